@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
+
+	"watchparty/internal/config"
+	"watchparty/internal/frontend"
+)
+
+// serveFrontend serves the web client, embedded or from disk, with the SPA
+// fallback behaving the same either way. It must be registered after every
+// API route, since any unmatched GET falls back to index.html.
+func serveFrontend(app *fiber.App, cfg *config.Config) {
+	files, source, ok := frontend.Open(cfg.FrontendDist)
+	if !ok {
+		log.Println("Frontend dist not found, running in API-only mode")
+		return
+	}
+	log.Printf("Serving frontend from: %s", source)
+
+	root := http.FS(files)
+	maxAge := int(cfg.StaticAssetMaxAge.Seconds())
+
+	app.Get("/*", func(c *fiber.Ctx) error {
+		name := strings.TrimPrefix(path.Clean("/"+c.Params("*")), "/")
+
+		if name != "" {
+			if info, err := fs.Stat(files, name); err == nil && !info.IsDir() {
+				if err := filesystem.SendFile(c, root, "/"+name); err != nil {
+					return err
+				}
+				// Vite fingerprints everything under assets/, so those
+				// files can be cached for a long time. Everything else
+				// must be revalidated so deploys are picked up.
+				if strings.HasPrefix(name, "assets/") && maxAge > 0 {
+					c.Set(fiber.HeaderCacheControl, fmt.Sprintf("public, max-age=%d, immutable", maxAge))
+				} else {
+					c.Set(fiber.HeaderCacheControl, "no-cache")
+				}
+				return nil
+			}
+		}
+
+		// SPA fallback - serve index.html for all unmatched routes
+		if err := filesystem.SendFile(c, root, "/index.html"); err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		return nil
+	})
+}
@@ -5,7 +5,9 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -14,12 +16,18 @@ import (
 
 	"watchparty/internal/config"
 	"watchparty/internal/handlers"
+	"watchparty/internal/logging"
 	"watchparty/internal/middleware"
 	"watchparty/internal/services"
+	"watchparty/internal/utils"
 	"watchparty/pkg/tunnel"
 	"watchparty/pkg/websocket"
 )
 
+// shutdownTimeout bounds how long shutdown waits for in-flight HTTP
+// requests once the hub has stopped
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// Load .env file if it exists
 	if err := godotenv.Load(); err != nil {
@@ -28,6 +36,41 @@ func main() {
 
 	// Load configuration
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	logging.Init(cfg.LogLevel, cfg.LogFormat)
+
+	// Apply password policy used by request validation
+	utils.SetPasswordPolicy(utils.PasswordPolicy{
+		MinLength:     cfg.PasswordMinLength,
+		MaxLength:     cfg.PasswordMaxLength,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireLetter: cfg.PasswordRequireLetter,
+	})
+
+	// Apply custom chat filter words, if any
+	if len(cfg.ProfanityList) > 0 {
+		utils.SetProfanityList(cfg.ProfanityList)
+	}
+
+	// Apply custom random username words, if any
+	usernameAdjectives, usernameAnimals := cfg.UsernameAdjectives, cfg.UsernameAnimals
+	if cfg.UsernameAdjectivesFile != "" {
+		words, err := utils.ReadWordList(cfg.UsernameAdjectivesFile)
+		if err != nil {
+			log.Fatalf("Failed to load USERNAME_ADJECTIVES_FILE: %v", err)
+		}
+		usernameAdjectives = words
+	}
+	if cfg.UsernameAnimalsFile != "" {
+		words, err := utils.ReadWordList(cfg.UsernameAnimalsFile)
+		if err != nil {
+			log.Fatalf("Failed to load USERNAME_ANIMALS_FILE: %v", err)
+		}
+		usernameAnimals = words
+	}
+	utils.SetUsernameWords(usernameAdjectives, usernameAnimals)
 
 	// Initialize Redis
 	redisService, err := services.NewRedisService(cfg)
@@ -38,41 +81,78 @@ func main() {
 	log.Println("Connected to Redis")
 
 	// Initialize services
-	authService := services.NewAuthService(cfg)
-	sessionService := services.NewSessionService(redisService, authService, cfg)
+	authService, err := services.NewAuthService(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize auth: %v", err)
+	}
+	iceService := services.NewICEService(redisService, cfg)
+	// Background workers run until shutdown cancels workerCtx, and shutdown
+	// then waits for them so Redis outlives them too
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	runWorker := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
+
+	// Session events go to WEBHOOK_URL on a background worker
+	webhookService := services.NewWebhookService(cfg)
+	runWorker(webhookService.Run)
+	sessionService := services.NewSessionService(redisService, authService, iceService, webhookService, cfg)
+
+	// Periodically free slots held by participants who never connected or left
+	runWorker(func(ctx context.Context) {
+		sessionService.RunParticipantReaper(ctx, time.Minute)
+	})
 
-	// Initialize WebSocket hub
-	hub := websocket.NewHub(redisService)
-	go hub.Run()
+	// Initialize WebSocket hub. It runs until shutdown cancels hubCtx, and
+	// shutdown then waits for it so Redis outlives the hub.
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	hub := websocket.NewHub(redisService, webhookService, cfg)
+	go hub.Run(hubCtx)
+	go hub.RunIdleSweeper(hubCtx)
 	log.Println("WebSocket hub started")
 
 	// Determine Base URL (Tunnel or Config)
-	baseURL := getBaseURL(cfg)
+	baseURL := tunnel.NewURLHolder(getBaseURL(cfg))
 	if cfg.EnableTunnel {
 		log.Println("Starting Cloudflare Tunnel...")
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
-		
-		// Start tunnel for frontend port (5173)
-		tunnelURL, err := tunnel.StartTunnel(ctx, "5173")
-		if err != nil {
-			log.Printf("Failed to start tunnel: %v", err)
-			log.Println("Falling back to default base URL")
-		} else {
-			log.Printf("Tunnel started successfully! Public URL: %s", tunnelURL)
-			baseURL = tunnelURL
-		}
+
+		// Supervise a tunnel per configured port (the frontend's 5173 by
+		// default), restarting each if it dies. Share links use the
+		// TUNNEL_SHARE_PORT tunnel, falling back to the default base URL
+		// until it is up.
+		tunnels := tunnel.NewGroup(cfg.TunnelPorts, cfg.TunnelSharePort, cfg.TunnelMaxRetries, baseURL)
+		go tunnels.Run(ctx)
 	}
 
 	// Initialize handlers
-	healthHandler := handlers.NewHealthHandler()
-	sessionHandler := handlers.NewSessionHandler(sessionService, baseURL)
-	wsHandler := handlers.NewWebSocketHandler(hub, authService)
+	healthHandler := handlers.NewHealthHandler(redisService, hub)
+	sessionHandler := handlers.NewSessionHandler(sessionService, hub, baseURL, cfg)
+	wsHandler := handlers.NewWebSocketHandler(hub, authService, cfg)
+	adminHandler := handlers.NewAdminHandler(sessionService, hub)
+	statsHandler := handlers.NewStatsHandler(sessionService, hub, cfg)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		AppName:      "WatchParty",
 		ServerHeader: "WatchParty",
+		// Every API body is small JSON; refuse anything bigger before parsing
+		BodyLimit: cfg.BodyLimit,
+		// c.IP() reads the real client address from the proxy header, but
+		// only for requests coming from a trusted proxy. For
+		// X-Forwarded-For the first valid address is used, so proxies in
+		// front should overwrite rather than append to it; Cloudflare's
+		// CF-Connecting-IP is set by Cloudflare alone.
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.TrustedProxies,
+		EnableIPValidation:      true,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
@@ -90,65 +170,168 @@ func main() {
 	app.Use(logger.New(logger.Config{
 		Format: "[${time}] ${status} - ${method} ${path} (${latency})\n",
 	}))
-	app.Use(middleware.CORSMiddleware(cfg.AllowedOrigins))
+	app.Use(middleware.CORSMiddleware(cfg.AllowedOrigins, cfg.CORSMethods, cfg.CORSHeaders, cfg.CORSAllowCredentials))
 
 	// Health check (no auth required)
 	app.Get("/health", healthHandler.Health)
+	// Kubernetes-style probes: liveness only says the process is serving,
+	// readiness also needs Redis and the hub
+	app.Get("/health/live", healthHandler.Live)
+	app.Get("/health/ready", healthHandler.Ready)
 
 	// API routes
 	api := app.Group("/api")
 
+	// Build info (no auth required)
+	api.Get("/version", healthHandler.Version)
+
+	// Aggregate usage, public unless STATS_PUBLIC=false
+	if cfg.StatsPublic {
+		api.Get("/stats", statsHandler.Stats)
+	} else {
+		api.Get("/stats", middleware.AdminAuthMiddleware(cfg.AdminSecret), statsHandler.Stats)
+	}
+
+	// WebRTC routes
+	api.Get("/ice-servers",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		sessionHandler.GetIceServers,
+	)
+
 	// Session routes
 	sessions := api.Group("/sessions")
 	sessions.Post("/create",
-		middleware.CreateSessionRateLimiter(cfg.CreateSessionLimit),
+		middleware.RequireJSON(),
+		middleware.CreateSessionRateLimiter(redisService, cfg.CreateSessionLimit),
 		sessionHandler.CreateSession,
 	)
 	sessions.Post("/join",
-		middleware.JoinSessionRateLimiter(cfg.JoinSessionLimit),
+		middleware.RequireJSON(),
+		middleware.JoinSessionRateLimiter(redisService, cfg.JoinSessionLimit),
 		sessionHandler.JoinSession,
 	)
+	sessions.Get("/:id/join/:requestId", sessionHandler.GetJoinStatus)
+	sessions.Get("/:id/exists",
+		middleware.SessionLookupRateLimiter(redisService, cfg.SessionLookupLimit),
+		sessionHandler.SessionExists,
+	)
 	sessions.Get("/:id",
-		middleware.AuthMiddleware(authService),
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
 		sessionHandler.GetSession,
 	)
+	sessions.Get("/:id/events",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		sessionHandler.SessionEvents,
+	)
+	sessions.Post("/:id/leave",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		sessionHandler.LeaveSession,
+	)
+	sessions.Put("/:id/media",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		middleware.HostOnlyMiddleware(sessionService),
+		sessionHandler.UpdateMedia,
+	)
+	sessions.Post("/:id/lock",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		middleware.HostOnlyMiddleware(sessionService),
+		sessionHandler.LockSession,
+	)
+	sessions.Put("/:id/password",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		middleware.HostOnlyMiddleware(sessionService),
+		sessionHandler.ChangePassword,
+	)
+	sessions.Get("/:id/transcript",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		middleware.HostOnlyMiddleware(sessionService),
+		sessionHandler.Transcript,
+	)
+	sessions.Post("/:id/rotate",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		middleware.HostOnlyMiddleware(sessionService),
+		sessionHandler.RotateSession,
+	)
+	sessions.Post("/:id/extend",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		middleware.HostOnlyMiddleware(sessionService),
+		sessionHandler.ExtendSession,
+	)
+	sessions.Put("/:id/mutes/:userId",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		middleware.HostOnlyMiddleware(sessionService),
+		sessionHandler.MuteUser,
+	)
+	sessions.Delete("/:id/mutes/:userId",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		middleware.HostOnlyMiddleware(sessionService),
+		sessionHandler.UnmuteUser,
+	)
+	sessions.Put("/:id/controllers/:userId",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		middleware.HostOnlyMiddleware(sessionService),
+		sessionHandler.GrantController,
+	)
+	sessions.Delete("/:id/controllers/:userId",
+		middleware.AuthMiddleware(authService, sessionService, cfg.AuthCookieName),
+		middleware.HostOnlyMiddleware(sessionService),
+		sessionHandler.RevokeController,
+	)
+
+	// Admin routes
+	admin := api.Group("/admin", middleware.AdminAuthMiddleware(cfg.AdminSecret))
+	admin.Get("/sessions", adminHandler.ListSessions)
+	admin.Post("/sessions/batch", adminHandler.BatchSessions)
+	admin.Get("/metrics", adminHandler.Metrics)
+	admin.Delete("/sessions/:id", adminHandler.TerminateSession)
+	admin.Get("/sessions/:id/audit", adminHandler.AuditLog)
 
 	// WebSocket route
 	app.Use("/ws/:sessionId", wsHandler.UpgradeMiddleware())
 	app.Get("/ws/:sessionId", wsHandler.HandleWebSocket())
 
-	// Serve static frontend files in production
-	// The frontend dist folder should be at ../frontend/dist relative to the binary
-	frontendDist := os.Getenv("FRONTEND_DIST")
-	if frontendDist == "" {
-		frontendDist = "../frontend/dist"
-	}
-	
-	// Check if frontend dist exists
-	if _, err := os.Stat(frontendDist); err == nil {
-		log.Printf("Serving frontend from: %s", frontendDist)
-		
-		// Serve static files
-		app.Static("/", frontendDist)
-		
-		// SPA fallback - serve index.html for all unmatched routes
-		app.Get("/*", func(c *fiber.Ctx) error {
-			return c.SendFile(frontendDist + "/index.html")
-		})
-	} else {
-		log.Println("Frontend dist not found, running in API-only mode")
-	}
+	// Serve the frontend, embedded or from FRONTEND_DIST, in production
+	serveFrontend(app, cfg)
 
-	// Graceful shutdown
+	// Graceful shutdown: stop the hub first and give the write pumps up to
+	// WS_WRITE_WAIT to send their close frames. That also ends the SSE
+	// streams, which would otherwise hold app.Shutdown open forever. Then
+	// stop taking requests, waiting at most shutdownTimeout for the rest,
+	// and stop the background workers, delivering the webhooks still
+	// queued within another shutdownTimeout. main waits on shutdownDone,
+	// so deferred cleanup such as closing Redis only runs after that.
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 
 		log.Println("Shutting down server...")
-		if err := app.Shutdown(); err != nil {
+		stopHub()
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.WSWriteWait)
+		defer cancel()
+		if err := hub.Wait(ctx); err != nil {
+			log.Printf("WebSocket connections not closed cleanly: %v", err)
+		}
+		if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
 			log.Printf("Server shutdown error: %v", err)
 		}
+
+		stopWorkers()
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer drainCancel()
+		workersDone := make(chan struct{})
+		go func() {
+			workers.Wait()
+			close(workersDone)
+		}()
+		select {
+		case <-workersDone:
+			webhookService.Drain(drainCtx)
+		case <-drainCtx.Done():
+			log.Println("Background workers did not stop in time")
+		}
 	}()
 
 	// Start server
@@ -157,6 +340,7 @@ func main() {
 	if err := app.Listen(":" + port); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
+	<-shutdownDone
 }
 
 func getBaseURL(cfg *config.Config) string {
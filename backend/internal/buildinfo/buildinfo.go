@@ -0,0 +1,33 @@
+// Package buildinfo holds version details injected at build time, e.g.
+//
+//	go build -ldflags "-X watchparty/internal/buildinfo.Version=v1.2.0 \
+//	  -X watchparty/internal/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X watchparty/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+package buildinfo
+
+import (
+	"time"
+
+	"watchparty/internal/models"
+)
+
+// Set via -ldflags; the defaults identify a local development build
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// startTime is when the server process started
+var startTime = time.Now()
+
+// Get returns the build details along with the server's start time and uptime
+func Get() models.VersionResponse {
+	return models.VersionResponse{
+		Version:       Version,
+		Commit:        Commit,
+		BuildTime:     BuildTime,
+		StartTime:     startTime.UTC().Format(time.RFC3339),
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+	}
+}
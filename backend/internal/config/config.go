@@ -5,79 +5,357 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"watchparty/internal/utils"
 )
 
+// DefaultJWTSecret is the development fallback secret, refused in production
+const DefaultJWTSecret = "your-secret-key-change-in-production"
+
 // Config holds all configuration for the application
 type Config struct {
 	// Server settings
-	Port string
+	Port      string
+	Env       string // development or production
+	BodyLimit int    // max request body size in bytes
+
+	// Logging
+	LogLevel  string // debug, info, warn, error
+	LogFormat string // text or json
 
 	// JWT settings
-	JWTSecret     string
-	JWTExpiration time.Duration
+	JWTAlg            string // HS256 (shared secret) or RS256 (key pair)
+	JWTSecret         string
+	JWTPrivateKeyFile string // PEM RSA private key used to sign with RS256
+	JWTPublicKeyFile  string // PEM RSA public key used to verify RS256; derived from the private key if unset
+	JWTExpiration     time.Duration
+	JWTAudience       string // expected "aud" claim, identifying this deployment
+
+	// The token may also travel in an HttpOnly cookie, out of reach of
+	// page scripts. Browsers attach cookies to cross-site requests too, so
+	// the cookie is SameSite=Strict and CORS must not allow untrusted
+	// origins with credentials.
+	AuthCookieName string // cookie read when no Authorization header is sent ("" disables)
+	AuthCookieSet  bool   // set the cookie on create and join responses
 
 	// Redis settings
-	RedisURL      string
-	RedisPassword string
-	RedisDB       int
+	RedisURL                   string
+	RedisPassword              string
+	RedisDB                    int
+	RedisTLS                   bool // connect over TLS; implied by a rediss:// URL
+	RedisTLSInsecureSkipVerify bool // accept any server certificate, for self-signed ones in development
+
+	// Redis resilience
+	RedisRetries          int           // extra attempts for transient errors on critical calls
+	RedisRetryBackoff     time.Duration // delay before the first retry, doubled each time
+	RedisBreakerThreshold int           // consecutive failures before calls fail fast
+	RedisBreakerCooldown  time.Duration // how long calls fail fast before Redis is tried again
 
 	// Session settings
-	SessionTTL       time.Duration
-	MaxParticipants  int
+	SessionTTL             time.Duration
+	MaxParticipants        int
+	MaxSessionsPerIP       int           // concurrently active sessions one IP may create (0 disables)
+	MaxTotalSessions       int           // concurrently active sessions on the whole server (0 disables)
+	SessionExtendIncrement time.Duration // how much each host extension adds to ExpiresAt
+	SessionMaxLifetime     time.Duration // cap on total session lifetime from creation
+	ParticipantGracePeriod time.Duration // how long a participant may stay disconnected before removal
+	EmptySessionGrace      time.Duration // how long an empty session lives before it is closed (0 disables)
+	ExpiredTombstoneTTL    time.Duration // how long closed sessions are reported as expired rather than not found
+	UserLeftDebounce       time.Duration // how long to wait for a reconnect before announcing user_left (0 disables)
+	HostOfflineGrace       time.Duration // how long a disconnected host keeps the role before it passes on (0 disables)
+	AuditLogSize           int           // audit entries kept per session
+	AuditLogTTL            time.Duration // how long a session's audit trail outlives its last entry
 
 	// Rate limiting
-	CreateSessionLimit int           // per hour per IP
-	JoinSessionLimit   int           // per minute per session
-	WSMessageLimit     int           // per minute per connection
+	CreateSessionLimit int // per hour per IP
+	JoinSessionLimit   int // per minute per session
+	WSMessageLimit     int // per minute per connection
+	SessionLookupLimit int // existence checks per minute per IP
+	SessionChatRate    int // low-priority messages per second per session (0 disables)
+
+	// WebSocket
+	WSMaxMessageSize           int64         // bytes per incoming message, measured after decompression
+	WSCompression              bool          // negotiate permessage-deflate with clients
+	WSSendBuffer               int           // messages queued per client before new ones are dropped
+	WSMaxSendDrops             int           // consecutive dropped messages before a stalled client is evicted
+	HubBroadcastBuffer         int           // session broadcasts queued for the hub before new ones are dropped
+	HubDirectBuffer            int           // direct messages queued for the hub before new ones are dropped
+	WSPingInterval             time.Duration // how often the server pings each client
+	WSPongWait                 time.Duration // how long to wait for any read, including pongs, before dropping
+	WSWriteWait                time.Duration // time allowed to write a message to the peer
+	WSMaxConnectionsPerSession int           // concurrent connections across a session (0 disables)
+	WSMaxConnectionsPerUser    int           // concurrent connections per participant, e.g. tabs (0 disables)
+	WSIdleTimeout              time.Duration // disconnect viewers who send no messages for this long (0 disables)
+
+	// Synchronized start
+	ReadyTimeout time.Duration // how long wait_for_all waits for slow viewers before playing anyway
+	SyncPlayLead time.Duration // how far ahead sync_play schedules the start so every client receives it in time
+
+	// Playback state persistence
+	PlaybackSaveDebounce time.Duration // how long to coalesce playback states, e.g. rapid seeks, before writing the last one
+
+	// Password hashing and policy
+	BcryptCost            int
+	PasswordMinLength     int
+	PasswordMaxLength     int // bytes; bcrypt can't hash more than 72
+	PasswordRequireDigit  bool
+	PasswordRequireLetter bool
+	PasswordChangeRevokes bool // changing a session password signs out everyone but the host
+
+	// Join brute-force protection
+	JoinMaxFailedAttempts int           // failed passwords before a session is blocked
+	JoinFailureWindow     time.Duration // window in which failures are counted
+	JoinLockoutDuration   time.Duration // how long joins stay blocked
+	JoinApprovalTimeout   time.Duration // how long a join request waits for the host
+
+	// Chat
+	MaxChatLength     int // characters per chat message
+	ChatFilterEnabled bool
+	ChatPersistence   bool // store chat history in Redis; when off, chat is only relayed live
+	ProfanityList     []string
+
+	// Chat transcripts for hosts to export, kept apart from the short replay history
+	TranscriptMaxMessages int           // messages kept per session, oldest dropped first (0 disables)
+	TranscriptTTL         time.Duration // how long a transcript lives after its last message
+
+	// Random usernames. A word list file takes precedence over the
+	// comma-separated list for the same half of the name.
+	UsernameAdjectives     []string
+	UsernameAnimals        []string
+	UsernameAdjectivesFile string
+	UsernameAnimalsFile    string
+
+	// Reverse proxies. The client IP, used for rate limits and session
+	// quotas, is read from ProxyHeader only on requests arriving from one
+	// of TrustedProxies.
+	ProxyHeader    string   // e.g. CF-Connecting-IP or X-Forwarded-For; empty uses the socket address
+	TrustedProxies []string // IPs or CIDRs of proxies allowed to set ProxyHeader
 
 	// CORS
-	AllowedOrigins []string
+	AllowedOrigins       []string
+	CORSMethods          []string
+	CORSHeaders          []string
+	CORSAllowCredentials bool
+
+	// Static frontend
+	FrontendDist      string        // built frontend on disk, used unless the binary embeds it
+	StaticAssetMaxAge time.Duration // browser cache lifetime for fingerprinted assets
+	ShareJoinPath     string        // frontend route that share links point at, followed by the session ID
+
+	// Webhooks
+	WebhookURLs    []string      // receivers of session events; none disables webhooks
+	WebhookSecret  string        // HMAC key for signing deliveries
+	WebhookTimeout time.Duration // per delivery attempt
+	WebhookRetries int           // extra attempts after a transient failure
+
+	// Public stats
+	StatsPublic   bool          // serve /api/stats without the admin secret
+	StatsCacheTTL time.Duration // how long aggregate stats are reused before recounting
 
 	// Tunnel
-	EnableTunnel bool
+	EnableTunnel     bool
+	TunnelMaxRetries int      // consecutive restart attempts before giving up
+	TunnelPorts      []string // local ports to expose, one tunnel each
+	TunnelSharePort  string   // port whose public URL is used for share links
 
-    // WebRTC
-    IceServers []interface{}
+	// WebRTC
+	IceServers         []interface{}
+	IceForceRelay      bool                     // force TURN relay for all sessions
+	IceServersByRegion map[string][]interface{} // region -> servers preferred for clients in that region
+	IceCountryRegions  map[string]string        // country code -> region; unmapped countries are their own region
+	RegionHeader       string                   // request header carrying the client's country code
 
-    // Security
-    AdminSecret string
+	// Security
+	AdminSecret         string
+	AllowPublicSessions bool     // permit sessions created without a password
+	AllowedMediaDomains []string // hosts (and their subdomains) media URLs may point at; empty allows any
 
-    // Metered.ca
-    MeteredAPIKey string
+	// Metered.ca
+	MeteredAPIKey   string
+	MeteredDomain   string
+	IceFetchTimeout time.Duration // per request to the Metered API
+	IceFetchRetries int           // extra attempts after a transient failure
+	IceCacheTTL     time.Duration // how long fetched credentials are reused, capped at half their lifetime
 }
 
 // Load creates a new Config from environment variables
 func Load() *Config {
+	corsAllowCredentials := getEnv("CORS_ALLOW_CREDENTIALS", "true") == "true"
+	tunnelPorts := getListEnv("TUNNEL_PORTS", []string{"5173"})
+	tunnelSharePort := ""
+	if len(tunnelPorts) > 0 {
+		tunnelSharePort = tunnelPorts[0]
+	}
+
 	return &Config{
-		Port: getEnv("PORT", "8080"),
+		Port:      getEnv("PORT", "8080"),
+		Env:       getEnv("ENV", "development"),
+		BodyLimit: getIntEnv("BODY_LIMIT", 64*1024), // 64KB
+
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogFormat: getEnv("LOG_FORMAT", "text"),
+
+		JWTAlg:            strings.ToUpper(getEnv("JWT_ALG", "HS256")),
+		JWTSecret:         getEnv("JWT_SECRET", DefaultJWTSecret),
+		JWTPrivateKeyFile: getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTPublicKeyFile:  getEnv("JWT_PUBLIC_KEY_FILE", ""),
+		JWTExpiration:     getDurationEnv("JWT_EXPIRATION", time.Hour),
+		JWTAudience:       getEnv("JWT_AUDIENCE", getEnv("FRONTEND_URL", "http://localhost:5173")),
+		AuthCookieName:    getEnv("AUTH_COOKIE_NAME", "watchparty_token"),
+		AuthCookieSet:     getEnv("AUTH_COOKIE_SET", "false") == "true",
 
-		JWTSecret:     getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-		JWTExpiration: getDurationEnv("JWT_EXPIRATION", time.Hour),
+		RedisURL:                   getEnv("REDIS_URL", "localhost:6379"),
+		RedisPassword:              getEnv("REDIS_PASSWORD", ""),
+		RedisDB:                    getIntEnv("REDIS_DB", 0),
+		RedisTLS:                   getEnv("REDIS_TLS", "false") == "true",
+		RedisTLSInsecureSkipVerify: getEnv("REDIS_TLS_INSECURE_SKIP_VERIFY", "false") == "true",
 
-		RedisURL:      getEnv("REDIS_URL", "localhost:6379"),
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
-		RedisDB:       getIntEnv("REDIS_DB", 0),
+		RedisRetries:          getIntEnv("REDIS_RETRIES", 2),
+		RedisRetryBackoff:     getDurationEnv("REDIS_RETRY_BACKOFF", 50*time.Millisecond),
+		RedisBreakerThreshold: getIntEnv("REDIS_BREAKER_THRESHOLD", 5),
+		RedisBreakerCooldown:  getDurationEnv("REDIS_BREAKER_COOLDOWN", 10*time.Second),
 
-		SessionTTL:      getDurationEnv("SESSION_TTL", 24*time.Hour),
-		MaxParticipants: getIntEnv("MAX_PARTICIPANTS", 10),
+		SessionTTL:             getDurationEnv("SESSION_TTL", 24*time.Hour),
+		MaxParticipants:        getIntEnv("MAX_PARTICIPANTS", 10),
+		MaxSessionsPerIP:       getIntEnv("MAX_SESSIONS_PER_IP", 5),
+		MaxTotalSessions:       getIntEnv("MAX_TOTAL_SESSIONS", 0),
+		SessionExtendIncrement: getDurationEnv("SESSION_EXTEND_INCREMENT", 12*time.Hour),
+		SessionMaxLifetime:     getDurationEnv("SESSION_MAX_LIFETIME", 72*time.Hour),
+		ParticipantGracePeriod: getDurationEnv("PARTICIPANT_GRACE_PERIOD", 2*time.Minute),
+		EmptySessionGrace:      getDurationEnv("EMPTY_SESSION_GRACE", 5*time.Minute),
+		ExpiredTombstoneTTL:    getDurationEnv("EXPIRED_TOMBSTONE_TTL", 24*time.Hour),
+		UserLeftDebounce:       getDurationEnv("USER_LEFT_DEBOUNCE", 3*time.Second),
+		HostOfflineGrace:       getDurationEnv("HOST_OFFLINE_GRACE", 30*time.Second),
+		AuditLogSize:           getIntEnv("AUDIT_LOG_SIZE", 200),
+		AuditLogTTL:            getDurationEnv("AUDIT_LOG_TTL", 7*24*time.Hour),
 
 		CreateSessionLimit: getIntEnv("CREATE_SESSION_LIMIT", 5),
 		JoinSessionLimit:   getIntEnv("JOIN_SESSION_LIMIT", 10),
 		WSMessageLimit:     getIntEnv("WS_MESSAGE_LIMIT", 100),
+		SessionLookupLimit: getIntEnv("SESSION_LOOKUP_LIMIT", 30),
+		SessionChatRate:    getIntEnv("SESSION_CHAT_RATE", 20),
 
-		AllowedOrigins: []string{
-			"*", // Allow all origins for Cloudflare Tunnel testing
-			"http://localhost:5173",
-			getEnv("FRONTEND_URL", "http://localhost:5173"),
-		},
-		EnableTunnel: getEnv("ENABLE_TUNNEL", "false") == "true",
-		IceServers:   getIceServers(),
-		AdminSecret:  getEnv("ADMIN_SECRET", ""),
-		MeteredAPIKey: getEnv("METERED_API_KEY", ""),
+		WSMaxMessageSize:           int64(getIntEnv("WS_MAX_MESSAGE_SIZE", 64*1024)), // 64KB
+		WSCompression:              getEnv("WS_COMPRESSION", "false") == "true",
+		WSSendBuffer:               getIntEnv("WS_SEND_BUFFER", 256),
+		WSMaxSendDrops:             getIntEnv("WS_MAX_SEND_DROPS", 32),
+		HubBroadcastBuffer:         getIntEnv("HUB_BROADCAST_BUFFER", 256),
+		HubDirectBuffer:            getIntEnv("HUB_DIRECT_BUFFER", 256),
+		WSPingInterval:             getDurationEnv("WS_PING_INTERVAL", 54*time.Second),
+		WSPongWait:                 getDurationEnv("WS_PONG_WAIT", 60*time.Second),
+		WSWriteWait:                getDurationEnv("WS_WRITE_WAIT", 10*time.Second),
+		WSMaxConnectionsPerSession: getIntEnv("WS_MAX_CONNECTIONS_PER_SESSION", 50),
+		WSMaxConnectionsPerUser:    getIntEnv("WS_MAX_CONNECTIONS_PER_USER", 3),
+		WSIdleTimeout:              getDurationEnv("WS_IDLE_TIMEOUT", 0),
+
+		ReadyTimeout: getDurationEnv("READY_TIMEOUT", 10*time.Second),
+		SyncPlayLead: getDurationEnv("SYNC_PLAY_LEAD", 500*time.Millisecond),
+
+		PlaybackSaveDebounce: getDurationEnv("PLAYBACK_SAVE_DEBOUNCE", time.Second),
+
+		BcryptCost:            getBcryptCost(),
+		PasswordMinLength:     getIntEnv("PASSWORD_MIN_LENGTH", 6),
+		PasswordMaxLength:     getIntEnv("PASSWORD_MAX_LENGTH", utils.MaxPasswordBytes),
+		PasswordRequireDigit:  getEnv("PASSWORD_REQUIRE_DIGIT", "false") == "true",
+		PasswordRequireLetter: getEnv("PASSWORD_REQUIRE_LETTER", "false") == "true",
+		PasswordChangeRevokes: getEnv("PASSWORD_CHANGE_REVOKES", "true") == "true",
+
+		JoinMaxFailedAttempts: getIntEnv("JOIN_MAX_FAILED_ATTEMPTS", 10),
+		JoinFailureWindow:     getDurationEnv("JOIN_FAILURE_WINDOW", 15*time.Minute),
+		JoinLockoutDuration:   getDurationEnv("JOIN_LOCKOUT_DURATION", 15*time.Minute),
+		JoinApprovalTimeout:   getDurationEnv("JOIN_APPROVAL_TIMEOUT", 5*time.Minute),
+
+		MaxChatLength:     getIntEnv("MAX_CHAT_LENGTH", 500),
+		ChatFilterEnabled: getEnv("CHAT_FILTER_ENABLED", "false") == "true",
+		ChatPersistence:   getEnv("CHAT_PERSISTENCE_ENABLED", "true") == "true",
+		ProfanityList:     getListEnv("PROFANITY_LIST", nil),
+
+		TranscriptMaxMessages: getIntEnv("TRANSCRIPT_MAX_MESSAGES", 5000),
+		TranscriptTTL:         getDurationEnv("TRANSCRIPT_TTL", 24*time.Hour),
+
+		UsernameAdjectives:     getListEnv("USERNAME_ADJECTIVES", nil),
+		UsernameAnimals:        getListEnv("USERNAME_ANIMALS", nil),
+		UsernameAdjectivesFile: getEnv("USERNAME_ADJECTIVES_FILE", ""),
+		UsernameAnimalsFile:    getEnv("USERNAME_ANIMALS_FILE", ""),
+
+		ProxyHeader:    getEnv("PROXY_HEADER", ""),
+		TrustedProxies: getListEnv("TRUSTED_PROXIES", nil),
+
+		AllowedOrigins:       getAllowedOrigins(corsAllowCredentials),
+		CORSMethods:          getListEnv("CORS_METHODS", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
+		CORSHeaders:          getListEnv("CORS_HEADERS", []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Admin-Secret"}),
+		CORSAllowCredentials: corsAllowCredentials,
+
+		FrontendDist:      getEnv("FRONTEND_DIST", "../frontend/dist"),
+		StaticAssetMaxAge: getDurationEnv("STATIC_ASSET_MAX_AGE", 365*24*time.Hour),
+		ShareJoinPath:     getEnv("SHARE_JOIN_PATH", "/join/"),
+
+		WebhookURLs:    getListEnv("WEBHOOK_URL", nil),
+		WebhookSecret:  getEnv("WEBHOOK_SECRET", ""),
+		WebhookTimeout: getDurationEnv("WEBHOOK_TIMEOUT", 5*time.Second),
+		WebhookRetries: getIntEnv("WEBHOOK_RETRIES", 3),
+
+		StatsPublic:   getEnv("STATS_PUBLIC", "true") == "true",
+		StatsCacheTTL: getDurationEnv("STATS_CACHE_TTL", 10*time.Second),
+
+		EnableTunnel:        getEnv("ENABLE_TUNNEL", "false") == "true",
+		TunnelMaxRetries:    getIntEnv("TUNNEL_MAX_RETRIES", 5),
+		TunnelPorts:         tunnelPorts,
+		TunnelSharePort:     getEnv("TUNNEL_SHARE_PORT", tunnelSharePort),
+		IceServers:          getIceServers(),
+		IceForceRelay:       getEnv("ICE_FORCE_RELAY", "false") == "true",
+		IceServersByRegion:  getIceServersByRegion(),
+		IceCountryRegions:   getCountryRegions(),
+		RegionHeader:        getEnv("REGION_HEADER", "CF-IPCountry"),
+		AdminSecret:         getEnv("ADMIN_SECRET", ""),
+		AllowPublicSessions: getEnv("ALLOW_PUBLIC_SESSIONS", "true") == "true",
+		AllowedMediaDomains: getListEnv("ALLOWED_MEDIA_DOMAINS", nil),
+		MeteredAPIKey:       getEnv("METERED_API_KEY", ""),
+		MeteredDomain:       getEnv("METERED_DOMAIN", "vibecodingisreal.metered.live"),
+		IceFetchTimeout:     getDurationEnv("ICE_FETCH_TIMEOUT", 5*time.Second),
+		IceFetchRetries:     getIntEnv("ICE_FETCH_RETRIES", 2),
+		IceCacheTTL:         getDurationEnv("ICE_CACHE_TTL", time.Hour),
 	}
 }
 
+// HasTurnServers reports whether any TURN relay is available, either from
+// the configured ICE servers or via Metered.ca credentials
+func (c *Config) HasTurnServers() bool {
+	if c.MeteredAPIKey != "" {
+		return true
+	}
+	for _, server := range c.IceServers {
+		entry, ok := server.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var urls []interface{}
+		switch u := entry["urls"].(type) {
+		case string:
+			urls = []interface{}{u}
+		case []interface{}:
+			urls = u
+		}
+		for _, url := range urls {
+			if s, ok := url.(string); ok && (strings.HasPrefix(s, "turn:") || strings.HasPrefix(s, "turns:")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getBcryptCost() int {
+	cost := getIntEnv("BCRYPT_COST", utils.DefaultBcryptCost)
+	if !utils.IsValidBcryptCost(cost) {
+		log.Printf("Invalid BCRYPT_COST %d, must be between 4 and 31. Using default %d.", cost, utils.DefaultBcryptCost)
+		return utils.DefaultBcryptCost
+	}
+	return cost
+}
+
 func getIceServers() []interface{} {
 	// Default public STUN servers
 	defaultServers := []interface{}{
@@ -108,6 +386,48 @@ func getIceServers() []interface{} {
 	return servers
 }
 
+// getIceServersByRegion parses ICE_SERVERS_BY_REGION, a JSON object mapping
+// region codes to ICE server lists in the same format as ICE_SERVERS
+func getIceServersByRegion() map[string][]interface{} {
+	value := os.Getenv("ICE_SERVERS_BY_REGION")
+	if value == "" {
+		return nil
+	}
+
+	var byRegion map[string][]interface{}
+	if err := json.Unmarshal([]byte(value), &byRegion); err != nil {
+		log.Printf("Invalid ICE_SERVERS_BY_REGION JSON: %v. Ignoring regional servers.", err)
+		return nil
+	}
+
+	normalized := make(map[string][]interface{}, len(byRegion))
+	for region, servers := range byRegion {
+		normalized[strings.ToUpper(region)] = servers
+	}
+	return normalized
+}
+
+// getCountryRegions parses ICE_COUNTRY_REGIONS, a JSON object grouping
+// country codes into the regions of ICE_SERVERS_BY_REGION, e.g. {"DE":"EU"}
+func getCountryRegions() map[string]string {
+	value := os.Getenv("ICE_COUNTRY_REGIONS")
+	if value == "" {
+		return nil
+	}
+
+	var regions map[string]string
+	if err := json.Unmarshal([]byte(value), &regions); err != nil {
+		log.Printf("Invalid ICE_COUNTRY_REGIONS JSON: %v. Using country codes as regions.", err)
+		return nil
+	}
+
+	normalized := make(map[string]string, len(regions))
+	for country, region := range regions {
+		normalized[strings.ToUpper(country)] = strings.ToUpper(region)
+	}
+	return normalized
+}
+
 // Helper functions for environment variables
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -125,6 +445,33 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getAllowedOrigins returns the CORS origin list. The "*" wildcard used for
+// Cloudflare Tunnel testing is only included when credentials are disabled,
+// since browsers reject a wildcard origin on credentialed requests.
+func getAllowedOrigins(allowCredentials bool) []string {
+	origins := []string{
+		"http://localhost:5173",
+		getEnv("FRONTEND_URL", "http://localhost:5173"),
+	}
+	if !allowCredentials {
+		origins = append([]string{"*"}, origins...)
+	}
+	return origins
+}
+
+func getListEnv(key string, defaultValue []string) []string {
+	if value := os.Getenv(key); value != "" {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list
+	}
+	return defaultValue
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestAllowedOriginsWildcardOnlyWithoutCredentials(t *testing.T) {
+	t.Setenv("FRONTEND_URL", "https://watch.example.com")
+
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	cfg := Load()
+	if !cfg.CORSAllowCredentials {
+		t.Fatal("credentials not enabled")
+	}
+	if slices.Contains(cfg.AllowedOrigins, "*") {
+		t.Errorf("origins %v include the wildcard while credentials are allowed", cfg.AllowedOrigins)
+	}
+	if !slices.Contains(cfg.AllowedOrigins, "https://watch.example.com") {
+		t.Errorf("origins %v do not include FRONTEND_URL", cfg.AllowedOrigins)
+	}
+
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "false")
+	cfg = Load()
+	if cfg.CORSAllowCredentials {
+		t.Fatal("credentials not disabled")
+	}
+	if !slices.Contains(cfg.AllowedOrigins, "*") {
+		t.Errorf("origins %v lack the wildcard while credentials are disabled", cfg.AllowedOrigins)
+	}
+}
+
+func TestCORSMethodsAndHeaders(t *testing.T) {
+	cfg := Load()
+	if want := []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}; !reflect.DeepEqual(cfg.CORSMethods, want) {
+		t.Errorf("default methods = %v, want %v", cfg.CORSMethods, want)
+	}
+	if !slices.Contains(cfg.CORSHeaders, "Authorization") {
+		t.Errorf("default headers %v lack Authorization", cfg.CORSHeaders)
+	}
+
+	t.Setenv("CORS_METHODS", "GET, PATCH ,")
+	t.Setenv("CORS_HEADERS", "Content-Type,X-Request-ID")
+	cfg = Load()
+	if want := []string{"GET", "PATCH"}; !reflect.DeepEqual(cfg.CORSMethods, want) {
+		t.Errorf("methods = %v, want %v", cfg.CORSMethods, want)
+	}
+	if want := []string{"Content-Type", "X-Request-ID"}; !reflect.DeepEqual(cfg.CORSHeaders, want) {
+		t.Errorf("headers = %v, want %v", cfg.CORSHeaders, want)
+	}
+}
+
+func TestUsernameWordsFromEnv(t *testing.T) {
+	t.Setenv("USERNAME_ADJECTIVES", "Sneaky, Grumpy,,")
+	t.Setenv("USERNAME_ANIMALS", "")
+
+	cfg := Load()
+	if want := []string{"Sneaky", "Grumpy"}; !slices.Equal(cfg.UsernameAdjectives, want) {
+		t.Errorf("adjectives = %v, want %v", cfg.UsernameAdjectives, want)
+	}
+	if len(cfg.UsernameAnimals) != 0 {
+		t.Errorf("animals = %v, want none so the defaults apply", cfg.UsernameAnimals)
+	}
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"watchparty/internal/utils"
+)
+
+// IsProduction reports whether the server runs with ENV=production
+func (c *Config) IsProduction() bool {
+	return c.Env == "production"
+}
+
+// Validate checks the configuration for unsafe or nonsensical values.
+// Production deployments are additionally refused when using development
+// defaults such as the fallback JWT secret.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.IsProduction() {
+		if c.JWTAlg == "HS256" && c.JWTSecret == DefaultJWTSecret {
+			errs = append(errs, errors.New("JWT_SECRET must be set in production"))
+		}
+		if c.RedisURL == "" {
+			errs = append(errs, errors.New("REDIS_URL must be set in production"))
+		}
+	}
+
+	switch c.JWTAlg {
+	case "HS256":
+		if c.JWTSecret == "" {
+			errs = append(errs, errors.New("JWT_SECRET must not be empty"))
+		}
+	case "RS256":
+		if c.JWTPrivateKeyFile == "" {
+			errs = append(errs, errors.New("JWT_PRIVATE_KEY_FILE must be set when JWT_ALG is RS256"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("JWT_ALG must be HS256 or RS256, got %q", c.JWTAlg))
+	}
+	if c.JWTAudience == "" {
+		errs = append(errs, errors.New("JWT_AUDIENCE must not be empty"))
+	}
+	if c.AuthCookieSet && c.AuthCookieName == "" {
+		errs = append(errs, errors.New("AUTH_COOKIE_NAME must be set when AUTH_COOKIE_SET is enabled"))
+	}
+	if c.JWTExpiration <= 0 {
+		errs = append(errs, fmt.Errorf("JWT_EXPIRATION must be positive, got %v", c.JWTExpiration))
+	}
+	if c.SessionTTL <= 0 {
+		errs = append(errs, fmt.Errorf("SESSION_TTL must be positive, got %v", c.SessionTTL))
+	}
+	if c.MaxParticipants <= 0 {
+		errs = append(errs, fmt.Errorf("MAX_PARTICIPANTS must be positive, got %d", c.MaxParticipants))
+	}
+
+	positive := []struct {
+		name  string
+		value int
+	}{
+		{"CREATE_SESSION_LIMIT", c.CreateSessionLimit},
+		{"JOIN_SESSION_LIMIT", c.JoinSessionLimit},
+		{"WS_MESSAGE_LIMIT", c.WSMessageLimit},
+		{"SESSION_LOOKUP_LIMIT", c.SessionLookupLimit},
+		{"REDIS_BREAKER_THRESHOLD", c.RedisBreakerThreshold},
+		{"MAX_CHAT_LENGTH", c.MaxChatLength},
+		{"JOIN_MAX_FAILED_ATTEMPTS", c.JoinMaxFailedAttempts},
+		{"WS_SEND_BUFFER", c.WSSendBuffer},
+		{"WS_MAX_SEND_DROPS", c.WSMaxSendDrops},
+		{"HUB_BROADCAST_BUFFER", c.HubBroadcastBuffer},
+		{"HUB_DIRECT_BUFFER", c.HubDirectBuffer},
+		{"AUDIT_LOG_SIZE", c.AuditLogSize},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %d", p.name, p.value))
+		}
+	}
+
+	if !strings.HasPrefix(c.ShareJoinPath, "/") {
+		errs = append(errs, fmt.Errorf("SHARE_JOIN_PATH must start with /, got %q", c.ShareJoinPath))
+	}
+	for _, u := range c.WebhookURLs {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("WEBHOOK_URL contains invalid URL %q", u))
+		}
+	}
+	for _, domain := range c.AllowedMediaDomains {
+		if strings.Contains(domain, "/") || strings.Contains(domain, ":") || strings.Trim(domain, ".") == "" {
+			errs = append(errs, fmt.Errorf("ALLOWED_MEDIA_DOMAINS contains invalid domain %q, expected a bare host such as youtube.com", domain))
+		}
+	}
+	if len(c.WebhookURLs) > 0 {
+		if c.WebhookTimeout <= 0 {
+			errs = append(errs, fmt.Errorf("WEBHOOK_TIMEOUT must be positive, got %v", c.WebhookTimeout))
+		}
+		if c.WebhookRetries < 0 {
+			errs = append(errs, fmt.Errorf("WEBHOOK_RETRIES must not be negative, got %d", c.WebhookRetries))
+		}
+		if c.IsProduction() && c.WebhookSecret == "" {
+			errs = append(errs, errors.New("WEBHOOK_SECRET must be set in production when WEBHOOK_URL is"))
+		}
+	}
+	if c.StatsCacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("STATS_CACHE_TTL must not be negative, got %v", c.StatsCacheTTL))
+	}
+	if c.StaticAssetMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("STATIC_ASSET_MAX_AGE must not be negative, got %v", c.StaticAssetMaxAge))
+	}
+	if c.SessionChatRate < 0 {
+		errs = append(errs, fmt.Errorf("SESSION_CHAT_RATE must not be negative, got %d", c.SessionChatRate))
+	}
+	if c.WSIdleTimeout < 0 {
+		errs = append(errs, fmt.Errorf("WS_IDLE_TIMEOUT must not be negative, got %v", c.WSIdleTimeout))
+	}
+	if c.HostOfflineGrace < 0 {
+		errs = append(errs, fmt.Errorf("HOST_OFFLINE_GRACE must not be negative, got %v", c.HostOfflineGrace))
+	}
+	if c.SyncPlayLead < 0 {
+		errs = append(errs, fmt.Errorf("SYNC_PLAY_LEAD must not be negative, got %v", c.SyncPlayLead))
+	}
+	if c.TranscriptMaxMessages < 0 {
+		errs = append(errs, fmt.Errorf("TRANSCRIPT_MAX_MESSAGES must not be negative, got %d", c.TranscriptMaxMessages))
+	}
+	if c.TranscriptMaxMessages > 0 && c.TranscriptTTL <= 0 {
+		errs = append(errs, fmt.Errorf("TRANSCRIPT_TTL must be positive, got %v", c.TranscriptTTL))
+	}
+	if c.PlaybackSaveDebounce < 0 {
+		errs = append(errs, fmt.Errorf("PLAYBACK_SAVE_DEBOUNCE must not be negative, got %v", c.PlaybackSaveDebounce))
+	}
+	if c.RedisTLSInsecureSkipVerify && c.IsProduction() {
+		errs = append(errs, errors.New("REDIS_TLS_INSECURE_SKIP_VERIFY must not be enabled in production"))
+	}
+	if c.RedisRetries < 0 {
+		errs = append(errs, fmt.Errorf("REDIS_RETRIES must not be negative, got %d", c.RedisRetries))
+	}
+	if c.WSMaxConnectionsPerSession < 0 || c.WSMaxConnectionsPerUser < 0 {
+		errs = append(errs, errors.New("WS_MAX_CONNECTIONS_PER_SESSION and WS_MAX_CONNECTIONS_PER_USER must not be negative"))
+	}
+	if c.MaxSessionsPerIP < 0 {
+		errs = append(errs, fmt.Errorf("MAX_SESSIONS_PER_IP must not be negative, got %d", c.MaxSessionsPerIP))
+	}
+	if c.MaxTotalSessions < 0 {
+		errs = append(errs, fmt.Errorf("MAX_TOTAL_SESSIONS must not be negative, got %d", c.MaxTotalSessions))
+	}
+	if c.IceFetchTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("ICE_FETCH_TIMEOUT must be positive, got %v", c.IceFetchTimeout))
+	}
+	if c.IceFetchRetries < 0 {
+		errs = append(errs, fmt.Errorf("ICE_FETCH_RETRIES must not be negative, got %d", c.IceFetchRetries))
+	}
+	if c.WSMaxMessageSize <= 0 {
+		errs = append(errs, fmt.Errorf("WS_MAX_MESSAGE_SIZE must be positive, got %d", c.WSMaxMessageSize))
+	}
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"WS_PING_INTERVAL", c.WSPingInterval},
+		{"WS_PONG_WAIT", c.WSPongWait},
+		{"WS_WRITE_WAIT", c.WSWriteWait},
+		{"SESSION_EXTEND_INCREMENT", c.SessionExtendIncrement},
+		{"REDIS_RETRY_BACKOFF", c.RedisRetryBackoff},
+		{"REDIS_BREAKER_COOLDOWN", c.RedisBreakerCooldown},
+		{"AUDIT_LOG_TTL", c.AuditLogTTL},
+		{"READY_TIMEOUT", c.ReadyTimeout},
+		{"ICE_CACHE_TTL", c.IceCacheTTL},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %v", d.name, d.value))
+		}
+	}
+	// A pong can only arrive after a ping, so pings must go out well within the read deadline
+	if c.WSPingInterval >= c.WSPongWait {
+		errs = append(errs, fmt.Errorf("WS_PING_INTERVAL (%v) must be less than WS_PONG_WAIT (%v)", c.WSPingInterval, c.WSPongWait))
+	}
+	if c.SessionMaxLifetime < c.SessionTTL {
+		errs = append(errs, fmt.Errorf("SESSION_MAX_LIFETIME (%v) must be at least SESSION_TTL (%v)", c.SessionMaxLifetime, c.SessionTTL))
+	}
+	if c.PasswordMinLength < 0 {
+		errs = append(errs, fmt.Errorf("PASSWORD_MIN_LENGTH must not be negative, got %d", c.PasswordMinLength))
+	}
+	if c.PasswordMaxLength < c.PasswordMinLength || c.PasswordMaxLength > utils.MaxPasswordBytes {
+		errs = append(errs, fmt.Errorf("PASSWORD_MAX_LENGTH must be between PASSWORD_MIN_LENGTH and %d, got %d", utils.MaxPasswordBytes, c.PasswordMaxLength))
+	}
+	if c.BodyLimit <= 0 {
+		errs = append(errs, fmt.Errorf("BODY_LIMIT must be positive, got %d", c.BodyLimit))
+	}
+	if c.TunnelMaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("TUNNEL_MAX_RETRIES must not be negative, got %d", c.TunnelMaxRetries))
+	}
+	if c.EnableTunnel {
+		errs = append(errs, c.validateTunnelPorts()...)
+	}
+	errs = append(errs, c.validateTrustedProxies()...)
+	if c.JoinFailureWindow <= 0 || c.JoinLockoutDuration <= 0 {
+		errs = append(errs, errors.New("JOIN_FAILURE_WINDOW and JOIN_LOCKOUT_DURATION must be positive"))
+	}
+
+	if c.IceForceRelay && !c.HasTurnServers() {
+		errs = append(errs, errors.New("ICE_FORCE_RELAY requires a TURN server in ICE_SERVERS or METERED_API_KEY"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateTrustedProxies checks that a proxy header comes with the proxies
+// allowed to set it, and that each of those is an IP or CIDR
+func (c *Config) validateTrustedProxies() []error {
+	var errs []error
+	if c.ProxyHeader != "" && len(c.TrustedProxies) == 0 {
+		errs = append(errs, errors.New("TRUSTED_PROXIES must be set when PROXY_HEADER is, or any client could spoof its IP"))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			errs = append(errs, fmt.Errorf("TRUSTED_PROXIES contains invalid IP or CIDR %q", proxy))
+		}
+	}
+	return errs
+}
+
+// validateTunnelPorts checks that every tunnel port is a valid TCP port and
+// that the share port is one of them
+func (c *Config) validateTunnelPorts() []error {
+	if len(c.TunnelPorts) == 0 {
+		return []error{errors.New("TUNNEL_PORTS must list at least one port when ENABLE_TUNNEL is set")}
+	}
+
+	var errs []error
+	shared := false
+	for _, port := range c.TunnelPorts {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			errs = append(errs, fmt.Errorf("TUNNEL_PORTS contains invalid port %q", port))
+		}
+		if port == c.TunnelSharePort {
+			shared = true
+		}
+	}
+	if !shared {
+		errs = append(errs, fmt.Errorf("TUNNEL_SHARE_PORT %q must be one of TUNNEL_PORTS", c.TunnelSharePort))
+	}
+	return errs
+}
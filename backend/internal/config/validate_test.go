@@ -0,0 +1,141 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateAcceptsDefaults(t *testing.T) {
+	if err := Load().Validate(); err != nil {
+		t.Fatalf("default configuration rejected: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string // substring of the error, empty if valid
+	}{
+		{"default secret in production", func(c *Config) { c.Env = "production" }, "JWT_SECRET must be set in production"},
+		{"custom secret in production", func(c *Config) {
+			c.Env = "production"
+			c.JWTSecret = "a-real-secret"
+		}, ""},
+		{"default secret in development", func(c *Config) { c.Env = "development" }, ""},
+		{"empty Redis URL in production", func(c *Config) {
+			c.Env = "production"
+			c.JWTSecret = "a-real-secret"
+			c.RedisURL = ""
+		}, "REDIS_URL must be set in production"},
+		{"empty JWT secret", func(c *Config) { c.JWTSecret = "" }, "JWT_SECRET must not be empty"},
+		{"unknown JWT algorithm", func(c *Config) { c.JWTAlg = "none" }, "JWT_ALG must be HS256 or RS256"},
+		{"RS256 without key", func(c *Config) { c.JWTAlg = "RS256" }, "JWT_PRIVATE_KEY_FILE must be set"},
+		{"zero session TTL", func(c *Config) { c.SessionTTL = 0 }, "SESSION_TTL must be positive"},
+		{"negative JWT expiration", func(c *Config) { c.JWTExpiration = -time.Hour }, "JWT_EXPIRATION must be positive"},
+		{"zero max participants", func(c *Config) { c.MaxParticipants = 0 }, "MAX_PARTICIPANTS must be positive"},
+		{"negative rate limit", func(c *Config) { c.CreateSessionLimit = -1 }, "CREATE_SESSION_LIMIT must be positive"},
+		{"zero chat length", func(c *Config) { c.MaxChatLength = 0 }, "MAX_CHAT_LENGTH must be positive"},
+		{"zero send buffer", func(c *Config) { c.WSSendBuffer = 0 }, "WS_SEND_BUFFER must be positive"},
+		{"skipping Redis TLS verification in production", func(c *Config) {
+			c.Env = "production"
+			c.JWTSecret = "a-real-secret"
+			c.RedisTLSInsecureSkipVerify = true
+		}, "REDIS_TLS_INSECURE_SKIP_VERIFY"},
+		{"negative Redis retries", func(c *Config) { c.RedisRetries = -1 }, "REDIS_RETRIES must not be negative"},
+		{"ping not within pong wait", func(c *Config) { c.WSPingInterval = c.WSPongWait }, "WS_PING_INTERVAL"},
+		{"lifetime shorter than TTL", func(c *Config) { c.SessionMaxLifetime = c.SessionTTL - time.Minute }, "SESSION_MAX_LIFETIME"},
+		{"proxy header without proxies", func(c *Config) {
+			c.ProxyHeader = "X-Forwarded-For"
+			c.TrustedProxies = nil
+		}, "TRUSTED_PROXIES must be set"},
+		{"invalid trusted proxy", func(c *Config) { c.TrustedProxies = []string{"not-an-ip"} }, "invalid IP or CIDR"},
+		{"invalid webhook URL", func(c *Config) { c.WebhookURLs = []string{"ftp://example.com"} }, "WEBHOOK_URL contains invalid URL"},
+		{"share port not tunnelled", func(c *Config) {
+			c.EnableTunnel = true
+			c.TunnelPorts = []string{"8080"}
+			c.TunnelSharePort = "5173"
+		}, "TUNNEL_SHARE_PORT"},
+		{"relative join path", func(c *Config) { c.ShareJoinPath = "join/" }, "SHARE_JOIN_PATH must start with /"},
+		{"media domain with scheme", func(c *Config) { c.AllowedMediaDomains = []string{"https://youtube.com"} }, "ALLOWED_MEDIA_DOMAINS contains invalid domain"},
+		{"auth cookie without a name", func(c *Config) {
+			c.AuthCookieSet = true
+			c.AuthCookieName = ""
+		}, "AUTH_COOKIE_NAME must be set"},
+		{"bare media domains", func(c *Config) { c.AllowedMediaDomains = []string{"youtube.com", "vimeo.com"} }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Load()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("Validate() = nil, want an error containing %q", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := Load()
+	cfg.SessionTTL = 0
+	cfg.MaxParticipants = -1
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want an error")
+	}
+	for _, want := range []string{"SESSION_TTL", "MAX_PARTICIPANTS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestValidateWebSocketTimings(t *testing.T) {
+	tests := []struct {
+		name             string
+		ping, pong, wait time.Duration
+		want             string
+	}{
+		{"proxy with 30s idle timeout", 20 * time.Second, 25 * time.Second, 5 * time.Second, ""},
+		{"ping equal to pong wait", 30 * time.Second, 30 * time.Second, 5 * time.Second, "WS_PING_INTERVAL (30s) must be less than WS_PONG_WAIT (30s)"},
+		{"ping longer than pong wait", time.Minute, 30 * time.Second, 5 * time.Second, "must be less than WS_PONG_WAIT"},
+		{"zero ping interval", 0, 30 * time.Second, 5 * time.Second, "WS_PING_INTERVAL must be positive"},
+		{"zero pong wait", 0, 0, 5 * time.Second, "WS_PONG_WAIT must be positive"},
+		{"negative write wait", 20 * time.Second, 25 * time.Second, -time.Second, "WS_WRITE_WAIT must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Load()
+			cfg.WSPingInterval, cfg.WSPongWait, cfg.WSWriteWait = tt.ping, tt.pong, tt.wait
+			err := cfg.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketTimingsFromEnv(t *testing.T) {
+	t.Setenv("WS_PING_INTERVAL", "20s")
+	t.Setenv("WS_PONG_WAIT", "25s")
+	t.Setenv("WS_WRITE_WAIT", "3s")
+
+	cfg := Load()
+	if cfg.WSPingInterval != 20*time.Second || cfg.WSPongWait != 25*time.Second || cfg.WSWriteWait != 3*time.Second {
+		t.Errorf("got ping %v, pong %v, write %v", cfg.WSPingInterval, cfg.WSPongWait, cfg.WSWriteWait)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
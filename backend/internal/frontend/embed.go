@@ -0,0 +1,20 @@
+//go:build embed
+
+package frontend
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed all:dist
+var dist embed.FS
+
+// embeddedDist returns the frontend compiled into the binary
+func embeddedDist() fs.FS {
+	files, err := fs.Sub(dist, "dist")
+	if err != nil {
+		return nil
+	}
+	return files
+}
@@ -0,0 +1,24 @@
+// Package frontend locates the built web client, either embedded in the
+// binary or in a dist folder on disk.
+//
+// Embedding is opt-in: copy the Vite build output to internal/frontend/dist
+// and build with -tags embed to produce a single self-contained binary.
+package frontend
+
+import (
+	"io/fs"
+	"os"
+)
+
+// Open returns the frontend files and a description of where they come from.
+// The embedded copy wins when the binary was built with it; otherwise
+// distPath is used if it exists. ok is false in API-only mode.
+func Open(distPath string) (files fs.FS, source string, ok bool) {
+	if embedded := embeddedDist(); embedded != nil {
+		return embedded, "embedded assets", true
+	}
+	if info, err := os.Stat(distPath); err == nil && info.IsDir() {
+		return os.DirFS(distPath), distPath, true
+	}
+	return nil, "", false
+}
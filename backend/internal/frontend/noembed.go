@@ -0,0 +1,10 @@
+//go:build !embed
+
+package frontend
+
+import "io/fs"
+
+// embeddedDist reports that no frontend was compiled into the binary
+func embeddedDist() fs.FS {
+	return nil
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"watchparty/internal/models"
+	"watchparty/internal/services"
+	ws "watchparty/pkg/websocket"
+)
+
+// AdminHandler handles operator-only endpoints
+type AdminHandler struct {
+	sessionService *services.SessionService
+	hub            *ws.Hub
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(sessionService *services.SessionService, hub *ws.Hub) *AdminHandler {
+	return &AdminHandler{
+		sessionService: sessionService,
+		hub:            hub,
+	}
+}
+
+// ListSessions handles GET /api/admin/sessions
+func (h *AdminHandler) ListSessions(c *fiber.Ctx) error {
+	sessions, err := h.sessionService.ListSessions(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to list sessions",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"sessions": sessions,
+	})
+}
+
+// BatchSessions handles POST /api/admin/sessions/batch
+func (h *AdminHandler) BatchSessions(c *fiber.Ctx) error {
+	var req models.BatchSessionsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid request body",
+		})
+	}
+
+	if errors := req.Validate(); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Details: errors,
+		})
+	}
+
+	sessions, err := h.sessionService.GetSessionSummaries(c.Context(), req.IDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to get sessions",
+		})
+	}
+
+	// Entries line up with the requested IDs; missing sessions are null
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"sessions": sessions,
+	})
+}
+
+// Metrics handles GET /api/admin/metrics
+func (h *AdminHandler) Metrics(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(h.hub.Metrics())
+}
+
+// AuditLog handles GET /api/admin/sessions/:id/audit
+func (h *AdminHandler) AuditLog(c *fiber.Ctx) error {
+	entries, err := h.sessionService.GetAuditLog(c.Context(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSessionID) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Invalid session ID format",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to get audit log",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"entries": entries,
+	})
+}
+
+// TerminateSession handles DELETE /api/admin/sessions/:id
+func (h *AdminHandler) TerminateSession(c *fiber.Ctx) error {
+	sessionID := c.Params("id")
+
+	if err := h.sessionService.TerminateSession(c.Context(), sessionID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrSessionNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Session not found",
+				Message: "The requested session doesn't exist or has expired",
+			})
+		case errors.Is(err, services.ErrInvalidSessionID):
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Invalid session ID format",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Failed to terminate session",
+			})
+		}
+	}
+
+	h.hub.CloseSession(sessionID)
+
+	return c.Status(fiber.StatusOK).JSON(models.SuccessResponse{
+		Status:  "ok",
+		Message: "Session terminated",
+	})
+}
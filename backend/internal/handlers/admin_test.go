@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"watchparty/internal/config"
+	"watchparty/internal/middleware"
+	"watchparty/internal/models"
+)
+
+const testAdminSecret = "admin-secret"
+
+// newAdminServer serves the admin batch route behind the admin secret
+func newAdminServer(t *testing.T) *testServer {
+	t.Helper()
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.AdminSecret = testAdminSecret
+	})
+	h := NewAdminHandler(s.sessions, s.hub)
+	admin := s.app.Group("/api/admin", middleware.AdminAuthMiddleware(s.cfg.AdminSecret))
+	admin.Post("/sessions/batch", h.BatchSessions)
+	return s
+}
+
+// batch posts ids to the batch endpoint with the given admin secret
+func (s *testServer) batch(t *testing.T, secret string, ids []string) (int, map[string]interface{}) {
+	t.Helper()
+	data, err := json.Marshal(models.BatchSessionsRequest{IDs: ids})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/sessions/batch", strings.NewReader(string(data)))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if secret != "" {
+		req.Header.Set("X-Admin-Secret", secret)
+	}
+	return s.send(t, req)
+}
+
+func TestBatchSessionsWithMissingIDs(t *testing.T) {
+	s := newAdminServer(t)
+	ctx := context.Background()
+	first, second, ended := s.newSession(t, "First"), s.newSession(t, "Second"), s.newSession(t, "Ended")
+	if err := s.sessions.TerminateSession(ctx, ended); err != nil {
+		t.Fatalf("TerminateSession: %v", err)
+	}
+
+	ids := []string{first, uuid.NewString(), second, ended}
+	status, body := s.batch(t, testAdminSecret, ids)
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %v", status, body)
+	}
+	sessions, ok := body["sessions"].([]interface{})
+	if !ok || len(sessions) != len(ids) {
+		t.Fatalf("sessions = %v, want %d entries", body["sessions"], len(ids))
+	}
+	for i, want := range []string{"First", "", "Second", ""} {
+		if want == "" {
+			if sessions[i] != nil {
+				t.Errorf("entry %d = %v, want null", i, sessions[i])
+			}
+			continue
+		}
+		entry, ok := sessions[i].(map[string]interface{})
+		if !ok || entry["id"] != ids[i] || entry["name"] != want {
+			t.Errorf("entry %d = %v, want session %s named %q", i, sessions[i], ids[i], want)
+		}
+	}
+}
+
+func TestBatchSessionsRejectsBadRequests(t *testing.T) {
+	s := newAdminServer(t)
+	tooMany := make([]string, models.MaxBatchSessions+1)
+	for i := range tooMany {
+		tooMany[i] = uuid.NewString()
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		ids    []string
+		want   int
+	}{
+		{"no secret", "", []string{uuid.NewString()}, http.StatusUnauthorized},
+		{"wrong secret", "guess", []string{uuid.NewString()}, http.StatusUnauthorized},
+		{"empty", testAdminSecret, nil, http.StatusBadRequest},
+		{"over the cap", testAdminSecret, tooMany, http.StatusBadRequest},
+		{"invalid ID", testAdminSecret, []string{"not-a-uuid"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, body := s.batch(t, tt.secret, tt.ids); status != tt.want {
+				t.Errorf("status %d %v, want %d", status, body, tt.want)
+			}
+		})
+	}
+}
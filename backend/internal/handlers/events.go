@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"watchparty/internal/models"
+	"watchparty/internal/services"
+)
+
+// sseHeartbeatInterval keeps idle streams open through proxies. A failed
+// heartbeat write is also how a disconnected viewer is noticed.
+const sseHeartbeatInterval = 15 * time.Second
+
+// sseEventTypes are the broadcasts relayed to SSE viewers. Chat and WebRTC
+// signaling need a two-way connection and stay WebSocket-only.
+var sseEventTypes = map[models.MessageType]bool{
+	models.MessageTypePlaybackState:      true,
+	models.MessageTypePlaybackControl:    true,
+	models.MessageTypeMediaChanged:       true,
+	models.MessageTypeUserJoined:         true,
+	models.MessageTypeUserLeft:           true,
+	models.MessageTypeHostChanged:        true,
+	models.MessageTypeControllersChanged: true,
+	models.MessageTypeSessionExtended:    true,
+	models.MessageTypeIntermission:       true,
+	models.MessageTypeIntermissionEnded:  true,
+	models.MessageTypeSyncPlay:           true,
+}
+
+// SessionEvents handles GET /api/sessions/:id/events, a read-only Server-Sent
+// Events stream of playback and presence updates for clients that cannot
+// use WebSockets
+func (h *SessionHandler) SessionEvents(c *fiber.Ctx) error {
+	sessionID := c.Params("id")
+	tokenSessionID, _ := c.Locals("sessionId").(string)
+	if sessionID == "" || tokenSessionID != sessionID {
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error:   "Forbidden",
+			Message: "You don't have access to this session",
+		})
+	}
+
+	if _, err := h.sessionService.GetSession(c.Context(), sessionID); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Session not found",
+				Message: "The requested session doesn't exist or has expired",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to get session",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		sub := h.hub.Subscribe(sessionID)
+		defer h.hub.Unsubscribe(sub)
+
+		heartbeat := time.NewTicker(sseHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		fmt.Fprint(w, "retry: 3000\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case message, ok := <-sub.C:
+				if !ok {
+					// Session ended, tokens revoked or the hub stopped
+					return
+				}
+				var envelope struct {
+					Type models.MessageType `json:"type"`
+					Seq  int64              `json:"seq"`
+				}
+				if err := json.Unmarshal(message, &envelope); err != nil || !sseEventTypes[envelope.Type] {
+					continue
+				}
+				if envelope.Seq > 0 {
+					fmt.Fprintf(w, "id: %d\n", envelope.Seq)
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", envelope.Type, message)
+
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+
+			case <-h.hub.Done():
+				// Server shutting down
+				return
+			}
+
+			if err := w.Flush(); err != nil {
+				// Client went away
+				return
+			}
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"watchparty/internal/middleware"
+	"watchparty/internal/models"
+	"watchparty/pkg/tunnel"
+)
+
+// openEvents serves the SSE route and opens a stream to a new session as
+// its host. It returns the session ID, the host's token, the response and a
+// reader already past the stream's retry preamble.
+func openEvents(t *testing.T, s *testServer) (string, string, *http.Response, *bufio.Reader) {
+	t.Helper()
+	h := NewSessionHandler(s.sessions, s.hub, tunnel.NewURLHolder("http://localhost:5173"), s.cfg)
+	s.app.Get("/api/sessions/:id/events", middleware.AuthMiddleware(s.auth, s.sessions, s.cfg.AuthCookieName), h.SessionEvents)
+	sessionID, token := s.create(t)
+	addr := s.listen(t)
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/api/sessions/"+sessionID+"/events", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		t.Fatalf("GET events: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET events: status %d", resp.StatusCode)
+	}
+
+	stream := bufio.NewReader(resp.Body)
+	if line, err := stream.ReadString('\n'); err != nil || line != "retry: 3000\n" {
+		t.Fatalf("first line = %q (%v), want the retry preamble", line, err)
+	}
+	if s.hub.SubscriberCount(sessionID) != 1 {
+		t.Fatalf("subscribers = %d, want 1", s.hub.SubscriberCount(sessionID))
+	}
+	return sessionID, token, resp, stream
+}
+
+// waitForNoSubscribers polls until the session has no subscriptions, calling
+// poke between checks
+func waitForNoSubscribers(t *testing.T, s *testServer, sessionID string, poke func()) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for s.hub.SubscriberCount(sessionID) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d subscriptions still open", s.hub.SubscriberCount(sessionID))
+		}
+		poke()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSessionEventsRelaysBroadcasts(t *testing.T) {
+	s := newSessionServer(t, nil)
+	sessionID, _, _, stream := openEvents(t, s)
+
+	s.hub.BroadcastEvent(sessionID, models.MessageTypeChat, models.ChatPayload{Message: "WebSocket only"})
+	s.hub.BroadcastEvent(sessionID, models.MessageTypeSessionExtended, struct{}{})
+
+	var event string
+	for event == "" {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		if strings.HasPrefix(line, "event: ") {
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+		}
+	}
+	if event != string(models.MessageTypeSessionExtended) {
+		t.Errorf("first event = %q, want %q with chat skipped", event, models.MessageTypeSessionExtended)
+	}
+}
+
+func TestSessionEventsDisconnectEndsSubscription(t *testing.T) {
+	s := newSessionServer(t, nil)
+	sessionID, _, resp, _ := openEvents(t, s)
+
+	resp.Body.Close()
+	// The server notices the client left when its next write fails
+	waitForNoSubscribers(t, s, sessionID, func() {
+		s.hub.BroadcastEvent(sessionID, models.MessageTypeSessionExtended, struct{}{})
+	})
+}
+
+func TestSessionEventsEndWhenHubStops(t *testing.T) {
+	s := newSessionServer(t, nil)
+	sessionID, _, _, stream := openEvents(t, s)
+
+	s.stopHub()
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, stream)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("stream ended with %v, want a clean EOF", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream still open after the hub stopped")
+	}
+	waitForNoSubscribers(t, s, sessionID, func() {})
+
+	// With the stream gone, shutdown doesn't wait on it
+	start := time.Now()
+	if err := s.app.ShutdownWithTimeout(5 * time.Second); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("shutdown took %v", elapsed)
+	}
+}
+
+func TestSessionEventsEndWhenTokensAreRevoked(t *testing.T) {
+	s := newSessionServer(t, nil)
+	sessionID, hostToken, _, stream := openEvents(t, s)
+
+	// Revocation has one-second resolution, like the tokens' issue times
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	status, body := s.do(t, http.MethodPut, "/api/sessions/"+sessionID+"/password", hostToken, map[string]string{
+		"password": "fresh-secret456",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("change password: status %d: %v", status, body)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, stream)
+		done <- err
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream opened with a revoked token is still open")
+	}
+	waitForNoSubscribers(t, s, sessionID, func() {})
+
+	// The old token can't open a new one, the reissued one can
+	if status, _ := s.do(t, http.MethodGet, "/api/sessions/"+sessionID, hostToken, nil); status != http.StatusUnauthorized {
+		t.Errorf("GET session with the revoked token: status %d, want 401", status)
+	}
+	if status, _ := s.do(t, http.MethodGet, "/api/sessions/"+sessionID, body["token"].(string), nil); status != http.StatusOK {
+		t.Errorf("GET session with the reissued token: status %d, want 200", status)
+	}
+}
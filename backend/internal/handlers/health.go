@@ -1,20 +1,79 @@
 package handlers
 
 import (
+	"context"
+	"log/slog"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"watchparty/internal/buildinfo"
+	"watchparty/internal/services"
+	ws "watchparty/pkg/websocket"
 )
 
+// readyCheckTimeout bounds how long a readiness probe waits on Redis
+const readyCheckTimeout = 2 * time.Second
+
 // HealthHandler handles health check endpoints
-type HealthHandler struct{}
+type HealthHandler struct {
+	redis *services.RedisService
+	hub   *ws.Hub
+}
 
 // NewHealthHandler creates a new health handler
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+func NewHealthHandler(redis *services.RedisService, hub *ws.Hub) *HealthHandler {
+	return &HealthHandler{
+		redis: redis,
+		hub:   hub,
+	}
 }
 
 // Health returns the health status of the server
 func (h *HealthHandler) Health(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
-		"status": "ok",
+		"status":  "ok",
+		"version": buildinfo.Get(),
 	})
 }
+
+// Live handles GET /health/live. It answers whenever the process can serve
+// requests, so a Redis outage doesn't get the server restarted.
+func (h *HealthHandler) Live(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Ready handles GET /health/ready. It responds 503 until Redis is reachable
+// and the hub is running, so traffic is routed elsewhere in the meantime.
+func (h *HealthHandler) Ready(c *fiber.Ctx) error {
+	checks := fiber.Map{"redis": "ok", "hub": "ok"}
+	ready := true
+
+	ctx, cancel := context.WithTimeout(c.Context(), readyCheckTimeout)
+	defer cancel()
+	if err := h.redis.Health(ctx); err != nil {
+		// The error may name internal addresses, so it is only logged
+		slog.Warn("Readiness check failed", "dependency", "redis", "error", err)
+		checks["redis"] = "unreachable"
+		ready = false
+	}
+	if !h.hub.Running() {
+		checks["hub"] = "not running"
+		ready = false
+	}
+
+	if !ready {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status": "unavailable",
+			"checks": checks,
+		})
+	}
+	return c.JSON(fiber.Map{
+		"status": "ready",
+		"checks": checks,
+	})
+}
+
+// Version returns the running build's version details
+func (h *HealthHandler) Version(c *fiber.Ctx) error {
+	return c.JSON(buildinfo.Get())
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"watchparty/internal/services"
+	ws "watchparty/pkg/websocket"
+)
+
+// newHealthServer serves the liveness and readiness probes for hub
+func newHealthServer(t *testing.T, hub func(s *testServer) *ws.Hub) *testServer {
+	t.Helper()
+	s := newTestServer(t, nil)
+	h := NewHealthHandler(s.redis, hub(s))
+	s.app.Get("/health/live", h.Live)
+	s.app.Get("/health/ready", h.Ready)
+
+	// Run starts in its own goroutine
+	deadline := time.Now().Add(2 * time.Second)
+	for !s.hub.Running() {
+		if time.Now().After(deadline) {
+			t.Fatal("hub never started")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return s
+}
+
+// runningHub is the test server's hub, which Run is serving
+func runningHub(s *testServer) *ws.Hub { return s.hub }
+
+func TestHealthProbesWhenHealthy(t *testing.T) {
+	s := newHealthServer(t, runningHub)
+
+	if status, body := s.do(t, http.MethodGet, "/health/live", "", nil); status != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("live: status %d %v, want 200 ok", status, body)
+	}
+	status, body := s.do(t, http.MethodGet, "/health/ready", "", nil)
+	if status != http.StatusOK || body["status"] != "ready" {
+		t.Errorf("ready: status %d %v, want 200 ready", status, body)
+	}
+}
+
+func TestHealthProbesWithRedisDown(t *testing.T) {
+	s := newHealthServer(t, runningHub)
+	s.mr.Close()
+
+	// A Redis blip must not get the process restarted
+	if status, body := s.do(t, http.MethodGet, "/health/live", "", nil); status != http.StatusOK {
+		t.Errorf("live: status %d %v, want 200", status, body)
+	}
+	status, body := s.do(t, http.MethodGet, "/health/ready", "", nil)
+	checks, _ := body["checks"].(map[string]interface{})
+	if status != http.StatusServiceUnavailable || checks["redis"] != "unreachable" || checks["hub"] != "ok" {
+		t.Errorf("ready: status %d %v, want 503 with only Redis unreachable", status, body)
+	}
+}
+
+func TestReadinessWaitsForHub(t *testing.T) {
+	s := newHealthServer(t, func(s *testServer) *ws.Hub {
+		// Built but never run
+		return ws.NewHub(s.redis, services.NewWebhookService(s.cfg), s.cfg)
+	})
+
+	if status, body := s.do(t, http.MethodGet, "/health/live", "", nil); status != http.StatusOK {
+		t.Errorf("live: status %d %v, want 200", status, body)
+	}
+	status, body := s.do(t, http.MethodGet, "/health/ready", "", nil)
+	checks, _ := body["checks"].(map[string]interface{})
+	if status != http.StatusServiceUnavailable || checks["hub"] != "not running" || checks["redis"] != "ok" {
+		t.Errorf("ready: status %d %v, want 503 with the hub not running", status, body)
+	}
+}
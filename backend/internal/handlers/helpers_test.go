@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+	"watchparty/internal/services"
+	ws "watchparty/pkg/websocket"
+)
+
+// testServer wires the services and hub to an in-memory Redis. Tests add
+// the routes they exercise to app.
+type testServer struct {
+	cfg      *config.Config
+	mr       *miniredis.Miniredis
+	redis    *services.RedisService
+	auth     *services.AuthService
+	sessions *services.SessionService
+	hub      *ws.Hub
+	app      *fiber.App
+
+	// stopHub stops the hub early, as server shutdown does
+	stopHub context.CancelFunc
+}
+
+// newTestServer builds a test server. configure, if not nil, adjusts the
+// configuration first. The hub is stopped when the test ends.
+func newTestServer(t *testing.T, configure func(*config.Config)) *testServer {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	cfg := config.Load()
+	cfg.RedisURL = mr.Addr()
+	cfg.RedisRetries = 0
+	cfg.BcryptCost = bcrypt.MinCost
+	cfg.UserLeftDebounce = 0
+	cfg.HostOfflineGrace = 0
+	cfg.EmptySessionGrace = 0
+	if configure != nil {
+		configure(cfg)
+	}
+
+	redis, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Fatalf("NewRedisService: %v", err)
+	}
+	t.Cleanup(func() { redis.Close() })
+	auth, err := services.NewAuthService(cfg)
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+	webhooks := services.NewWebhookService(cfg)
+
+	hub := ws.NewHub(redis, webhooks, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	go hub.Run(ctx)
+	t.Cleanup(func() {
+		cancel()
+		waitCtx, done := context.WithTimeout(context.Background(), 2*time.Second)
+		defer done()
+		hub.Wait(waitCtx)
+	})
+
+	return &testServer{
+		cfg:      cfg,
+		mr:       mr,
+		redis:    redis,
+		auth:     auth,
+		sessions: services.NewSessionService(redis, auth, services.NewICEService(redis, cfg), webhooks, cfg),
+		hub:      hub,
+		app:      fiber.New(fiber.Config{BodyLimit: cfg.BodyLimit}),
+		stopHub:  cancel,
+	}
+}
+
+// newSession creates a password-protected session directly through the
+// service and returns its ID
+func (s *testServer) newSession(t *testing.T, name string) string {
+	t.Helper()
+	resp, err := s.sessions.CreateSession(context.Background(), &models.CreateSessionRequest{
+		Name:     name,
+		Password: testPassword,
+	}, "http://localhost:5173", "203.0.113.7", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	return resp.ID
+}
+
+// listen serves app on a local port until the test ends and returns its
+// address
+func (s *testServer) listen(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go s.app.Listener(ln)
+	t.Cleanup(func() { s.app.ShutdownWithTimeout(time.Second) })
+	return ln.Addr().String()
+}
+
+// do sends a request to app with an optional JSON body and bearer token
+// and returns the status and decoded JSON response
+func (s *testServer) do(t *testing.T, method, path, token string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		reader = strings.NewReader(string(data))
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	if token != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+	return s.send(t, req)
+}
+
+// send sends req to app and returns the status and decoded JSON response
+func (s *testServer) send(t *testing.T, req *http.Request) (int, map[string]interface{}) {
+	t.Helper()
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	var decoded map[string]interface{}
+	if data, _ := io.ReadAll(resp.Body); len(data) > 0 {
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%s %s: invalid JSON %q: %v", req.Method, req.URL.Path, data, err)
+		}
+	}
+	return resp.StatusCode, decoded
+}
@@ -1,24 +1,69 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"watchparty/internal/config"
 	"watchparty/internal/models"
 	"watchparty/internal/services"
+	"watchparty/pkg/tunnel"
+	ws "watchparty/pkg/websocket"
 )
 
 // SessionHandler handles session-related HTTP endpoints
 type SessionHandler struct {
 	sessionService *services.SessionService
-	baseURL        string
+	hub            *ws.Hub
+	baseURL        *tunnel.URLHolder
+	config         *config.Config
 }
 
 // NewSessionHandler creates a new session handler
-func NewSessionHandler(sessionService *services.SessionService, baseURL string) *SessionHandler {
+func NewSessionHandler(sessionService *services.SessionService, hub *ws.Hub, baseURL *tunnel.URLHolder, cfg *config.Config) *SessionHandler {
 	return &SessionHandler{
 		sessionService: sessionService,
+		hub:            hub,
 		baseURL:        baseURL,
+		config:         cfg,
+	}
+}
+
+// clientCountry returns the country code the edge proxy reported for the
+// request, or "" if the header isn't present
+func (h *SessionHandler) clientCountry(c *fiber.Ctx) string {
+	return c.Get(h.config.RegionHeader)
+}
+
+// setAuthCookie hands the token to the browser as an HttpOnly cookie, if
+// AUTH_COOKIE_SET is on, so the frontend needn't keep it where scripts can
+// read it. SameSite=Strict keeps other sites from riding on the cookie.
+func (h *SessionHandler) setAuthCookie(c *fiber.Ctx, token string) {
+	if !h.config.AuthCookieSet || token == "" {
+		return
 	}
+	c.Cookie(&fiber.Cookie{
+		Name:     h.config.AuthCookieName,
+		Value:    token,
+		Path:     "/api",
+		Expires:  time.Now().Add(h.config.JWTExpiration),
+		Secure:   h.config.IsProduction(),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+}
+
+// storeUnavailable responds 503 while the session store is failing fast,
+// so clients know to retry shortly rather than treat it as a server bug
+func storeUnavailable(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderRetryAfter, "10")
+	return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+		Error:   "Service Unavailable",
+		Message: "Sessions are temporarily unavailable, please try again shortly",
+	})
 }
 
 // CreateSession handles POST /api/sessions/create
@@ -49,14 +94,44 @@ func (h *SessionHandler) CreateSession(c *fiber.Ctx) error {
 	}
 
 	// Create session
-	response, err := h.sessionService.CreateSession(c.Context(), &req, h.baseURL)
+	response, err := h.sessionService.CreateSession(c.Context(), &req, h.baseURL.Get(), c.IP(), h.clientCountry(c))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to create session",
-		})
+		if errors.Is(err, services.ErrRedisUnavailable) {
+			return storeUnavailable(c)
+		}
+		switch {
+		case errors.Is(err, services.ErrPublicSessionsDisabled):
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error:   "Forbidden",
+				Message: "Public sessions are disabled on this server",
+			})
+		case errors.Is(err, services.ErrTooManyActiveSessions):
+			return c.Status(fiber.StatusTooManyRequests).JSON(models.ErrorResponse{
+				Error:   "Too many active sessions",
+				Message: "You already have the maximum number of active sessions. End one before creating another",
+			})
+		case errors.Is(err, services.ErrRelayUnavailable):
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Relay-only sessions require a TURN server, which is not configured",
+			})
+		case errors.Is(err, services.ErrMediaURLNotAllowed):
+			return c.Status(fiber.StatusBadRequest).JSON(mediaURLNotAllowedResponse())
+		case errors.Is(err, services.ErrServerAtCapacity):
+			c.Set(fiber.HeaderRetryAfter, "60")
+			return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+				Error:   "Service Unavailable",
+				Message: "This server is hosting as many sessions as it can, please try again later",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Failed to create session",
+			})
+		}
 	}
 
+	h.setAuthCookie(c, response.Token)
 	return c.Status(fiber.StatusOK).JSON(response)
 }
 
@@ -79,25 +154,53 @@ func (h *SessionHandler) JoinSession(c *fiber.Ctx) error {
 	}
 
 	// Join session
-	response, err := h.sessionService.JoinSession(c.Context(), &req)
+	response, err := h.sessionService.JoinSession(c.Context(), &req, c.IP(), h.clientCountry(c))
 	if err != nil {
+		if errors.Is(err, services.ErrRedisUnavailable) {
+			return storeUnavailable(c)
+		}
 		// Determine error type
-		switch err.Error() {
-		case "session not found":
+		switch {
+		case errors.Is(err, services.ErrInvalidSessionID):
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Invalid session ID",
+			})
+		case errors.Is(err, services.ErrSessionNotFound):
 			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
 				Error:   "Session not found",
 				Message: "The session you're trying to join doesn't exist or has expired",
 			})
-		case "invalid password":
+		case errors.Is(err, services.ErrInvalidPassword):
 			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
 				Error:   "Authentication failed",
 				Message: "Invalid password",
 			})
-		case "session is full":
+		case errors.Is(err, services.ErrSessionExpired):
+			return c.Status(fiber.StatusGone).JSON(models.ErrorResponse{
+				Error:   "Session expired",
+				Message: "This session has ended. Ask the host for a new link",
+			})
+		case errors.Is(err, services.ErrSessionLocked):
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error:   "Session locked",
+				Message: "The host has locked this session to new participants",
+			})
+		case errors.Is(err, services.ErrTooManyFailedAttempts):
+			return c.Status(fiber.StatusTooManyRequests).JSON(models.ErrorResponse{
+				Error:   "Too many failed attempts",
+				Message: "Joining this session is temporarily blocked, please try again later",
+			})
+		case errors.Is(err, services.ErrSessionFull):
 			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
 				Error:   "Session full",
 				Message: "This session has reached the maximum number of participants",
 			})
+		case errors.Is(err, services.ErrSessionBusy):
+			return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+				Error:   "Session busy",
+				Message: "Too many people are joining at once, please try again",
+			})
 		default:
 			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 				Error:   "Internal Server Error",
@@ -106,6 +209,115 @@ func (h *SessionHandler) JoinSession(c *fiber.Ctx) error {
 		}
 	}
 
+	// Waiting-room joins are accepted but not admitted yet; ping the host
+	if response.Pending {
+		h.hub.SendEventToHost(response.ID, models.MessageTypeJoinRequest, models.JoinRequestPayload{
+			RequestID: response.RequestID,
+			Username:  response.Username,
+			Spectator: req.Spectator,
+		})
+		return c.Status(fiber.StatusAccepted).JSON(response)
+	}
+
+	h.setAuthCookie(c, response.Token)
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// GetJoinStatus handles GET /api/sessions/:id/join/:requestId, polled by
+// users waiting for the host to admit them
+func (h *SessionHandler) GetJoinStatus(c *fiber.Ctx) error {
+	response, err := h.sessionService.GetJoinStatus(c.Context(), c.Params("id"), c.Params("requestId"), c.IP(), h.clientCountry(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSessionID):
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Invalid session or request ID",
+			})
+		case errors.Is(err, services.ErrSessionNotFound) || errors.Is(err, services.ErrJoinRequestNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Not found",
+				Message: "This join request doesn't exist or has timed out",
+			})
+		case errors.Is(err, services.ErrSessionExpired):
+			return c.Status(fiber.StatusGone).JSON(models.ErrorResponse{
+				Error:   "Session expired",
+				Message: "This session has ended. Ask the host for a new link",
+			})
+		case errors.Is(err, services.ErrJoinDenied):
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error:   "Join denied",
+				Message: "The host declined your request to join",
+			})
+		case errors.Is(err, services.ErrSessionFull):
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error:   "Session full",
+				Message: "This session has reached the maximum number of participants",
+			})
+		case errors.Is(err, services.ErrSessionBusy):
+			return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+				Error:   "Session busy",
+				Message: "Too many people are joining at once, please try again",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Failed to check join request",
+			})
+		}
+	}
+
+	if response.Pending {
+		return c.Status(fiber.StatusAccepted).JSON(response)
+	}
+	h.setAuthCookie(c, response.Token)
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// SessionExists handles GET /api/sessions/:id/exists
+func (h *SessionHandler) SessionExists(c *fiber.Ctx) error {
+	response, err := h.sessionService.SessionExists(c.Context(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSessionID) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Invalid session ID format",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to look up session",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// GetIceServers handles GET /api/ice-servers
+func (h *SessionHandler) GetIceServers(c *fiber.Ctx) error {
+	sessionID, _ := c.Locals("sessionId").(string)
+
+	response, err := h.sessionService.GetIceConfig(c.Context(), sessionID, h.clientCountry(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSessionNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Session not found",
+				Message: "The requested session doesn't exist or has expired",
+			})
+		case errors.Is(err, services.ErrSessionExpired):
+			return c.Status(fiber.StatusGone).JSON(models.ErrorResponse{
+				Error:   "Session expired",
+				Message: "This session has ended",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Failed to get ICE servers",
+			})
+		}
+	}
+
 	return c.Status(fiber.StatusOK).JSON(response)
 }
 
@@ -131,7 +343,37 @@ func (h *SessionHandler) GetSession(c *fiber.Ctx) error {
 	// Get session
 	response, err := h.sessionService.GetSession(c.Context(), sessionID)
 	if err != nil {
-		if err.Error() == "session not found" {
+		switch {
+		case errors.Is(err, services.ErrInvalidSessionID):
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Invalid session ID",
+			})
+		case errors.Is(err, services.ErrSessionNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Session not found",
+				Message: "The requested session doesn't exist or has expired",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Failed to get session",
+			})
+		}
+	}
+
+	response.Spectators = h.hub.SpectatorCount(sessionID)
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// LockSession handles POST /api/sessions/:id/lock
+func (h *SessionHandler) LockSession(c *fiber.Ctx) error {
+	// Host access is enforced by HostOnlyMiddleware
+	sessionID := c.Params("id")
+
+	response, err := h.sessionService.ToggleLock(c.Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
 				Error:   "Session not found",
 				Message: "The requested session doesn't exist or has expired",
@@ -139,9 +381,352 @@ func (h *SessionHandler) GetSession(c *fiber.Ctx) error {
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error:   "Internal Server Error",
-			Message: "Failed to get session",
+			Message: "Failed to update session lock",
 		})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(response)
 }
+
+// ChangePassword handles PUT /api/sessions/:id/password
+func (h *SessionHandler) ChangePassword(c *fiber.Ctx) error {
+	// Host access is enforced by HostOnlyMiddleware
+	sessionID := c.Params("id")
+	hostID, _ := c.Locals("userId").(string)
+
+	var req models.ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid request body",
+		})
+	}
+
+	if errors := req.Validate(); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Details: errors,
+		})
+	}
+
+	response, removed, err := h.sessionService.ChangePassword(c.Context(), sessionID, hostID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSessionNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Session not found",
+				Message: "The requested session doesn't exist or has expired",
+			})
+		case errors.Is(err, services.ErrSessionHasNoPassword):
+			return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+				Error:   "Conflict",
+				Message: "Public sessions don't have a password",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Failed to change password",
+			})
+		}
+	}
+
+	// Removed viewers must join again with the new password
+	for _, userID := range removed {
+		h.hub.SignOutUser(sessionID, userID, models.CloseReasonUnauthorized, models.MessageTypePasswordChanged, struct{}{})
+	}
+	if response.TokensRevoked {
+		// Every open stream was authorized by a now revoked token
+		h.hub.EndSubscriptions(sessionID)
+	}
+
+	h.setAuthCookie(c, response.Token)
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// RotateSession handles POST /api/sessions/:id/rotate
+func (h *SessionHandler) RotateSession(c *fiber.Ctx) error {
+	// Host access is enforced by HostOnlyMiddleware
+	sessionID := c.Params("id")
+	hostID, _ := c.Locals("userId").(string)
+
+	response, tokens, err := h.sessionService.RotateSession(c.Context(), sessionID, hostID, h.baseURL.Get(), h.hub.Spectators(sessionID))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSessionNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Session not found",
+				Message: "The requested session doesn't exist or has expired",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Failed to rotate session",
+			})
+		}
+	}
+
+	// Connected clients reconnect under the new ID with re-issued tokens
+	h.hub.RotateSession(sessionID, response.ID, response.ShareURL, tokens)
+
+	h.setAuthCookie(c, response.Token)
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// Transcript handles GET /api/sessions/:id/transcript. The chat log is
+// returned as JSON, or as a plain-text download with ?format=text.
+func (h *SessionHandler) Transcript(c *fiber.Ctx) error {
+	// Host access is enforced by HostOnlyMiddleware
+	sessionID := c.Params("id")
+
+	format := c.Query("format", "json")
+	if format != "json" && format != "text" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "format must be json or text",
+		})
+	}
+
+	transcript, err := h.sessionService.GetTranscript(c.Context(), sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSessionNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Session not found",
+				Message: "The requested session doesn't exist or has expired",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Failed to get transcript",
+			})
+		}
+	}
+
+	if format == "text" {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="transcript-%s.txt"`, sessionID))
+		return c.Status(fiber.StatusOK).SendString(formatTranscript(transcript))
+	}
+	return c.Status(fiber.StatusOK).JSON(transcript)
+}
+
+// formatTranscript renders a transcript as one "[time] username: message"
+// line per message, in UTC
+func formatTranscript(transcript *models.TranscriptResponse) string {
+	var b strings.Builder
+	for _, entry := range transcript.Messages {
+		message := entry.Message
+		if entry.Encrypted {
+			message = "[encrypted]"
+		}
+		timestamp := time.UnixMilli(entry.Timestamp).UTC().Format("2006-01-02 15:04:05")
+		fmt.Fprintf(&b, "[%s] %s: %s\n", timestamp, entry.Username, message)
+	}
+	return b.String()
+}
+
+// ExtendSession handles POST /api/sessions/:id/extend
+func (h *SessionHandler) ExtendSession(c *fiber.Ctx) error {
+	// Host access is enforced by HostOnlyMiddleware
+	sessionID := c.Params("id")
+
+	response, err := h.sessionService.ExtendSession(c.Context(), sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSessionID):
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Invalid session ID",
+			})
+		case errors.Is(err, services.ErrSessionNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Session not found",
+				Message: "The requested session doesn't exist or has expired",
+			})
+		case errors.Is(err, services.ErrExtensionLimitReached):
+			return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+				Error:   "Extension limit reached",
+				Message: "This session has reached its maximum lifetime and can't be extended further",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Failed to extend session",
+			})
+		}
+	}
+
+	h.hub.BroadcastEvent(sessionID, models.MessageTypeSessionExtended, response)
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// GrantController handles PUT /api/sessions/:id/controllers/:userId
+func (h *SessionHandler) GrantController(c *fiber.Ctx) error {
+	return h.setController(c, true)
+}
+
+// RevokeController handles DELETE /api/sessions/:id/controllers/:userId
+func (h *SessionHandler) RevokeController(c *fiber.Ctx) error {
+	return h.setController(c, false)
+}
+
+func (h *SessionHandler) setController(c *fiber.Ctx, enabled bool) error {
+	// Host access is enforced by HostOnlyMiddleware
+	sessionID := c.Params("id")
+
+	response, err := h.sessionService.SetController(c.Context(), sessionID, c.Params("userId"), enabled)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSessionID):
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Invalid user ID",
+			})
+		case errors.Is(err, services.ErrSessionNotFound) || errors.Is(err, services.ErrParticipantNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Not found",
+				Message: "That user is not a participant in this session",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Failed to update playback controllers",
+			})
+		}
+	}
+
+	// Refresh connected clients' permissions and let everyone know
+	h.hub.SetControllers(sessionID, response.Controllers)
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// MuteUser handles PUT /api/sessions/:id/mutes/:userId
+func (h *SessionHandler) MuteUser(c *fiber.Ctx) error {
+	return h.setMuted(c, true)
+}
+
+// UnmuteUser handles DELETE /api/sessions/:id/mutes/:userId
+func (h *SessionHandler) UnmuteUser(c *fiber.Ctx) error {
+	return h.setMuted(c, false)
+}
+
+func (h *SessionHandler) setMuted(c *fiber.Ctx, muted bool) error {
+	// Host access is enforced by HostOnlyMiddleware
+	sessionID := c.Params("id")
+	userID := c.Params("userId")
+
+	if err := h.sessionService.SetMuted(c.Context(), sessionID, userID, muted); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSessionID):
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Invalid user ID",
+			})
+		case errors.Is(err, services.ErrCannotMuteHost):
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "The host can't be muted",
+			})
+		case errors.Is(err, services.ErrSessionNotFound) || errors.Is(err, services.ErrParticipantNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Not found",
+				Message: "That user is not a participant in this session",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Failed to update mute",
+			})
+		}
+	}
+
+	// Apply to live connections and let UIs reflect it
+	h.hub.SetMuted(sessionID, userID, muted)
+
+	return c.Status(fiber.StatusOK).JSON(models.UserMutedPayload{UserID: userID})
+}
+
+// LeaveSession handles POST /api/sessions/:id/leave
+func (h *SessionHandler) LeaveSession(c *fiber.Ctx) error {
+	sessionID := c.Params("id")
+	tokenSessionID, _ := c.Locals("sessionId").(string)
+	userID, _ := c.Locals("userId").(string)
+	if sessionID == "" || tokenSessionID != sessionID {
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error:   "Forbidden",
+			Message: "You don't have access to this session",
+		})
+	}
+
+	newHostID, err := h.sessionService.LeaveSession(c.Context(), sessionID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to leave session",
+		})
+	}
+
+	// Drop any live connections and hand over host controls if needed
+	h.hub.DisconnectUser(sessionID, userID)
+	if newHostID != "" {
+		h.hub.SetHost(sessionID, newHostID)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.SuccessResponse{
+		Status:  "ok",
+		Message: "Left session",
+	})
+}
+
+// mediaURLNotAllowedResponse explains a media URL rejected by ALLOWED_MEDIA_DOMAINS
+func mediaURLNotAllowedResponse() models.ErrorResponse {
+	return models.ErrorResponse{
+		Error:   "Validation failed",
+		Message: "This server doesn't allow media from that site",
+		Details: map[string]string{"media_url": "Media URL host is not on this server's list of allowed domains"},
+	}
+}
+
+// UpdateMedia handles PUT /api/sessions/:id/media
+func (h *SessionHandler) UpdateMedia(c *fiber.Ctx) error {
+	// Host access is enforced by HostOnlyMiddleware
+	sessionID := c.Params("id")
+
+	var req models.UpdateMediaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid request body",
+		})
+	}
+
+	if errors := req.Validate(); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Validation failed",
+			Details: errors,
+		})
+	}
+
+	media, err := h.sessionService.UpdateMedia(c.Context(), sessionID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSessionNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Session not found",
+				Message: "The requested session doesn't exist or has expired",
+			})
+		case errors.Is(err, services.ErrMediaURLNotAllowed):
+			return c.Status(fiber.StatusBadRequest).JSON(mediaURLNotAllowedResponse())
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to update media",
+		})
+	}
+
+	h.hub.BroadcastEvent(sessionID, models.MessageTypeMediaChanged, media)
+
+	return c.Status(fiber.StatusOK).JSON(media)
+}
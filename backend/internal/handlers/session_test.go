@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"watchparty/internal/config"
+	"watchparty/internal/middleware"
+	"watchparty/internal/models"
+	"watchparty/internal/utils"
+	"watchparty/pkg/tunnel"
+)
+
+const testPassword = "secret123"
+
+// newSessionServer serves the session routes the way main wires them,
+// without the rate limiters
+func newSessionServer(t *testing.T, configure func(*config.Config)) *testServer {
+	t.Helper()
+	s := newTestServer(t, configure)
+	h := NewSessionHandler(s.sessions, s.hub, tunnel.NewURLHolder("http://localhost:5173"), s.cfg)
+	sessions := s.app.Group("/api/sessions")
+	sessions.Post("/create", h.CreateSession)
+	sessions.Post("/join", h.JoinSession)
+	sessions.Get("/:id", middleware.AuthMiddleware(s.auth, s.sessions, s.cfg.AuthCookieName), h.GetSession)
+	sessions.Put("/:id/password",
+		middleware.AuthMiddleware(s.auth, s.sessions, s.cfg.AuthCookieName),
+		middleware.HostOnlyMiddleware(s.sessions),
+		h.ChangePassword,
+	)
+	sessions.Get("/:id/transcript",
+		middleware.AuthMiddleware(s.auth, s.sessions, s.cfg.AuthCookieName),
+		middleware.HostOnlyMiddleware(s.sessions),
+		h.Transcript,
+	)
+	return s
+}
+
+// create creates a session through the API and returns its ID and the
+// host's token
+func (s *testServer) create(t *testing.T) (string, string) {
+	t.Helper()
+	status, body := s.do(t, http.MethodPost, "/api/sessions/create", "", map[string]string{
+		"name":     "Movie night",
+		"password": testPassword,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("create: status %d: %v", status, body)
+	}
+	return body["id"].(string), body["token"].(string)
+}
+
+// join joins a session through the API and returns the status and response
+func (s *testServer) join(t *testing.T, sessionID string) (int, map[string]interface{}) {
+	t.Helper()
+	return s.do(t, http.MethodPost, "/api/sessions/join", "", map[string]string{
+		"session_id": sessionID,
+		"password":   testPassword,
+	})
+}
+
+func TestJoinFullSessionIsForbidden(t *testing.T) {
+	s := newSessionServer(t, func(cfg *config.Config) {
+		cfg.MaxParticipants = 2
+	})
+	sessionID, _ := s.create(t)
+
+	if status, body := s.join(t, sessionID); status != http.StatusOK {
+		t.Fatalf("first join: status %d: %v", status, body)
+	}
+	status, body := s.join(t, sessionID)
+	if status != http.StatusForbidden || body["error"] != "Session full" {
+		t.Errorf("join when full: status %d %v, want 403 Session full", status, body)
+	}
+}
+
+func TestJoinSessionErrorStatuses(t *testing.T) {
+	s := newSessionServer(t, nil)
+	ctx := context.Background()
+	open, _ := s.create(t)
+	locked, _ := s.create(t)
+	if err := s.redis.SetSessionLocked(ctx, locked, true); err != nil {
+		t.Fatalf("SetSessionLocked: %v", err)
+	}
+	ended, _ := s.create(t)
+	if err := s.sessions.TerminateSession(ctx, ended); err != nil {
+		t.Fatalf("TerminateSession: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		sessionID string
+		password  string
+		status    int
+		error     string
+	}{
+		{"missing session ID", "", testPassword, http.StatusBadRequest, "Validation failed"},
+		{"malformed session ID", "not-a-uuid", testPassword, http.StatusBadRequest, "Bad Request"},
+		{"unknown session", uuid.NewString(), testPassword, http.StatusNotFound, "Session not found"},
+		{"wrong password", open, "wrong-password", http.StatusUnauthorized, "Authentication failed"},
+		{"locked session", locked, testPassword, http.StatusForbidden, "Session locked"},
+		{"ended session", ended, testPassword, http.StatusGone, "Session expired"},
+		{"success", open, testPassword, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := s.do(t, http.MethodPost, "/api/sessions/join", "", map[string]string{
+				"session_id": tt.sessionID,
+				"password":   tt.password,
+			})
+			if status != tt.status {
+				t.Errorf("status = %d (%v), want %d", status, body, tt.status)
+			}
+			if got, _ := body["error"].(string); got != tt.error {
+				t.Errorf("error = %q, want %q", got, tt.error)
+			}
+		})
+	}
+}
+
+func TestGetSessionErrorStatuses(t *testing.T) {
+	s := newSessionServer(t, nil)
+
+	tests := []struct {
+		name      string
+		sessionID string
+		status    int
+	}{
+		{"malformed session ID", "not-a-uuid", http.StatusBadRequest},
+		{"unknown session", uuid.NewString(), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := s.auth.GenerateToken(tt.sessionID, uuid.NewString(), "Popcorn", true)
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+			if status, body := s.do(t, http.MethodGet, "/api/sessions/"+tt.sessionID, token, nil); status != tt.status {
+				t.Errorf("status = %d (%v), want %d", status, body, tt.status)
+			}
+		})
+	}
+}
+
+func TestOldPasswordFailsAfterChange(t *testing.T) {
+	s := newSessionServer(t, nil)
+	sessionID, hostToken := s.create(t)
+	const newPassword = "fresh-secret456"
+
+	status, body := s.do(t, http.MethodPut, "/api/sessions/"+sessionID+"/password", hostToken, map[string]string{
+		"password": newPassword,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("change password: status %d: %v", status, body)
+	}
+	if body["tokens_revoked"] != true || body["token"] == "" {
+		t.Errorf("response %v, want revoked tokens and a new host token", body)
+	}
+
+	if status, body := s.join(t, sessionID); status != http.StatusUnauthorized {
+		t.Errorf("join with old password: status %d (%v), want 401", status, body)
+	}
+	status, body = s.do(t, http.MethodPost, "/api/sessions/join", "", map[string]string{
+		"session_id": sessionID,
+		"password":   newPassword,
+	})
+	if status != http.StatusOK {
+		t.Errorf("join with new password: status %d (%v), want 200", status, body)
+	}
+}
+
+func TestChangePasswordIsHostOnly(t *testing.T) {
+	s := newSessionServer(t, nil)
+	sessionID, _ := s.create(t)
+	_, joined := s.join(t, sessionID)
+	viewerToken, _ := joined["token"].(string)
+
+	status, body := s.do(t, http.MethodPut, "/api/sessions/"+sessionID+"/password", viewerToken, map[string]string{
+		"password": "fresh-secret456",
+	})
+	if status != http.StatusForbidden {
+		t.Errorf("status = %d (%v), want 403", status, body)
+	}
+	if status, body := s.join(t, sessionID); status != http.StatusOK {
+		t.Errorf("join with original password: status %d (%v), want 200", status, body)
+	}
+}
+
+func TestChangePasswordReportsViolations(t *testing.T) {
+	s := newSessionServer(t, nil)
+	sessionID, hostToken := s.create(t)
+
+	status, body := s.do(t, http.MethodPut, "/api/sessions/"+sessionID+"/password", hostToken, map[string]string{
+		"password": "a",
+	})
+	details, _ := body["details"].(map[string]interface{})
+	if status != http.StatusBadRequest || body["error"] != "Validation failed" || details["password"] == nil {
+		t.Errorf("status %d %v, want 400 with the password violations", status, body)
+	}
+	if status, body := s.join(t, sessionID); status != http.StatusOK {
+		t.Errorf("join with original password: status %d (%v), want 200", status, body)
+	}
+}
+
+func TestCreateWithOversizedPasswordIsRejected(t *testing.T) {
+	s := newSessionServer(t, nil)
+
+	status, body := s.do(t, http.MethodPost, "/api/sessions/create", "", map[string]string{
+		"name":     "Movie night",
+		"password": strings.Repeat("a", utils.MaxPasswordBytes+1),
+	})
+	if status != http.StatusBadRequest || body["error"] != "Validation failed" {
+		t.Errorf("status %d %v, want 400 Validation failed", status, body)
+	}
+
+	// Bodies over the limit are refused before they are parsed
+	addr := s.listen(t)
+	oversized := `{"name":"Movie night","password":"` + strings.Repeat("a", s.cfg.BodyLimit) + `"}`
+	resp, err := http.Post("http://"+addr+"/api/sessions/create", "application/json", strings.NewReader(oversized))
+	if err != nil {
+		t.Fatalf("POST oversized body: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status %d, want 413", resp.StatusCode)
+	}
+}
+
+func TestConcurrentJoinsAtCapacityNeverFail(t *testing.T) {
+	const spots, joiners = 5, 40
+	s := newSessionServer(t, func(cfg *config.Config) {
+		cfg.MaxParticipants = spots + 1 // the host takes one place
+	})
+	sessionID, _ := s.create(t)
+	addr := s.listen(t)
+	body := `{"session_id":"` + sessionID + `","password":"` + testPassword + `"}`
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, joiners)
+	for i := 0; i < joiners; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post("http://"+addr+"/api/sessions/join", "application/json", strings.NewReader(body))
+			if err != nil {
+				t.Errorf("POST join: %v", err)
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	// Losing the race is a full session or, at worst, a retryable 503
+	for status, n := range counts {
+		if status != http.StatusOK && status != http.StatusForbidden && status != http.StatusServiceUnavailable {
+			t.Errorf("%d joins got status %d", n, status)
+		}
+	}
+	if counts[http.StatusOK] > spots {
+		t.Errorf("%d joins succeeded, want at most %d", counts[http.StatusOK], spots)
+	}
+	if counts[http.StatusServiceUnavailable] == 0 && counts[http.StatusOK] != spots {
+		t.Errorf("%d joins succeeded with no contention reported, want %d", counts[http.StatusOK], spots)
+	}
+}
+
+// saveChat stores a chat message the way the hub does
+func (s *testServer) saveChat(t *testing.T, sessionID string, chat models.ChatPayload) {
+	t.Helper()
+	payload, _ := json.Marshal(chat)
+	data, _ := json.Marshal(models.WebSocketMessage{Type: models.MessageTypeChat, SessionID: sessionID, Payload: payload})
+	if err := s.redis.SaveChatMessage(context.Background(), sessionID, data); err != nil {
+		t.Fatalf("SaveChatMessage: %v", err)
+	}
+}
+
+func TestTranscriptExport(t *testing.T) {
+	s := newSessionServer(t, nil)
+	sessionID, hostToken := s.create(t)
+	start := time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)
+	// More than the replay history keeps
+	const count = 60
+	for i := 0; i < count; i++ {
+		s.saveChat(t, sessionID, models.ChatPayload{
+			ID:        fmt.Sprintf("msg-%d", i),
+			UserID:    "user-1",
+			Username:  "Brave Otter",
+			Message:   fmt.Sprintf("message %d", i),
+			Timestamp: start.Add(time.Duration(i) * time.Second).UnixMilli(),
+		})
+	}
+	s.saveChat(t, sessionID, models.ChatPayload{
+		UserID:    "user-2",
+		Username:  "Calm Heron",
+		Message:   "c2VjcmV0",
+		Timestamp: start.Add(time.Hour).UnixMilli(),
+		Encrypted: true,
+	})
+
+	status, body := s.do(t, http.MethodGet, "/api/sessions/"+sessionID+"/transcript", hostToken, nil)
+	if status != http.StatusOK {
+		t.Fatalf("JSON export: status %d: %v", status, body)
+	}
+	messages, _ := body["messages"].([]interface{})
+	if body["id"] != sessionID || body["name"] != "Movie night" || len(messages) != count+1 {
+		t.Fatalf("JSON export has %d messages (%v), want %d for the session", len(messages), body["id"], count+1)
+	}
+	first, _ := messages[0].(map[string]interface{})
+	if first["id"] != "msg-0" || first["message"] != "message 0" || first["username"] != "Brave Otter" {
+		t.Errorf("first message = %v, want the oldest", first)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sessions/"+sessionID+"/transcript?format=text", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+hostToken)
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("text export: %v", err)
+	}
+	defer resp.Body.Close()
+	text, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get(fiber.HeaderContentType), "text/plain") {
+		t.Fatalf("text export: status %d, content type %q", resp.StatusCode, resp.Header.Get(fiber.HeaderContentType))
+	}
+	if want := `attachment; filename="transcript-` + sessionID + `.txt"`; resp.Header.Get(fiber.HeaderContentDisposition) != want {
+		t.Errorf("Content-Disposition = %q, want %q", resp.Header.Get(fiber.HeaderContentDisposition), want)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(text), "\n"), "\n")
+	if len(lines) != count+1 {
+		t.Fatalf("text export has %d lines, want %d", len(lines), count+1)
+	}
+	if want := "[2026-10-16 20:00:00] Brave Otter: message 0"; lines[0] != want {
+		t.Errorf("first line = %q, want %q", lines[0], want)
+	}
+	// Ciphertext is useless in a plain-text export
+	if want := "[2026-10-16 21:00:00] Calm Heron: [encrypted]"; lines[count] != want {
+		t.Errorf("encrypted line = %q, want %q", lines[count], want)
+	}
+
+	if status, body := s.do(t, http.MethodGet, "/api/sessions/"+sessionID+"/transcript?format=csv", hostToken, nil); status != http.StatusBadRequest {
+		t.Errorf("unknown format: status %d (%v), want 400", status, body)
+	}
+}
+
+func TestTranscriptIsHostOnly(t *testing.T) {
+	s := newSessionServer(t, nil)
+	sessionID, _ := s.create(t)
+	_, joined := s.join(t, sessionID)
+	viewerToken, _ := joined["token"].(string)
+
+	if status, body := s.do(t, http.MethodGet, "/api/sessions/"+sessionID+"/transcript", viewerToken, nil); status != http.StatusForbidden {
+		t.Errorf("viewer export: status %d (%v), want 403", status, body)
+	}
+	if status, body := s.do(t, http.MethodGet, "/api/sessions/"+sessionID+"/transcript", "", nil); status != http.StatusUnauthorized {
+		t.Errorf("anonymous export: status %d (%v), want 401", status, body)
+	}
+}
+
+func TestJoinSetsAuthCookieWhenEnabled(t *testing.T) {
+	s := newSessionServer(t, func(cfg *config.Config) {
+		cfg.AuthCookieSet = true
+	})
+	sessionID, _ := s.create(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/join",
+		strings.NewReader(`{"session_id":"`+sessionID+`","password":"`+testPassword+`"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	resp.Body.Close()
+
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == s.cfg.AuthCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatalf("join set no %s cookie", s.cfg.AuthCookieName)
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode || cookie.Path != "/api" {
+		t.Errorf("cookie HttpOnly=%v SameSite=%v Path=%q, want HttpOnly, Strict and /api", cookie.HttpOnly, cookie.SameSite, cookie.Path)
+	}
+
+	// The cookie alone authenticates
+	req = httptest.NewRequest(http.MethodGet, "/api/sessions/"+sessionID, nil)
+	req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	if status, body := s.send(t, req); status != http.StatusOK {
+		t.Errorf("GET with cookie: status %d (%v), want 200", status, body)
+	}
+}
+
+func TestAuthCookieIsOffByDefault(t *testing.T) {
+	s := newSessionServer(t, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/create",
+		strings.NewReader(`{"name":"Movie night","password":"`+testPassword+`"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	resp.Body.Close()
+	if cookies := resp.Cookies(); len(cookies) != 0 {
+		t.Errorf("create set cookies %v with AUTH_COOKIE_SET off", cookies)
+	}
+}
+
+func TestCreateBeyondServerCapacityIsUnavailable(t *testing.T) {
+	s := newSessionServer(t, func(cfg *config.Config) {
+		cfg.MaxTotalSessions = 1
+	})
+	s.create(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/create",
+		strings.NewReader(`{"name":"Movie night","password":"`+testPassword+`"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get(fiber.HeaderRetryAfter) == "" {
+		t.Errorf("status %d, Retry-After %q, want 503 with Retry-After", resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+}
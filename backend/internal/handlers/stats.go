@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+	"watchparty/internal/services"
+	ws "watchparty/pkg/websocket"
+)
+
+// StatsHandler serves aggregate usage numbers. Counting sessions scans
+// Redis, so results are cached for StatsCacheTTL.
+type StatsHandler struct {
+	sessionService *services.SessionService
+	hub            *ws.Hub
+	cacheTTL       time.Duration
+
+	mu       sync.Mutex
+	cached   *models.StatsResponse
+	cachedAt time.Time
+}
+
+// NewStatsHandler creates a new stats handler
+func NewStatsHandler(sessionService *services.SessionService, hub *ws.Hub, cfg *config.Config) *StatsHandler {
+	return &StatsHandler{
+		sessionService: sessionService,
+		hub:            hub,
+		cacheTTL:       cfg.StatsCacheTTL,
+	}
+}
+
+// Stats handles GET /api/stats
+func (h *StatsHandler) Stats(c *fiber.Ctx) error {
+	// Holding the lock while counting means concurrent requests after
+	// expiry wait for one scan instead of each starting their own
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cached == nil || time.Since(h.cachedAt) >= h.cacheTTL {
+		sessions, err := h.sessionService.CountSessions(c.Context())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Failed to get stats",
+			})
+		}
+
+		now := time.Now()
+		h.cached = &models.StatsResponse{
+			ActiveSessions:    sessions,
+			ConnectedClients:  h.hub.ConnectedClients(),
+			MessagesProcessed: h.hub.ProcessedMessages(),
+			GeneratedAt:       now.UTC().Format(time.RFC3339),
+		}
+		h.cachedAt = now
+	}
+
+	return c.Status(fiber.StatusOK).JSON(h.cached)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"watchparty/internal/config"
+)
+
+func TestStatsWithActiveSessions(t *testing.T) {
+	s, baseURL := newWebSocketServer(t, func(cfg *config.Config) {
+		cfg.StatsCacheTTL = time.Hour
+	})
+	s.app.Get("/api/stats", NewStatsHandler(s.sessions, s.hub, s.cfg).Stats)
+
+	first, second := s.newSession(t, "First"), s.newSession(t, "Second")
+	chatAs(t, dialAs(t, s, baseURL, first, uuid.NewString()))
+	chatAs(t, dialAs(t, s, baseURL, first, uuid.NewString()))
+	chatAs(t, dialAs(t, s, baseURL, second, uuid.NewString()))
+
+	status, body := s.do(t, http.MethodGet, "/api/stats", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %v", status, body)
+	}
+	want := map[string]float64{"active_sessions": 2, "connected_clients": 3, "messages_processed": 3}
+	for field, n := range want {
+		if body[field] != n {
+			t.Errorf("%s = %v, want %v", field, body[field], n)
+		}
+	}
+
+	// Within the cache TTL the counts are reused rather than rescanned
+	s.newSession(t, "Third")
+	if _, cached := s.do(t, http.MethodGet, "/api/stats", "", nil); cached["active_sessions"] != want["active_sessions"] || cached["generated_at"] != body["generated_at"] {
+		t.Errorf("second request got %v, want the cached %v", cached, body)
+	}
+}
+
+func TestStatsAreRecountedAfterCacheTTL(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.StatsCacheTTL = time.Millisecond
+	})
+	s.app.Get("/api/stats", NewStatsHandler(s.sessions, s.hub, s.cfg).Stats)
+
+	if _, body := s.do(t, http.MethodGet, "/api/stats", "", nil); body["active_sessions"] != float64(0) {
+		t.Fatalf("active_sessions = %v, want 0", body["active_sessions"])
+	}
+	s.newSession(t, "Movie night")
+	time.Sleep(5 * time.Millisecond)
+	if _, body := s.do(t, http.MethodGet, "/api/stats", "", nil); body["active_sessions"] != float64(1) {
+		t.Errorf("active_sessions = %v, want 1 once the cache expired", body["active_sessions"])
+	}
+}
@@ -1,25 +1,50 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
+	"watchparty/internal/config"
 	"watchparty/internal/services"
 	ws "watchparty/pkg/websocket"
 )
 
+// wsAuthProtocol is the subprotocol clients offer alongside their JWT in
+// Sec-WebSocket-Protocol, e.g. "watchparty.v1, <token>". Only a protocol
+// name is echoed back on upgrade, never the token. Versioned names select
+// the message format; the bare name predates versioning and means v1.
+const wsAuthProtocol = "watchparty"
+
+// wsProtocols lists the subprotocols the server speaks, most preferred
+// first, with the message format version each selects
+var wsProtocols = []struct {
+	name    string
+	version int
+}{
+	{"watchparty.v1", 1},
+	{wsAuthProtocol, 1},
+}
+
+// wsLegacyVersion is assumed for clients that pass their token in the query
+// string and offer no subprotocol
+const wsLegacyVersion = 1
+
 // WebSocketHandler handles WebSocket connections
 type WebSocketHandler struct {
 	hub         *ws.Hub
 	authService *services.AuthService
+	config      *config.Config
 }
 
 // NewWebSocketHandler creates a new WebSocket handler
-func NewWebSocketHandler(hub *ws.Hub, authService *services.AuthService) *WebSocketHandler {
+func NewWebSocketHandler(hub *ws.Hub, authService *services.AuthService, cfg *config.Config) *WebSocketHandler {
 	return &WebSocketHandler{
 		hub:         hub,
 		authService: authService,
+		config:      cfg,
 	}
 }
 
@@ -27,8 +52,23 @@ func NewWebSocketHandler(hub *ws.Hub, authService *services.AuthService) *WebSoc
 func (h *WebSocketHandler) UpgradeMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
-			// Validate token before upgrade
-			token := c.Query("token")
+			// Validate token before upgrade. Prefer the subprotocol header,
+			// which stays out of URLs and logs; the query param is kept for
+			// older clients.
+			offered, token := parseProtocols(c.Get(fiber.HeaderSecWebSocketProtocol))
+			version := wsLegacyVersion
+			if len(offered) > 0 {
+				var ok bool
+				if version, ok = negotiateVersion(offered); !ok {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"error":   "Bad Request",
+						"message": "Unsupported protocol version",
+					})
+				}
+			}
+			if token == "" {
+				token = c.Query("token")
+			}
 			if token == "" {
 				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 					"error":   "Unauthorized",
@@ -53,11 +93,21 @@ func (h *WebSocketHandler) UpgradeMiddleware() fiber.Handler {
 				})
 			}
 
+			// Tokens from before a password change no longer grant access
+			if h.hub.TokenRevoked(sessionID, claims.Issued()) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error":   "Unauthorized",
+					"message": "Token has been revoked, please join again",
+				})
+			}
+
 			// Store claims in locals for handler
 			c.Locals("sessionId", claims.SessionID)
 			c.Locals("userId", claims.UserID)
 			c.Locals("username", claims.Username)
 			c.Locals("isHost", claims.IsHost)
+			c.Locals("isSpectator", claims.IsSpectator)
+			c.Locals("protocolVersion", version)
 
 			return c.Next()
 		}
@@ -65,6 +115,55 @@ func (h *WebSocketHandler) UpgradeMiddleware() fiber.Handler {
 	}
 }
 
+// parseProtocols splits a Sec-WebSocket-Protocol header of the form
+// "watchparty.v1, <token>" into the watchparty protocol names offered and
+// the JWT. The token is "" if no watchparty protocol was offered.
+func parseProtocols(header string) (offered []string, token string) {
+	for _, protocol := range strings.Split(header, ",") {
+		protocol = strings.TrimSpace(protocol)
+		switch {
+		case protocol == wsAuthProtocol || strings.HasPrefix(protocol, wsAuthProtocol+"."):
+			offered = append(offered, protocol)
+		case protocol != "" && token == "":
+			token = protocol
+		}
+	}
+	if len(offered) == 0 {
+		return nil, ""
+	}
+	return offered, token
+}
+
+// negotiateVersion picks the server's most preferred protocol among those
+// offered, matching how the upgrader chooses the one it echoes back. It
+// reports false if none is supported.
+func negotiateVersion(offered []string) (int, bool) {
+	for _, p := range wsProtocols {
+		for _, o := range offered {
+			if o == p.name {
+				return p.version, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// subprotocolNames returns the supported subprotocols in preference order
+func subprotocolNames() []string {
+	names := make([]string, len(wsProtocols))
+	for i, p := range wsProtocols {
+		names[i] = p.name
+	}
+	return names
+}
+
+// refuseConnection closes an upgraded connection that won't be served,
+// with the reason in the close frame
+func refuseConnection(c *websocket.Conn, code int, reason string, writeWait time.Duration) {
+	c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+	c.Close()
+}
+
 // HandleWebSocket handles WebSocket connections
 func (h *WebSocketHandler) HandleWebSocket() fiber.Handler {
 	return websocket.New(func(c *websocket.Conn) {
@@ -73,16 +172,42 @@ func (h *WebSocketHandler) HandleWebSocket() fiber.Handler {
 		username := c.Locals("username").(string)
 		isHost := c.Locals("isHost").(bool)
 
-		log.Printf("WebSocket connection: session=%s user=%s isHost=%v", sessionID, userID, isHost)
+		slog.Info("WebSocket connection", "session_id", sessionID, "user_id", userID, "is_host", isHost)
 
 		// Create client
-		client := ws.NewClient(c, h.hub, sessionID, userID, username, isHost)
+		client := ws.NewClient(c, h.hub, sessionID, userID, username, isHost, h.config.WSSendBuffer)
+		client.ProtocolVersion = c.Locals("protocolVersion").(int)
+		client.IsSpectator = c.Locals("isSpectator").(bool)
+
+		// Refuse connections over the per-session or per-user cap. The
+		// upgrade has already happened, so the reason goes in the close
+		// frame where browsers can read it.
+		if err := h.hub.ReserveConnection(client); err != nil {
+			slog.Warn("WebSocket connection refused", "session_id", sessionID, "user_id", userID, "reason", err)
+			refuseConnection(c, websocket.ClosePolicyViolation, err.Error(), h.config.WSWriteWait)
+			return
+		}
 
 		// Register client
-		h.hub.Register(client)
+		if !h.hub.Register(client) {
+			h.hub.ReleaseConnection(client)
+			refuseConnection(c, websocket.CloseGoingAway, "server shutting down", h.config.WSWriteWait)
+			return
+		}
 
 		// Start read/write pumps
 		go client.WritePump()
 		client.ReadPump() // This blocks until connection closes
+	}, websocket.Config{
+		// permessage-deflate shrinks SDP blobs and chat history replay
+		// considerably, which matters on mobile links, at the cost of extra
+		// CPU per message on both ends. Only "no context takeover" is
+		// supported, so each message is compressed independently and no
+		// per-connection compression state is kept between messages.
+		// WS_MAX_MESSAGE_SIZE applies to the decompressed payload, so a
+		// small compressed frame can't expand past the limit.
+		EnableCompression: h.config.WSCompression,
+		// Echo the negotiated protocol so browsers accept the upgrade
+		Subprotocols: subprotocolNames(),
 	})
 }
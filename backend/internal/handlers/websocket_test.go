@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/google/uuid"
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+	"watchparty/internal/services"
+)
+
+// newWebSocketServer serves the WebSocket route and returns its base URL.
+// configure, if not nil, adjusts the configuration first.
+func newWebSocketServer(t *testing.T, configure func(*config.Config)) (*testServer, string) {
+	t.Helper()
+	s := newTestServer(t, configure)
+	wsHandler := NewWebSocketHandler(s.hub, s.auth, s.cfg)
+	s.app.Use("/ws/:sessionId", wsHandler.UpgradeMiddleware())
+	s.app.Get("/ws/:sessionId", wsHandler.HandleWebSocket())
+	return s, "ws://" + s.listen(t) + "/ws/"
+}
+
+// chatAs sends a chat message over conn and returns the user the server
+// attributed the echoed message to
+func chatAs(t *testing.T, conn *fastws.Conn) string {
+	t.Helper()
+	payload, _ := json.Marshal(models.ChatPayload{Message: "hello"})
+	if err := conn.WriteJSON(models.WebSocketMessage{Type: models.MessageTypeChat, Payload: payload}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg models.WebSocketMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if msg.Type != models.MessageTypeChat {
+			continue
+		}
+		var chat models.ChatPayload
+		if err := json.Unmarshal(msg.Payload, &chat); err != nil {
+			t.Fatalf("chat payload: %v", err)
+		}
+		return chat.UserID
+	}
+}
+
+func TestWebSocketAuthViaSubprotocol(t *testing.T) {
+	s, baseURL := newWebSocketServer(t, nil)
+	sessionID, userID := uuid.NewString(), uuid.NewString()
+	token, err := s.auth.GenerateToken(sessionID, userID, "Popcorn", true)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	dialer := fastws.Dialer{Subprotocols: []string{"watchparty.v1", token}}
+	conn, resp, err := dialer.Dial(baseURL+sessionID, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Only the protocol name is echoed, never the token
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "watchparty.v1" {
+		t.Errorf("accepted subprotocol = %q, want %q", got, "watchparty.v1")
+	}
+	if got := chatAs(t, conn); got != userID {
+		t.Errorf("chat attributed to %q, want %q", got, userID)
+	}
+}
+
+func TestWebSocketAuthViaQueryParam(t *testing.T) {
+	s, baseURL := newWebSocketServer(t, nil)
+	sessionID, userID := uuid.NewString(), uuid.NewString()
+	token, err := s.auth.GenerateToken(sessionID, userID, "Popcorn", true)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	conn, resp, err := fastws.DefaultDialer.Dial(baseURL+sessionID+"?token="+token, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "" {
+		t.Errorf("accepted subprotocol = %q, want none", got)
+	}
+	if got := chatAs(t, conn); got != userID {
+		t.Errorf("chat attributed to %q, want %q", got, userID)
+	}
+}
+
+func TestWebSocketAuthFailures(t *testing.T) {
+	s, baseURL := newWebSocketServer(t, nil)
+	sessionID := uuid.NewString()
+	token, err := s.auth.GenerateToken(sessionID, uuid.NewString(), "Popcorn", false)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		protocols []string
+		want      int
+	}{
+		{"no token", sessionID, nil, http.StatusUnauthorized},
+		{"protocol without token", sessionID, []string{"watchparty.v1"}, http.StatusUnauthorized},
+		{"invalid token in protocol", sessionID, []string{"watchparty.v1", "not-a-jwt"}, http.StatusUnauthorized},
+		{"unsupported version", sessionID, []string{"watchparty.v9", token}, http.StatusBadRequest},
+		{"token for another session", uuid.NewString(), []string{"watchparty.v1", token}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := fastws.Dialer{Subprotocols: tt.protocols}
+			conn, resp, err := dialer.Dial(baseURL+tt.path, nil)
+			if err == nil {
+				conn.Close()
+				t.Fatal("upgrade succeeded")
+			}
+			if resp == nil || resp.StatusCode != tt.want {
+				t.Errorf("response = %v, want status %d", resp, tt.want)
+			}
+		})
+	}
+}
+
+// dialAs opens a WebSocket connection for a new token for userID
+func dialAs(t *testing.T, s *testServer, baseURL, sessionID, userID string) *fastws.Conn {
+	t.Helper()
+	token, err := s.auth.GenerateToken(sessionID, userID, "Popcorn", false)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	dialer := fastws.Dialer{Subprotocols: []string{"watchparty.v1", token}}
+	conn, _, err := dialer.Dial(baseURL+sessionID, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// expectRefused fails the test unless the server closes conn with a policy
+// violation naming the limit
+func expectRefused(t *testing.T, conn *fastws.Conn, reason string) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := conn.ReadMessage()
+	var closeErr *fastws.CloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatalf("read = %v, want a close frame", err)
+	}
+	if closeErr.Code != fastws.ClosePolicyViolation || closeErr.Text != reason {
+		t.Errorf("close = %d %q, want %d %q", closeErr.Code, closeErr.Text, fastws.ClosePolicyViolation, reason)
+	}
+}
+
+func TestConnectionOverSessionLimitIsRefused(t *testing.T) {
+	s, baseURL := newWebSocketServer(t, func(cfg *config.Config) {
+		cfg.WSMaxConnectionsPerSession = 2
+	})
+	sessionID := uuid.NewString()
+
+	first := dialAs(t, s, baseURL, sessionID, uuid.NewString())
+	chatAs(t, first)
+	chatAs(t, dialAs(t, s, baseURL, sessionID, uuid.NewString()))
+
+	expectRefused(t, dialAs(t, s, baseURL, sessionID, uuid.NewString()), services.ErrSessionConnectionLimit.Error())
+
+	// Other sessions are unaffected
+	chatAs(t, dialAs(t, s, baseURL, uuid.NewString(), uuid.NewString()))
+
+	// A slot frees up once a closed connection is released
+	first.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if count, err := s.redis.GetConnectionCount(context.Background(), sessionID); err == nil && count <= 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("closed connection was never released")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	chatAs(t, dialAs(t, s, baseURL, sessionID, uuid.NewString()))
+}
+
+func TestConnectionOverUserLimitIsRefused(t *testing.T) {
+	s, baseURL := newWebSocketServer(t, func(cfg *config.Config) {
+		cfg.WSMaxConnectionsPerUser = 2
+	})
+	sessionID, userID := uuid.NewString(), uuid.NewString()
+
+	chatAs(t, dialAs(t, s, baseURL, sessionID, userID))
+	chatAs(t, dialAs(t, s, baseURL, sessionID, userID))
+
+	expectRefused(t, dialAs(t, s, baseURL, sessionID, userID), services.ErrUserConnectionLimit.Error())
+	chatAs(t, dialAs(t, s, baseURL, sessionID, uuid.NewString()))
+}
+
+func TestWebSocketSubprotocolNegotiation(t *testing.T) {
+	s, baseURL := newWebSocketServer(t, nil)
+
+	tests := []struct {
+		name      string
+		protocols func(token string) []string
+		accepted  string
+		status    int
+	}{
+		{"v1", func(token string) []string { return []string{"watchparty.v1", token} }, "watchparty.v1", 0},
+		{"unversioned means v1", func(token string) []string { return []string{"watchparty", token} }, "watchparty", 0},
+		{"known version preferred", func(token string) []string { return []string{"watchparty.v7", "watchparty.v1", token} }, "watchparty.v1", 0},
+		{"unknown version only", func(token string) []string { return []string{"watchparty.v2", token} }, "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionID := uuid.NewString()
+			token, err := s.auth.GenerateToken(sessionID, uuid.NewString(), "Popcorn", true)
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+			dialer := fastws.Dialer{Subprotocols: tt.protocols(token)}
+			conn, resp, err := dialer.Dial(baseURL+sessionID, nil)
+			if tt.status != 0 {
+				if err == nil {
+					conn.Close()
+					t.Fatal("upgrade succeeded")
+				}
+				if resp == nil || resp.StatusCode != tt.status {
+					t.Errorf("response = %v, want status %d", resp, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+
+			if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != tt.accepted {
+				t.Errorf("accepted subprotocol = %q, want %q", got, tt.accepted)
+			}
+			chatAs(t, conn)
+			clients := s.hub.GetSessionClients(sessionID)
+			if len(clients) != 1 || clients[0].ProtocolVersion != 1 {
+				t.Errorf("clients = %v, want one speaking version 1", clients)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Init configures the default slog logger. level is one of debug, info,
+// warn or error; format is "json" for JSON output, anything else for text.
+// The standard log package is routed through the same handler.
+func Init(level, format string) {
+	opts := &slog.HandlerOptions{Level: parseLevel(level)}
+
+	var handler slog.Handler
+	if strings.ToLower(format) == "json" {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
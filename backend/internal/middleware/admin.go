@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminAuthMiddleware restricts a route to operators presenting the admin
+// secret in the X-Admin-Secret header. If no secret is configured, admin
+// routes are disabled entirely.
+func AdminAuthMiddleware(adminSecret string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if adminSecret == "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   "Forbidden",
+				"message": "Admin access is not configured",
+			})
+		}
+
+		provided := c.Get("X-Admin-Secret")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(adminSecret)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":   "Unauthorized",
+				"message": "Invalid admin secret",
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -7,35 +7,55 @@ import (
 	"watchparty/internal/services"
 )
 
-// AuthMiddleware creates a middleware that validates JWT tokens
-func AuthMiddleware(auth *services.AuthService) fiber.Handler {
+// AuthMiddleware creates a middleware that validates JWT tokens. The token
+// comes from the Authorization header or, when that is absent, from the
+// cookie named cookieName ("" disables the cookie). Tokens revoked by a
+// password change are rejected.
+func AuthMiddleware(auth *services.AuthService, sessionService *services.SessionService, cookieName string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Get authorization header
 		authHeader := c.Get("Authorization")
+		var tokenString string
 		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error":   "Unauthorized",
-				"message": "Missing authorization header",
-			})
+			tokenString = cookieToken(c, cookieName)
+			if tokenString == "" {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error":   "Unauthorized",
+					"message": "Missing authorization header",
+				})
+			}
+		} else {
+			// Check Bearer prefix
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error":   "Unauthorized",
+					"message": "Invalid authorization header format",
+				})
+			}
+			tokenString = parts[1]
 		}
 
-		// Check Bearer prefix
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		// Validate token
+		claims, err := auth.ValidateToken(tokenString)
+		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error":   "Unauthorized",
-				"message": "Invalid authorization header format",
+				"message": "Invalid or expired token",
 			})
 		}
 
-		tokenString := parts[1]
-
-		// Validate token
-		claims, err := auth.ValidateToken(tokenString)
+		revoked, err := sessionService.TokenRevoked(c.Context(), claims.SessionID, claims.Issued())
 		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "Internal Server Error",
+				"message": "Failed to verify token",
+			})
+		}
+		if revoked {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error":   "Unauthorized",
-				"message": "Invalid or expired token",
+				"message": "Token has been revoked",
 			})
 		}
 
@@ -49,20 +69,21 @@ func AuthMiddleware(auth *services.AuthService) fiber.Handler {
 	}
 }
 
-// OptionalAuthMiddleware creates a middleware that validates JWT tokens but doesn't require them
-func OptionalAuthMiddleware(auth *services.AuthService) fiber.Handler {
+// OptionalAuthMiddleware creates a middleware that validates JWT tokens but
+// doesn't require them. Tokens are looked up as in AuthMiddleware.
+func OptionalAuthMiddleware(auth *services.AuthService, cookieName string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
+		var tokenString string
 		if authHeader == "" {
-			return c.Next()
+			tokenString = cookieToken(c, cookieName)
+		} else if parts := strings.Split(authHeader, " "); len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+			tokenString = parts[1]
 		}
-
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		if tokenString == "" {
 			return c.Next()
 		}
 
-		tokenString := parts[1]
 		claims, err := auth.ValidateToken(tokenString)
 		if err != nil {
 			return c.Next()
@@ -77,3 +98,12 @@ func OptionalAuthMiddleware(auth *services.AuthService) fiber.Handler {
 		return c.Next()
 	}
 }
+
+// cookieToken returns the token from the auth cookie, or "" if the cookie
+// is disabled or not sent
+func cookieToken(c *fiber.Ctx, cookieName string) string {
+	if cookieName == "" {
+		return ""
+	}
+	return c.Cookies(cookieName)
+}
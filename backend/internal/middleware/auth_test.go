@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+	"watchparty/internal/services"
+)
+
+const testCookieName = "watchparty_token"
+
+// newAuthApp serves a route behind handler that echoes the authenticated
+// user ID, or "anonymous" when there is none
+func newAuthApp(handler fiber.Handler) *fiber.App {
+	app := fiber.New()
+	app.Get("/me", handler, func(c *fiber.Ctx) error {
+		if userID, ok := c.Locals("userId").(string); ok {
+			return c.SendString(userID)
+		}
+		return c.SendString("anonymous")
+	})
+	return app
+}
+
+// newTestAuth returns an auth service, a session service backed by an
+// in-memory Redis and a valid token for user-1
+func newTestAuth(t *testing.T) (*services.AuthService, *services.SessionService, string) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	cfg := config.Load()
+	cfg.RedisURL = mr.Addr()
+	cfg.RedisRetries = 0
+	cfg.BcryptCost = bcrypt.MinCost
+
+	redis, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Fatalf("NewRedisService: %v", err)
+	}
+	t.Cleanup(func() { redis.Close() })
+	auth, err := services.NewAuthService(cfg)
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+	sessions := services.NewSessionService(redis, auth, services.NewICEService(redis, cfg), services.NewWebhookService(cfg), cfg)
+
+	token, err := auth.GenerateToken("session-1", "user-1", "Brave Otter", false)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return auth, sessions, token
+}
+
+// getMe sends GET /me with the given Authorization header and auth cookie,
+// either of which may be empty, and returns the status and body
+func getMe(t *testing.T, app *fiber.App, header, cookie string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	if header != "" {
+		req.Header.Set(fiber.HeaderAuthorization, header)
+	}
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: testCookieName, Value: cookie})
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("GET /me: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestAuthMiddlewareReadsCookie(t *testing.T) {
+	auth, sessions, token := newTestAuth(t)
+	app := newAuthApp(AuthMiddleware(auth, sessions, testCookieName))
+
+	tests := []struct {
+		name   string
+		header string
+		cookie string
+		want   int
+	}{
+		{"header", "Bearer " + token, "", fiber.StatusOK},
+		{"cookie", "", token, fiber.StatusOK},
+		// The header wins, so a bad one isn't rescued by the cookie
+		{"invalid header with valid cookie", "Bearer not-a-token", token, fiber.StatusUnauthorized},
+		{"invalid cookie", "", "not-a-token", fiber.StatusUnauthorized},
+		{"neither", "", "", fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := getMe(t, app, tt.header, tt.cookie)
+			if status != tt.want {
+				t.Errorf("status = %d (%s), want %d", status, body, tt.want)
+			}
+			if tt.want == fiber.StatusOK && body != "user-1" {
+				t.Errorf("authenticated as %q, want user-1", body)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareRejectsRevokedToken(t *testing.T) {
+	auth, sessions, _ := newTestAuth(t)
+	app := newAuthApp(AuthMiddleware(auth, sessions, testCookieName))
+	ctx := context.Background()
+	created, err := sessions.CreateSession(ctx, &models.CreateSessionRequest{
+		Name:     "Movie night",
+		Password: "secret123",
+	}, "http://localhost:5173", "203.0.113.7", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	claims, err := auth.ValidateToken(created.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	// Revocation has one-second resolution, like the tokens' issue times
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	changed, _, err := sessions.ChangePassword(ctx, created.ID, claims.UserID, &models.ChangePasswordRequest{Password: "fresh-secret456"})
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+
+	if status, body := getMe(t, app, "Bearer "+created.Token, ""); status != fiber.StatusUnauthorized {
+		t.Errorf("revoked token: status %d (%s), want 401", status, body)
+	}
+	if status, body := getMe(t, app, "", created.Token); status != fiber.StatusUnauthorized {
+		t.Errorf("revoked cookie: status %d (%s), want 401", status, body)
+	}
+	if status, body := getMe(t, app, "Bearer "+changed.Token, ""); status != fiber.StatusOK || body != claims.UserID {
+		t.Errorf("reissued token: got %d %q, want 200 %q", status, body, claims.UserID)
+	}
+}
+
+func TestAuthMiddlewareCookieCanBeDisabled(t *testing.T) {
+	auth, sessions, token := newTestAuth(t)
+	app := newAuthApp(AuthMiddleware(auth, sessions, ""))
+
+	if status, _ := getMe(t, app, "", token); status != fiber.StatusUnauthorized {
+		t.Errorf("cookie with cookie auth disabled: status %d, want 401", status)
+	}
+}
+
+func TestOptionalAuthMiddlewareReadsCookie(t *testing.T) {
+	auth, _, token := newTestAuth(t)
+	app := newAuthApp(OptionalAuthMiddleware(auth, testCookieName))
+
+	tests := []struct {
+		name   string
+		cookie string
+		want   string
+	}{
+		{"valid cookie", token, "user-1"},
+		{"invalid cookie", "not-a-token", "anonymous"},
+		{"no cookie", "", "anonymous"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := getMe(t, app, "", tt.cookie)
+			if status != fiber.StatusOK || body != tt.want {
+				t.Errorf("got %d %q, want 200 %q", status, body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireJSON rejects requests whose Content-Type isn't application/json
+// (parameters such as charset are allowed) with 415 Unsupported Media Type,
+// rather than letting the body parser fail with a vaguer error
+func RequireJSON() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !c.Is("json") {
+			return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+				"error":   "Unsupported Media Type",
+				"message": "Request body must be JSON with Content-Type: application/json",
+			})
+		}
+
+		return c.Next()
+	}
+}
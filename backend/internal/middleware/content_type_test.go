@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireJSON(t *testing.T) {
+	app := fiber.New()
+	app.Post("/api/sessions/create", RequireJSON(), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"json", "application/json", `{"name":"Movie night"}`, fiber.StatusCreated},
+		{"json with charset", "application/json; charset=utf-8", `{"name":"Movie night"}`, fiber.StatusCreated},
+		{"form encoded", "application/x-www-form-urlencoded", "name=Movie+night", fiber.StatusUnsupportedMediaType},
+		{"plain text", "text/plain", `{"name":"Movie night"}`, fiber.StatusUnsupportedMediaType},
+		{"missing", "", `{"name":"Movie night"}`, fiber.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodPost, "/api/sessions/create", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set(fiber.HeaderContentType, tt.contentType)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
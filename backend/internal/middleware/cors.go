@@ -1,25 +1,20 @@
 package middleware
 
 import (
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
-// CORSMiddleware creates a CORS middleware with the specified origins
-func CORSMiddleware(allowedOrigins []string) fiber.Handler {
-	originsStr := ""
-	for i, origin := range allowedOrigins {
-		if i > 0 {
-			originsStr += ","
-		}
-		originsStr += origin
-	}
-
+// CORSMiddleware creates a CORS middleware with the specified origins,
+// methods and headers
+func CORSMiddleware(allowedOrigins, allowedMethods, allowedHeaders []string, allowCredentials bool) fiber.Handler {
 	return cors.New(cors.Config{
-		AllowOrigins:     originsStr,
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
-		AllowCredentials: true,
+		AllowOrigins:     strings.Join(allowedOrigins, ","),
+		AllowMethods:     strings.Join(allowedMethods, ","),
+		AllowHeaders:     strings.Join(allowedHeaders, ","),
+		AllowCredentials: allowCredentials,
 		MaxAge:           86400, // 24 hours
 	})
 }
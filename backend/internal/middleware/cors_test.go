@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// preflight sends a CORS preflight request from origin and returns the
+// response headers
+func preflight(t *testing.T, app *fiber.App, origin string) http.Header {
+	t.Helper()
+	req := httptest.NewRequest(fiber.MethodOptions, "/api/sessions", nil)
+	req.Header.Set(fiber.HeaderOrigin, origin)
+	req.Header.Set(fiber.HeaderAccessControlRequestMethod, fiber.MethodPatch)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("preflight: %v", err)
+	}
+	resp.Body.Close()
+	return resp.Header
+}
+
+func TestCORSWithCredentialsEchoesListedOrigins(t *testing.T) {
+	app := fiber.New()
+	app.Use(CORSMiddleware(
+		[]string{"http://localhost:5173", "https://watch.example.com"},
+		[]string{"GET", "POST", "PATCH"},
+		[]string{"Content-Type", "X-Request-ID"},
+		true,
+	))
+
+	headers := preflight(t, app, "https://watch.example.com")
+	if got := headers.Get(fiber.HeaderAccessControlAllowOrigin); got != "https://watch.example.com" {
+		t.Errorf("Allow-Origin = %q, want the request origin", got)
+	}
+	if got := headers.Get(fiber.HeaderAccessControlAllowCredentials); got != "true" {
+		t.Errorf("Allow-Credentials = %q, want true", got)
+	}
+	if got := headers.Get(fiber.HeaderAccessControlAllowMethods); got != "GET,POST,PATCH" {
+		t.Errorf("Allow-Methods = %q, want the configured methods", got)
+	}
+	if got := headers.Get(fiber.HeaderAccessControlAllowHeaders); got != "Content-Type,X-Request-ID" {
+		t.Errorf("Allow-Headers = %q, want the configured headers", got)
+	}
+
+	headers = preflight(t, app, "https://evil.example.com")
+	if got := headers.Get(fiber.HeaderAccessControlAllowOrigin); got != "" {
+		t.Errorf("unlisted origin allowed: Allow-Origin = %q", got)
+	}
+}
+
+func TestCORSWildcardWithoutCredentials(t *testing.T) {
+	app := fiber.New()
+	app.Use(CORSMiddleware([]string{"*", "http://localhost:5173"}, []string{"GET"}, []string{"Content-Type"}, false))
+
+	headers := preflight(t, app, "https://anywhere.example.com")
+	if got := headers.Get(fiber.HeaderAccessControlAllowOrigin); got == "" {
+		t.Error("wildcard did not allow an arbitrary origin")
+	}
+	if got := headers.Get(fiber.HeaderAccessControlAllowCredentials); got != "" {
+		t.Errorf("Allow-Credentials = %q, want it omitted", got)
+	}
+}
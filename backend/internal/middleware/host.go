@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"watchparty/internal/services"
+)
+
+// HostOnlyMiddleware restricts a route to the current host of the session in
+// the :id parameter. It must run after AuthMiddleware. The session record is
+// authoritative, so hosts that took over after a handover are accepted even
+// though their token was issued as a viewer.
+func HostOnlyMiddleware(sessionService *services.SessionService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sessionID := c.Params("id")
+		tokenSessionID, _ := c.Locals("sessionId").(string)
+		userID, _ := c.Locals("userId").(string)
+
+		if sessionID == "" || tokenSessionID != sessionID {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   "Forbidden",
+				"message": "You don't have access to this session",
+			})
+		}
+
+		isHost, err := sessionService.IsHost(c.Context(), sessionID, userID)
+		if err != nil {
+			if errors.Is(err, services.ErrSessionNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error":   "Session not found",
+					"message": "The requested session doesn't exist or has expired",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "Internal Server Error",
+				"message": "Failed to verify host",
+			})
+		}
+		if !isHost {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   "Forbidden",
+				"message": "Only the host can perform this action",
+			})
+		}
+
+		return c.Next()
+	}
+}
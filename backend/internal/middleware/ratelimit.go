@@ -1,13 +1,25 @@
 package middleware
 
 import (
+	"context"
+	"log/slog"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"watchparty/internal/services"
 )
 
+// Limiter decides whether a request identified by key may proceed.
+// It returns whether the request is allowed, the remaining quota and
+// when the current window resets.
+type Limiter interface {
+	Allow(key string) (bool, int, time.Time)
+}
+
 // RateLimiter provides rate limiting functionality
 type RateLimiter struct {
 	requests map[string]*rateLimitEntry
@@ -61,8 +73,9 @@ func (rl *RateLimiter) Allow(key string) (bool, int, time.Time) {
 	entry, exists := rl.requests[key]
 
 	if !exists || now.After(entry.resetTime) {
-		// Create new entry
-		rl.requests[key] = &rateLimitEntry{
+		// Create new entry. Keys such as c.IP() can point into a request
+		// buffer fasthttp reuses, so the map keeps its own copy.
+		rl.requests[strings.Clone(key)] = &rateLimitEntry{
 			count:     1,
 			resetTime: now.Add(rl.window),
 		}
@@ -79,9 +92,70 @@ func (rl *RateLimiter) Allow(key string) (bool, int, time.Time) {
 	return true, rl.limit - entry.count, entry.resetTime
 }
 
+// RedisRateLimiter provides rate limiting shared across server instances.
+// It falls back to an in-memory limiter when Redis is unavailable.
+type RedisRateLimiter struct {
+	redis    *services.RedisService
+	fallback *RateLimiter
+	prefix   string
+	limit    int
+	window   time.Duration
+
+	// Set while requests go to the fallback, so the switch is logged once
+	// rather than on every request during an outage
+	degraded atomic.Bool
+}
+
+// NewRedisRateLimiter creates a new Redis-backed rate limiter
+func NewRedisRateLimiter(redis *services.RedisService, prefix string, limit int, window time.Duration) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		redis:    redis,
+		fallback: NewRateLimiter(limit, window),
+		prefix:   prefix,
+		limit:    limit,
+		window:   window,
+	}
+}
+
+// Allow checks if the request should be allowed
+func (rl *RedisRateLimiter) Allow(key string) (bool, int, time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	count, ttl, err := rl.redis.IncrWithExpiry(ctx, "ratelimit:"+rl.prefix+":"+key, rl.window)
+	if err != nil {
+		if rl.degraded.CompareAndSwap(false, true) {
+			slog.Warn("Redis rate limiter unavailable, using in-memory fallback", "limiter", rl.prefix, "error", err)
+		}
+		return rl.fallback.Allow(key)
+	}
+	if rl.degraded.CompareAndSwap(true, false) {
+		slog.Info("Redis rate limiter recovered", "limiter", rl.prefix)
+	}
+
+	if ttl <= 0 {
+		ttl = rl.window
+	}
+	reset := time.Now().Add(ttl)
+
+	if count > int64(rl.limit) {
+		return false, 0, reset
+	}
+	return true, rl.limit - int(count), reset
+}
+
+// newLimiter returns a Redis-backed limiter when Redis is configured,
+// otherwise an in-memory one
+func newLimiter(redis *services.RedisService, prefix string, limit int, window time.Duration) Limiter {
+	if redis == nil {
+		return NewRateLimiter(limit, window)
+	}
+	return NewRedisRateLimiter(redis, prefix, limit, window)
+}
+
 // CreateSessionRateLimiter returns middleware for session creation rate limiting
-func CreateSessionRateLimiter(limit int) fiber.Handler {
-	rl := NewRateLimiter(limit, time.Hour)
+func CreateSessionRateLimiter(redis *services.RedisService, limit int) fiber.Handler {
+	rl := newLimiter(redis, "create", limit, time.Hour)
 
 	return func(c *fiber.Ctx) error {
 		ip := c.IP()
@@ -103,9 +177,33 @@ func CreateSessionRateLimiter(limit int) fiber.Handler {
 	}
 }
 
+// SessionLookupRateLimiter returns middleware limiting unauthenticated
+// session existence checks per IP, so session IDs can't be enumerated
+func SessionLookupRateLimiter(redis *services.RedisService, limit int) fiber.Handler {
+	rl := newLimiter(redis, "lookup", limit, time.Minute)
+
+	return func(c *fiber.Ctx) error {
+		allowed, remaining, reset := rl.Allow(c.IP())
+
+		// Set rate limit headers
+		c.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		if !allowed {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error":   "Rate limit exceeded",
+				"message": "Too many session lookups, please try again later",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
 // JoinSessionRateLimiter returns middleware for session join rate limiting
-func JoinSessionRateLimiter(limit int) fiber.Handler {
-	rl := NewRateLimiter(limit, time.Minute)
+func JoinSessionRateLimiter(redis *services.RedisService, limit int) fiber.Handler {
+	rl := newLimiter(redis, "join", limit, time.Minute)
 
 	return func(c *fiber.Ctx) error {
 		// Use session ID + IP as key
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// testProxyAddr is the peer address of requests sent with app.Test
+const testProxyAddr = "0.0.0.0"
+
+// newProxiedApp serves a create limiter of one request per client, with
+// the real client IP read from header on requests from trustedProxies
+func newProxiedApp(header string, trustedProxies []string) *fiber.App {
+	app := fiber.New(fiber.Config{
+		ProxyHeader:             header,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          trustedProxies,
+		EnableIPValidation:      true,
+	})
+	app.Post("/create", CreateSessionRateLimiter(nil, 1), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	return app
+}
+
+// createFrom sends a create request forwarded for clientIP in header and
+// returns the response status
+func createFrom(t *testing.T, app *fiber.App, header, clientIP string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/create", nil)
+	req.Header.Set(header, clientIP)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("POST /create: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestRateLimitUsesForwardedClientIP(t *testing.T) {
+	for _, header := range []string{"X-Forwarded-For", "CF-Connecting-IP"} {
+		t.Run(header, func(t *testing.T) {
+			app := newProxiedApp(header, []string{testProxyAddr})
+
+			if status := createFrom(t, app, header, "198.51.100.1"); status != http.StatusOK {
+				t.Fatalf("first client: status %d, want 200", status)
+			}
+			// Another client behind the same proxy has its own bucket
+			if status := createFrom(t, app, header, "198.51.100.2"); status != http.StatusOK {
+				t.Errorf("second client: status %d, want 200", status)
+			}
+			if status := createFrom(t, app, header, "198.51.100.1"); status != http.StatusTooManyRequests {
+				t.Errorf("first client again: status %d, want 429", status)
+			}
+		})
+	}
+}
+
+func TestRateLimitIgnoresHeaderFromUntrustedPeer(t *testing.T) {
+	app := newProxiedApp("X-Forwarded-For", []string{"192.0.2.10"})
+
+	if status := createFrom(t, app, "X-Forwarded-For", "198.51.100.1"); status != http.StatusOK {
+		t.Fatalf("first request: status %d, want 200", status)
+	}
+	// A spoofed header can't buy a fresh bucket
+	if status := createFrom(t, app, "X-Forwarded-For", "198.51.100.2"); status != http.StatusTooManyRequests {
+		t.Errorf("spoofed client: status %d, want 429", status)
+	}
+}
@@ -6,16 +6,84 @@ import "encoding/json"
 type MessageType string
 
 const (
-	MessageTypeChat            MessageType = "chat"
-	MessageTypeWebRTCOffer     MessageType = "webrtc_offer"
-	MessageTypeWebRTCAnswer    MessageType = "webrtc_answer"
-	MessageTypeICECandidate    MessageType = "ice_candidate"
-	MessageTypePlaybackState   MessageType = "playback_state"
-	MessageTypePlaybackControl MessageType = "playback_control"
-	MessageTypeUserJoined      MessageType = "user_joined"
-	MessageTypeUserLeft        MessageType = "user_left"
+	MessageTypeChat               MessageType = "chat"
+	MessageTypeWebRTCOffer        MessageType = "webrtc_offer"
+	MessageTypeWebRTCAnswer       MessageType = "webrtc_answer"
+	MessageTypeICECandidate       MessageType = "ice_candidate"
+	MessageTypePlaybackState      MessageType = "playback_state"
+	MessageTypePlaybackControl    MessageType = "playback_control"
+	MessageTypeUserJoined         MessageType = "user_joined"
+	MessageTypeUserLeft           MessageType = "user_left"
+	MessageTypeReaction           MessageType = "reaction"
+	MessageTypeDeleteChat         MessageType = "delete_chat"
+	MessageTypeError              MessageType = "error"
+	MessageTypeHostChanged        MessageType = "host_changed"
+	MessageTypeMediaChanged       MessageType = "media_changed"
+	MessageTypeRenegotiate        MessageType = "renegotiate"
+	MessageTypeMediaState         MessageType = "media_state"
+	MessageTypeJoinRequest        MessageType = "join_request"
+	MessageTypeJoinResponse       MessageType = "join_response"
+	MessageTypeControllersChanged MessageType = "controllers_changed"
+	MessageTypeSessionExtended    MessageType = "session_extended"
+	MessageTypeUserMuted          MessageType = "user_muted"
+	MessageTypeUserUnmuted        MessageType = "user_unmuted"
+	MessageTypeTyping             MessageType = "typing"
+	MessageTypeChatAck            MessageType = "chat_ack"
+	MessageTypeIntermission       MessageType = "intermission"
+	MessageTypeIntermissionEnded  MessageType = "intermission_ended"
+	MessageTypeWaitForAll         MessageType = "wait_for_all"
+	MessageTypeReady              MessageType = "ready"
+	MessageTypeSyncPlay           MessageType = "sync_play"
+	MessageTypePasswordChanged    MessageType = "password_changed"
+	MessageTypeIdleTimeout        MessageType = "idle_timeout"
+	MessageTypeSessionRotated     MessageType = "session_rotated"
+
+	// MessageTypeBinary stands for binary frames without a JSON type, e.g.
+	// data-channel fallback, when rate limiting. It never appears on the wire.
+	MessageTypeBinary MessageType = "binary"
 )
 
+// lowPriorityTypes may be dropped when a session is flooded. Everything
+// else, notably playback and WebRTC signaling, is always delivered.
+var lowPriorityTypes = map[MessageType]bool{
+	MessageTypeChat:     true,
+	MessageTypeReaction: true,
+	MessageTypeTyping:   true,
+	MessageTypeBinary:   true,
+}
+
+// IsLowPriority reports whether a message type may be throttled under load
+func IsLowPriority(t MessageType) bool {
+	return lowPriorityTypes[t]
+}
+
+// clientMessageTypes are the message types clients may send. Anything else,
+// such as user_joined or host_changed, is only ever sent by the server, so
+// a client sending it is refused rather than relayed for others to trust.
+var clientMessageTypes = map[MessageType]bool{
+	MessageTypeChat:              true,
+	MessageTypeDeleteChat:        true,
+	MessageTypeReaction:          true,
+	MessageTypeTyping:            true,
+	MessageTypeWebRTCOffer:       true,
+	MessageTypeWebRTCAnswer:      true,
+	MessageTypeICECandidate:      true,
+	MessageTypeRenegotiate:       true,
+	MessageTypeMediaState:        true,
+	MessageTypePlaybackState:     true,
+	MessageTypePlaybackControl:   true,
+	MessageTypeIntermission:      true,
+	MessageTypeIntermissionEnded: true,
+	MessageTypeWaitForAll:        true,
+	MessageTypeReady:             true,
+	MessageTypeJoinResponse:      true,
+}
+
+// IsClientMessageType reports whether clients may send a message type
+func IsClientMessageType(t MessageType) bool {
+	return clientMessageTypes[t]
+}
+
 // WebSocketMessage is the standard message format for WebSocket communication
 type WebSocketMessage struct {
 	Type      MessageType     `json:"type"`
@@ -24,6 +92,7 @@ type WebSocketMessage struct {
 	UserID    string          `json:"user_id"`
 	TargetID  string          `json:"target_id,omitempty"` // For directed messages
 	Timestamp int64           `json:"timestamp"`
+	Seq       int64           `json:"seq,omitempty"` // Per-session broadcast sequence number, set by the server
 }
 
 // ChatPayload is the payload for chat messages
@@ -31,8 +100,49 @@ type ChatPayload struct {
 	ID        string `json:"id,omitempty"`
 	UserID    string `json:"user_id"`
 	Username  string `json:"username"`
-	Message   string `json:"message"`
+	Message   string `json:"message"` // Ciphertext when Encrypted is set
 	Timestamp int64  `json:"timestamp"`
+	Encrypted bool   `json:"encrypted,omitempty"` // Set by the server in encrypted-chat sessions
+	AckID     string `json:"ack_id,omitempty"`    // Client-chosen ID; if set the sender gets a chat_ack once the message is stored
+}
+
+// ChatAckPayload confirms to the sender that a chat message was persisted
+// and broadcast under the server-assigned MessageID
+type ChatAckPayload struct {
+	AckID     string `json:"ack_id"`
+	MessageID string `json:"message_id"`
+}
+
+// DeleteChatPayload is the payload for chat message deletion requests and events
+type DeleteChatPayload struct {
+	ID        string `json:"id"`
+	DeletedBy string `json:"deleted_by,omitempty"`
+}
+
+// ReactionPayload is the payload for emoji reactions shown over the player
+type ReactionPayload struct {
+	Emoji    string `json:"emoji"`
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// allowedReactions is the set of emoji clients may send as reactions
+var allowedReactions = map[string]bool{
+	"👍":  true,
+	"👎":  true,
+	"❤️": true,
+	"😂":  true,
+	"😮":  true,
+	"😢":  true,
+	"😡":  true,
+	"🎉":  true,
+	"🔥":  true,
+	"👏":  true,
+}
+
+// IsAllowedReaction checks if an emoji is in the reaction allowlist
+func IsAllowedReaction(emoji string) bool {
+	return allowedReactions[emoji]
 }
 
 // UserEventPayload is the payload for user joined/left events
@@ -41,11 +151,36 @@ type UserEventPayload struct {
 	Username string `json:"username"`
 }
 
+// HostChangedPayload is the payload sent when host privileges move to another user
+type HostChangedPayload struct {
+	HostID string `json:"host_id"`
+}
+
+// ControllersChangedPayload lists the users the host has allowed to control playback
+type ControllersChangedPayload struct {
+	Controllers []string `json:"controllers"`
+}
+
+// UserMutedPayload identifies the user the host muted or unmuted in chat
+type UserMutedPayload struct {
+	UserID string `json:"user_id"`
+}
+
+// SessionExtendedPayload is sent when the host pushes back a session's expiry
+type SessionExtendedPayload struct {
+	ExpiresAt string `json:"expires_at"`
+}
+
 // PlaybackStatePayload is the payload for playback synchronization
 type PlaybackStatePayload struct {
 	Playing     bool    `json:"playing"`
 	CurrentTime float64 `json:"current_time"`
 	Volume      float64 `json:"volume"`
+	// SentAt is the host's clock (ms) when CurrentTime was sampled, and
+	// ServerTime is stamped by the server on relay (ms). Together they let
+	// viewers estimate latency and clock skew before seeking.
+	SentAt     int64 `json:"sent_at,omitempty"`
+	ServerTime int64 `json:"server_time"`
 }
 
 // PlaybackControlPayload is the payload for playback control commands
@@ -56,6 +191,75 @@ type PlaybackControlPayload struct {
 	FromUsername string  `json:"from_username"` // Username who sent the command
 }
 
+// validPlaybackActions is the set of accepted playback control actions
+var validPlaybackActions = map[string]bool{
+	"play":          true,
+	"pause":         true,
+	"seek_forward":  true,
+	"seek_backward": true,
+	"toggle":        true,
+}
+
+// IsValidPlaybackAction checks if a playback control action is supported
+func IsValidPlaybackAction(action string) bool {
+	return validPlaybackActions[action]
+}
+
+// MaxIntermissionMessageLength caps the banner text shown during an intermission
+const MaxIntermissionMessageLength = 200
+
+// IntermissionPayload is broadcast when the host calls an intermission.
+// Clients pause playback and show Message as a banner until an
+// intermission_ended message arrives.
+type IntermissionPayload struct {
+	Message      string `json:"message,omitempty"`
+	FromUser     string `json:"from_user"`     // User ID who started the intermission
+	FromUsername string `json:"from_username"` // Username who started the intermission
+	StartedAt    int64  `json:"started_at"`    // Server time (ms)
+}
+
+// WaitForAllPayload asks every viewer to seek to Position and report ready
+// once buffered. The server answers with sync_play when all are ready or
+// TimeoutMs has passed.
+type WaitForAllPayload struct {
+	Position     float64 `json:"position"`
+	TimeoutMs    int64   `json:"timeout_ms"`    // Set by the server
+	FromUser     string  `json:"from_user"`     // User ID who asked to wait
+	FromUsername string  `json:"from_username"` // Username who asked to wait
+}
+
+// ReadyPayload is sent by a viewer once it has buffered at Position. The
+// server forwards it to the host so they can see who is still loading.
+type ReadyPayload struct {
+	Position float64 `json:"position"`
+	UserID   string  `json:"user_id"`
+}
+
+// SyncPlayPayload tells every client to start playing from Position at
+// server time At (ms)
+type SyncPlayPayload struct {
+	Position float64  `json:"position"`
+	At       int64    `json:"at"`
+	TimedOut bool     `json:"timed_out"`         // Some viewers were not ready in time
+	Waiting  []string `json:"waiting,omitempty"` // User IDs that were not ready
+}
+
+// IdleTimeoutPayload is sent to a viewer disconnected for inactivity
+type IdleTimeoutPayload struct {
+	IdleSeconds int64 `json:"idle_seconds"`
+}
+
+// SessionRotatedPayload is sent to each connected client when the host
+// moves the session to a new ID. The connection is closed right after, and
+// the client reconnects to SessionID with Token, its re-issued token. Token
+// is empty for a client that must join again, such as a spectator who
+// connected while the rotation was under way.
+type SessionRotatedPayload struct {
+	SessionID string `json:"session_id"`
+	ShareURL  string `json:"share_url"`
+	Token     string `json:"token,omitempty"`
+}
+
 // WebRTCSignalPayload represents WebRTC signaling data
 type WebRTCSignalPayload struct {
 	Type      string          `json:"type,omitempty"` // offer, answer
@@ -63,6 +267,100 @@ type WebRTCSignalPayload struct {
 	Candidate json.RawMessage `json:"candidate,omitempty"`
 }
 
+// MediaStatePayload describes a user's microphone, camera and screen share state
+type MediaStatePayload struct {
+	AudioEnabled  bool   `json:"audio_enabled"`
+	VideoEnabled  bool   `json:"video_enabled"`
+	ScreenSharing bool   `json:"screen_sharing"`
+	UserID        string `json:"user_id"`
+}
+
+// JoinRequestPayload is sent to the host when someone asks to join a session
+// that requires approval
+type JoinRequestPayload struct {
+	RequestID string `json:"request_id"`
+	Username  string `json:"username"`
+	Spectator bool   `json:"spectator,omitempty"` // Asking to watch read-only
+}
+
+// JoinResponsePayload is the host's decision on a pending join request
+type JoinResponsePayload struct {
+	RequestID string `json:"request_id"`
+	Approved  bool   `json:"approved"`
+}
+
+// Error codes sent to clients in ErrorPayload
+const (
+	ErrorCodeInvalidMessage  = "invalid_message"
+	ErrorCodeMessageTooLarge = "message_too_large"
+	ErrorCodeMessageTooLong  = "message_too_long"
+	ErrorCodeForbidden       = "forbidden"
+	ErrorCodeMuted           = "muted"
+	ErrorCodeInvalidTarget   = "invalid_target"
+	ErrorCodeRateLimited     = "rate_limited"
+	ErrorCodeChatNotSaved    = "chat_not_saved"
+	ErrorCodeUnknownType     = "unknown_type"
+	ErrorCodeInternal        = "internal_error"
+)
+
+// ErrorPayload is the payload sent to a client when its message is rejected
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	AckID   string `json:"ack_id,omitempty"` // Echoed when a chat message requesting an ack failed
+}
+
+// CloseReason says why the server closed a WebSocket connection. Each has
+// its own close code in the range reserved for applications, and the close
+// frame's text is a CloseFramePayload.
+type CloseReason string
+
+// Close reasons sent in the close frame
+const (
+	CloseReasonShutdown     CloseReason = "shutdown"      // Server is restarting; reconnect shortly
+	CloseReasonKicked       CloseReason = "kicked"        // Removed from the session
+	CloseReasonIdle         CloseReason = "idle"          // No messages for too long
+	CloseReasonSessionEnded CloseReason = "session_ended" // Session was terminated
+	CloseReasonUnauthorized CloseReason = "unauthorized"  // Token no longer grants access; join again
+)
+
+// Close codes for each CloseReason
+const (
+	CloseCodeShutdown     = 4000
+	CloseCodeKicked       = 4001
+	CloseCodeIdle         = 4002
+	CloseCodeSessionEnded = 4003
+	CloseCodeUnauthorized = 4004
+)
+
+// Code returns the WebSocket close code for the reason
+func (r CloseReason) Code() int {
+	switch r {
+	case CloseReasonShutdown:
+		return CloseCodeShutdown
+	case CloseReasonKicked:
+		return CloseCodeKicked
+	case CloseReasonIdle:
+		return CloseCodeIdle
+	case CloseReasonSessionEnded:
+		return CloseCodeSessionEnded
+	default:
+		return CloseCodeUnauthorized
+	}
+}
+
+// Reconnect reports whether the client should reconnect with its current token
+func (r CloseReason) Reconnect() bool {
+	return r == CloseReasonShutdown
+}
+
+// CloseFramePayload is the JSON text of a close frame sent with a CloseReason.
+// Close frame text is limited to 123 bytes, so keep it short.
+type CloseFramePayload struct {
+	Reason    CloseReason `json:"reason"`
+	Reconnect bool        `json:"reconnect"`
+}
+
 // ErrorResponse is a standard error response
 type ErrorResponse struct {
 	Error   string            `json:"error"`
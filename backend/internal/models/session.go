@@ -1,19 +1,34 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"time"
+
+	"watchparty/internal/utils"
 )
 
 // Session represents a watch party session
 type Session struct {
-	ID              string    `json:"id"`
-	Name            string    `json:"name"`
-	HostID          string    `json:"host_id"`
-	PasswordHash    string    `json:"password_hash"` // Stored in Redis, not exposed via API
-	Participants    []string  `json:"participants"`
-	MaxParticipants int       `json:"max_participants"`
-	CreatedAt       time.Time `json:"created_at"`
-	ExpiresAt       time.Time `json:"expires_at"`
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	HostID          string            `json:"host_id"`
+	PasswordHash    string            `json:"password_hash"` // Stored in Redis, not exposed via API; empty for public sessions
+	Public          bool              `json:"public,omitempty"`
+	Participants    []string          `json:"participants"`
+	Usernames       map[string]string `json:"usernames,omitempty"` // User ID -> display name
+	MaxParticipants int               `json:"max_participants"`
+	Locked          bool              `json:"locked"`
+	RequireApproval bool              `json:"require_approval,omitempty"`
+	Controllers     []string          `json:"controllers,omitempty"` // Users allowed to control playback besides the host
+	CreatorIP       string            `json:"creator_ip,omitempty"`  // Counted against MaxSessionsPerIP
+	ForceRelay      bool              `json:"force_relay,omitempty"`
+	EncryptedChat   bool              `json:"encrypted_chat,omitempty"` // Chat bodies are client-side ciphertext
+	MediaTitle      string            `json:"media_title,omitempty"`
+	MediaURL        string            `json:"media_url,omitempty"`
+	TokensValidFrom int64             `json:"tokens_valid_from,omitempty"` // Unix seconds; tokens issued earlier are revoked
+	CreatedAt       time.Time         `json:"created_at"`
+	ExpiresAt       time.Time         `json:"expires_at"`
 }
 
 // CreateSessionRequest is the request body for creating a session
@@ -21,6 +36,16 @@ type CreateSessionRequest struct {
 	Name      string `json:"name"`
 	Password  string `json:"password"`
 	AdminCode string `json:"admin_code"`
+	Public    bool   `json:"public,omitempty"` // no password required to join
+	// RequireApproval holds joiners in a waiting room until the host admits them
+	RequireApproval bool   `json:"require_approval,omitempty"`
+	Username        string `json:"username,omitempty"`
+	ForceRelay      bool   `json:"force_relay,omitempty"`
+	// EncryptedChat means clients encrypt chat with a key shared out of
+	// band; the server relays and stores message bodies untouched
+	EncryptedChat bool   `json:"encrypted_chat,omitempty"`
+	MediaTitle    string `json:"media_title,omitempty"`
+	MediaURL      string `json:"media_url,omitempty"`
 }
 
 // CreateSessionResponse is the response for session creation
@@ -28,47 +53,297 @@ type CreateSessionResponse struct {
 	ID         string        `json:"id"`
 	Name       string        `json:"name"`
 	ShareURL   string        `json:"share_url"`
+	Username   string        `json:"username"`
 	Token      string        `json:"token"`
 	IceServers []interface{} `json:"ice_servers"`
+	// IceTransportPolicy is passed to RTCPeerConnection: "all" or "relay"
+	IceTransportPolicy string `json:"ice_transport_policy"`
+	EncryptedChat      bool   `json:"encrypted_chat"`
 }
 
 // JoinSessionRequest is the request body for joining a session
 type JoinSessionRequest struct {
 	SessionID string `json:"session_id"`
 	Password  string `json:"password"`
+	Username  string `json:"username,omitempty"`
+	Token     string `json:"token,omitempty"` // Optional token from an earlier join, to rejoin as the same participant
+	// Spectator joins as a read-only viewer that takes no participant slot
+	// and can't chat or control playback
+	Spectator bool `json:"spectator,omitempty"`
 }
 
-// JoinSessionResponse is the response for joining a session
+// JoinSessionResponse is the response for joining a session. When the
+// session requires approval, Pending is set and only ID, Name and RequestID
+// are filled in until the host admits the user.
 type JoinSessionResponse struct {
 	ID         string        `json:"id"`
 	Name       string        `json:"name"`
+	Pending    bool          `json:"pending,omitempty"`
+	RequestID  string        `json:"request_id,omitempty"`
+	Username   string        `json:"username"`
 	Token      string        `json:"token"`
 	IceServers []interface{} `json:"ice_servers"`
+	// IceTransportPolicy is passed to RTCPeerConnection: "all" or "relay"
+	IceTransportPolicy string `json:"ice_transport_policy"`
+	EncryptedChat      bool   `json:"encrypted_chat"`
+	// SpotsRemaining and IsFull count the joining user, so the frontend
+	// can warn e.g. "1 spot left"
+	SpotsRemaining int  `json:"spots_remaining"`
+	IsFull         bool `json:"is_full"`
+	Spectator      bool `json:"spectator,omitempty"`
 }
 
 // SessionInfoResponse is the response for getting session details
 type SessionInfoResponse struct {
-	ID              string   `json:"id"`
-	Name            string   `json:"name"`
-	HostID          string   `json:"host_id"`
-	Participants    []string `json:"participants"`
-	MaxParticipants int      `json:"max_participants"`
-	CreatedAt       string   `json:"created_at"`
-	ExpiresAt       string   `json:"expires_at"`
+	ID                string   `json:"id"`
+	Name              string   `json:"name"`
+	HostID            string   `json:"host_id"`
+	Participants      []string `json:"participants"`
+	MaxParticipants   int      `json:"max_participants"`
+	SpotsRemaining    int      `json:"spots_remaining"`
+	IsFull            bool     `json:"is_full"`
+	Spectators        int      `json:"spectators"` // Spectators connected to this server, not counted in Participants
+	ActiveConnections int64    `json:"active_connections"`
+	Locked            bool     `json:"locked"`
+	Public            bool     `json:"public"`
+	EncryptedChat     bool     `json:"encrypted_chat"`
+	Controllers       []string `json:"controllers"`
+	MediaTitle        string   `json:"media_title,omitempty"`
+	MediaURL          string   `json:"media_url,omitempty"`
+	CreatedAt         string   `json:"created_at"`
+	ExpiresAt         string   `json:"expires_at"`
+}
+
+// Pending join request states
+const (
+	JoinStatusPending  = "pending"
+	JoinStatusApproved = "approved"
+	JoinStatusDenied   = "denied"
+)
+
+// PendingJoin is a join request waiting for the host's decision
+type PendingJoin struct {
+	ID          string    `json:"id"`
+	SessionID   string    `json:"session_id"`
+	Username    string    `json:"username"`
+	Spectator   bool      `json:"spectator,omitempty"`
+	Status      string    `json:"status"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// SessionExistsResponse is the public, unauthenticated view of a session
+// shown on the join page before the user enters a password
+type SessionExistsResponse struct {
+	Exists           bool   `json:"exists"`
+	Name             string `json:"name"`
+	RequiresPassword bool   `json:"requires_password"`
+	IsFull           bool   `json:"is_full"`
+}
+
+// IceConfigResponse is the response for refreshing a session's ICE configuration
+type IceConfigResponse struct {
+	IceServers         []interface{} `json:"ice_servers"`
+	IceTransportPolicy string        `json:"ice_transport_policy"`
+}
+
+// ICE transport policies understood by RTCPeerConnection
+const (
+	IceTransportPolicyAll   = "all"
+	IceTransportPolicyRelay = "relay"
+)
+
+// LockSessionResponse is the response for toggling a session's lock state
+type LockSessionResponse struct {
+	ID     string `json:"id"`
+	Locked bool   `json:"locked"`
+}
+
+// AdminSessionSummary is a session entry in the admin session list
+type AdminSessionSummary struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	ParticipantCount int    `json:"participant_count"`
+	ExpiresAt        string `json:"expires_at"`
+}
+
+// MaxBatchSessions caps how many sessions one admin batch lookup may request
+const MaxBatchSessions = 100
+
+// BatchSessionsRequest is the request body for looking up several sessions at once
+type BatchSessionsRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// ClientDropStats reports backpressure for a single WebSocket connection
+type ClientDropStats struct {
+	SessionID        string `json:"session_id"`
+	UserID           string `json:"user_id"`
+	ClientID         string `json:"client_id"`
+	DroppedMessages  int64  `json:"dropped_messages"`
+	ConsecutiveDrops int64  `json:"consecutive_drops"`
+}
+
+// HubMetrics summarises WebSocket backpressure across the hub
+type HubMetrics struct {
+	DroppedMessages   int64             `json:"dropped_messages"`
+	EvictedClients    int64             `json:"evicted_clients"`
+	ThrottledMessages int64             `json:"throttled_messages"` // Low-priority messages refused by the per-session rate limit
+	BroadcastQueue    QueueStats        `json:"broadcast_queue"`
+	DirectQueue       QueueStats        `json:"direct_queue"`
+	Clients           []ClientDropStats `json:"clients"` // Connections that have dropped messages
+}
+
+// QueueStats describes one of the hub's inbound queues
+type QueueStats struct {
+	Length   int   `json:"length"`
+	Capacity int   `json:"capacity"`
+	Peak     int64 `json:"peak"`      // Deepest the queue has been since start
+	NearFull int64 `json:"near_full"` // Sends that found the queue at least 80% full
+	Dropped  int64 `json:"dropped"`   // Messages dropped because the queue was full
+}
+
+// Audit events recorded for a session
+const (
+	AuditEventCreate       = "create"
+	AuditEventJoin         = "join"
+	AuditEventRejoin       = "rejoin"
+	AuditEventLeave        = "leave"
+	AuditEventKick         = "kick"
+	AuditEventHostTransfer = "host_transfer"
+	AuditEventTerminate    = "terminate"
+	AuditEventPassword     = "password_change"
+	AuditEventRotate       = "rotate"
+	AuditEventSpectate     = "spectate"
+)
+
+// TranscriptResponse is a session's exported chat log, oldest message first
+type TranscriptResponse struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Messages []TranscriptEntry `json:"messages"`
+}
+
+// TranscriptEntry is one chat message in an exported transcript
+type TranscriptEntry struct {
+	ID        string `json:"id,omitempty"`
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	Message   string `json:"message"` // Ciphertext when Encrypted is set
+	Timestamp int64  `json:"timestamp"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+}
+
+// AuditEntry is one record in a session's audit trail. IPs are truncated
+// and no secrets are ever stored.
+type AuditEntry struct {
+	Event     string `json:"event"`
+	Actor     string `json:"actor"` // User ID, or "system"/"admin"
+	IP        string `json:"ip,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Webhook events sent to WEBHOOK_URL
+const (
+	WebhookSessionCreated = "session_created"
+	WebhookUserJoined     = "user_joined"
+	WebhookUserLeft       = "user_left"
+	WebhookSessionEnded   = "session_ended"
+)
+
+// WebhookEvent is the JSON body POSTed for each webhook event
+type WebhookEvent struct {
+	Event     string      `json:"event"`
+	SessionID string      `json:"session_id"`
+	Data      interface{} `json:"data,omitempty"`
+	Timestamp int64       `json:"timestamp"` // Unix milliseconds when the event happened
+}
+
+// WebhookUserData describes the user in user_joined and user_left events
+type WebhookUserData struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// WebhookSessionData describes the session in session_created and
+// session_ended events
+type WebhookSessionData struct {
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason,omitempty"` // Why a session ended: terminated or idle
+}
+
+// StatsResponse holds aggregate usage numbers, e.g. for "X parties
+// happening now"
+type StatsResponse struct {
+	ActiveSessions    int    `json:"active_sessions"`
+	ConnectedClients  int    `json:"connected_clients"`  // On this server instance
+	MessagesProcessed int64  `json:"messages_processed"` // Since this server instance started
+	GeneratedAt       string `json:"generated_at"`
+}
+
+// VersionResponse describes the running build so clients can tell when the
+// backend has been redeployed
+type VersionResponse struct {
+	Version       string `json:"version"`
+	Commit        string `json:"commit"`
+	BuildTime     string `json:"build_time"`
+	StartTime     string `json:"start_time"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+}
+
+// ChangePasswordRequest is the request body for rotating a session password
+type ChangePasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// ChangePasswordResponse is the response for rotating a session password.
+// When other participants' tokens were revoked, Token replaces the host's.
+type ChangePasswordResponse struct {
+	ID            string `json:"id"`
+	TokensRevoked bool   `json:"tokens_revoked"`
+	Token         string `json:"token,omitempty"`
+}
+
+// RotateSessionResponse is the response for moving a session to a new ID.
+// The old ID and every token issued for it stop working; Token replaces the
+// host's.
+type RotateSessionResponse struct {
+	ID         string `json:"id"`
+	PreviousID string `json:"previous_id"`
+	ShareURL   string `json:"share_url"`
+	Token      string `json:"token"`
+}
+
+// UpdateMediaRequest is the request body for changing the now-playing media
+type UpdateMediaRequest struct {
+	MediaTitle string `json:"media_title"`
+	MediaURL   string `json:"media_url"`
+}
+
+// MediaChangedPayload is the payload broadcast when the now-playing media changes
+type MediaChangedPayload struct {
+	MediaTitle string `json:"media_title"`
+	MediaURL   string `json:"media_url"`
 }
 
 // Validate checks if the create session request is valid
 func (r *CreateSessionRequest) Validate() map[string]string {
 	errors := make(map[string]string)
 
-	if len(r.Name) < 3 || len(r.Name) > 50 {
-		errors["name"] = "Name must be between 3 and 50 characters"
+	if violations := utils.ValidateSessionName(r.Name); len(violations) > 0 {
+		errors["name"] = strings.Join(violations, ". ")
 	}
 
-	if len(r.Password) < 6 {
-		errors["password"] = "Password must be at least 6 characters"
+	// Public rooms are joined without a password, so there is nothing to check
+	if !r.Public {
+		if violations := utils.ValidatePassword(r.Password, utils.GetPasswordPolicy()); len(violations) > 0 {
+			errors["password"] = strings.Join(violations, ". ")
+		}
 	}
 
+	validateUsername(r.Username, errors)
+	validateMedia(r.MediaTitle, r.MediaURL, errors)
+
 	return errors
 }
 
@@ -80,9 +355,113 @@ func (r *JoinSessionRequest) Validate() map[string]string {
 		errors["session_id"] = "Session ID is required"
 	}
 
-	if r.Password == "" {
-		errors["password"] = "Password is required"
+	// Only an upper bound, so passwords set under an older policy still work
+	if len(r.Password) > utils.MaxPasswordBytes {
+		errors["password"] = fmt.Sprintf("Password must be at most %d bytes", utils.MaxPasswordBytes)
+	}
+
+	validateUsername(r.Username, errors)
+
+	return errors
+}
+
+// Validate checks if the change password request is valid
+func (r *ChangePasswordRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+	if violations := utils.ValidatePassword(r.Password, utils.GetPasswordPolicy()); len(violations) > 0 {
+		errors["password"] = strings.Join(violations, ". ")
+	}
+	return errors
+}
+
+// Validate checks if the update media request is valid
+func (r *UpdateMediaRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+	validateMedia(r.MediaTitle, r.MediaURL, errors)
+	return errors
+}
+
+// Validate checks if the batch sessions request is valid
+func (r *BatchSessionsRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	switch {
+	case len(r.IDs) == 0:
+		errors["ids"] = "At least one session ID is required"
+	case len(r.IDs) > MaxBatchSessions:
+		errors["ids"] = fmt.Sprintf("At most %d session IDs may be requested at once", MaxBatchSessions)
+	default:
+		for _, id := range r.IDs {
+			if !utils.IsValidUUID(id) {
+				errors["ids"] = "Session IDs must be valid UUIDs"
+				break
+			}
+		}
 	}
 
 	return errors
 }
+
+// validateMedia checks the optional now-playing fields
+func validateMedia(title, mediaURL string, errors map[string]string) {
+	if len(title) > 200 {
+		errors["media_title"] = "Media title must be at most 200 characters"
+	}
+
+	if mediaURL != "" && !utils.IsValidMediaURL(mediaURL) {
+		errors["media_url"] = "Media URL must be a valid http or https URL"
+	}
+}
+
+// validateUsername checks the optional display name
+func validateUsername(username string, errors map[string]string) {
+	if username != "" && !utils.IsValidUsername(username) {
+		errors["username"] = "Username must be between 3 and 20 characters"
+	}
+}
+
+// IsController reports whether the host has delegated playback control to userID
+func (s *Session) IsController(userID string) bool {
+	for _, id := range s.Controllers {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// SpotsRemaining reports how many more participants can join, never less
+// than zero
+func (s *Session) SpotsRemaining() int {
+	spots := s.MaxParticipants - len(s.Participants)
+	if spots < 0 {
+		return 0
+	}
+	return spots
+}
+
+// IsFull reports whether the session has reached its participant cap
+func (s *Session) IsFull() bool {
+	return s.SpotsRemaining() == 0
+}
+
+// TokenRevoked reports whether a token issued at issuedAt predates the
+// session's last token revocation
+func (s *Session) TokenRevoked(issuedAt time.Time) bool {
+	return s.TokensValidFrom > 0 && issuedAt.Unix() < s.TokensValidFrom
+}
+
+// UniqueUsername returns name, or name with a "#N" suffix if another
+// participant already uses it (case-insensitive)
+func (s *Session) UniqueUsername(name string) string {
+	taken := make(map[string]bool, len(s.Usernames))
+	for _, n := range s.Usernames {
+		taken[strings.ToLower(n)] = true
+	}
+
+	candidate := name
+	for i := 2; taken[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s#%d", name, i)
+	}
+	return candidate
+}
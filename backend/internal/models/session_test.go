@@ -0,0 +1,120 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"watchparty/internal/utils"
+)
+
+func TestCreateSessionRequestAppliesPasswordPolicy(t *testing.T) {
+	t.Cleanup(func() { utils.SetPasswordPolicy(utils.DefaultPasswordPolicy) })
+
+	req := &CreateSessionRequest{Name: "Movie night", Password: "abcdefgh"}
+	if errs := req.Validate(); len(errs) != 0 {
+		t.Fatalf("default policy rejected %q: %v", req.Password, errs)
+	}
+
+	utils.SetPasswordPolicy(utils.PasswordPolicy{MinLength: 10, MaxLength: utils.MaxPasswordBytes, RequireDigit: true})
+	errs := req.Validate()
+	if errs["password"] == "" {
+		t.Fatalf("tightened policy accepted %q", req.Password)
+	}
+	if want := "Password must be at least 10 characters. Password must contain a digit"; errs["password"] != want {
+		t.Errorf("password error = %q, want %q", errs["password"], want)
+	}
+
+	req.Password = "abcdefgh12"
+	if errs := req.Validate(); len(errs) != 0 {
+		t.Errorf("tightened policy rejected %q: %v", req.Password, errs)
+	}
+}
+
+func TestCreateSessionRequestPasswordRequiredUnlessPublic(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      CreateSessionRequest
+		wantErrs bool
+	}{
+		{"private with password", CreateSessionRequest{Name: "Movie night", Password: "secret123"}, false},
+		{"private without password", CreateSessionRequest{Name: "Movie night"}, true},
+		{"private with short password", CreateSessionRequest{Name: "Movie night", Password: "abc"}, true},
+		{"public without password", CreateSessionRequest{Name: "Movie night", Public: true}, false},
+		{"public with password", CreateSessionRequest{Name: "Movie night", Password: "abc", Public: true}, false},
+		{"public without name", CreateSessionRequest{Public: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.req.Validate()
+			if got := len(errs) > 0; got != tt.wantErrs {
+				t.Errorf("Validate() = %v, want errors: %v", errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestCreateSessionRequestReportsNameViolations(t *testing.T) {
+	req := &CreateSessionRequest{Name: "😀😀😀", Password: "secret123"}
+	errs := req.Validate()
+	if want := "Name must contain at least one letter or digit"; errs["name"] != want {
+		t.Errorf("name error = %q, want %q", errs["name"], want)
+	}
+	if _, ok := errs["password"]; ok {
+		t.Errorf("unexpected password error: %q", errs["password"])
+	}
+}
+
+func TestOversizedPasswordIsRejected(t *testing.T) {
+	atLimit := strings.Repeat("a", utils.MaxPasswordBytes)
+	overLimit := atLimit + "a"
+	// 37 two-byte characters are under 72 characters but over 72 bytes
+	multibyte := strings.Repeat("é", 37)
+	huge := strings.Repeat("a", 1<<20)
+
+	for _, password := range []string{overLimit, multibyte, huge} {
+		create := &CreateSessionRequest{Name: "Movie night", Password: password}
+		if errs := create.Validate(); !strings.Contains(errs["password"], "at most 72 bytes") {
+			t.Errorf("create with %d-byte password: errors = %v", len(password), errs)
+		}
+		join := &JoinSessionRequest{SessionID: "abc", Password: password}
+		if errs := join.Validate(); !strings.Contains(errs["password"], "at most 72 bytes") {
+			t.Errorf("join with %d-byte password: errors = %v", len(password), errs)
+		}
+	}
+
+	create := &CreateSessionRequest{Name: "Movie night", Password: atLimit}
+	if errs := create.Validate(); len(errs) != 0 {
+		t.Errorf("create with password at the limit: errors = %v", errs)
+	}
+	join := &JoinSessionRequest{SessionID: "abc", Password: atLimit}
+	if errs := join.Validate(); len(errs) != 0 {
+		t.Errorf("join with password at the limit: errors = %v", errs)
+	}
+}
+
+func TestSpotsRemaining(t *testing.T) {
+	tests := []struct {
+		name         string
+		participants int
+		max          int
+		wantSpots    int
+		wantFull     bool
+	}{
+		{"empty", 0, 3, 3, false},
+		{"one spot left", 2, 3, 1, false},
+		{"at capacity", 3, 3, 0, true},
+		// The cap can be lowered below the current head count
+		{"over capacity", 5, 3, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Session{Participants: make([]string, tt.participants), MaxParticipants: tt.max}
+			if got := s.SpotsRemaining(); got != tt.wantSpots {
+				t.Errorf("SpotsRemaining() = %d, want %d", got, tt.wantSpots)
+			}
+			if got := s.IsFull(); got != tt.wantFull {
+				t.Errorf("IsFull() = %v, want %v", got, tt.wantFull)
+			}
+		})
+	}
+}
@@ -2,15 +2,22 @@ package services
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"watchparty/internal/config"
 )
 
+// tokenIssuer is the "iss" claim on every token this server mints
+const tokenIssuer = "watchparty"
+
 // AuthService handles authentication operations
 type AuthService struct {
-	config *config.Config
+	config    *config.Config
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
 }
 
 // JWTClaims represents the claims in a JWT token
@@ -19,35 +26,95 @@ type JWTClaims struct {
 	UserID    string `json:"user_id"`
 	Username  string `json:"username"`
 	IsHost    bool   `json:"is_host"`
+	// IsSpectator marks a read-only viewer who isn't a session participant
+	IsSpectator bool `json:"is_spectator,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// NewAuthService creates a new auth service instance
-func NewAuthService(cfg *config.Config) *AuthService {
-	return &AuthService{
+// Issued returns when the token was issued, or the zero time if it doesn't
+// say, which predates any revocation
+func (c *JWTClaims) Issued() time.Time {
+	if c.IssuedAt == nil {
+		return time.Time{}
+	}
+	return c.IssuedAt.Time
+}
+
+// NewAuthService creates a new auth service instance using the signing
+// algorithm selected by JWT_ALG. RS256 keys are loaded from PEM files.
+func NewAuthService(cfg *config.Config) (*AuthService, error) {
+	a := &AuthService{
 		config: cfg,
 	}
+
+	switch cfg.JWTAlg {
+	case "RS256":
+		data, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT private key: %w", err)
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT private key: %w", err)
+		}
+		a.method = jwt.SigningMethodRS256
+		a.signKey = privateKey
+		a.verifyKey = &privateKey.PublicKey
+
+		if cfg.JWTPublicKeyFile != "" {
+			data, err := os.ReadFile(cfg.JWTPublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read JWT public key: %w", err)
+			}
+			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse JWT public key: %w", err)
+			}
+			a.verifyKey = publicKey
+		}
+	default:
+		a.method = jwt.SigningMethodHS256
+		a.signKey = []byte(cfg.JWTSecret)
+		a.verifyKey = []byte(cfg.JWTSecret)
+	}
+
+	return a, nil
 }
 
 // GenerateToken creates a new JWT token for a user
 func (a *AuthService) GenerateToken(sessionID, userID, username string, isHost bool) (string, error) {
-	now := time.Now()
-	claims := JWTClaims{
+	return a.signToken(JWTClaims{
 		SessionID: sessionID,
 		UserID:    userID,
 		Username:  username,
 		IsHost:    isHost,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(a.config.JWTExpiration)),
-			IssuedAt:  jwt.NewNumericDate(now),
-			NotBefore: jwt.NewNumericDate(now),
-			Issuer:    "watchparty",
-			Subject:   userID,
-		},
+	})
+}
+
+// GenerateSpectatorToken creates a JWT token for a read-only spectator
+func (a *AuthService) GenerateSpectatorToken(sessionID, userID, username string) (string, error) {
+	return a.signToken(JWTClaims{
+		SessionID:   sessionID,
+		UserID:      userID,
+		Username:    username,
+		IsSpectator: true,
+	})
+}
+
+// signToken fills in the registered claims and signs the token
+func (a *AuthService) signToken(claims JWTClaims) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(a.config.JWTExpiration)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Issuer:    tokenIssuer,
+		Subject:   claims.UserID,
+		Audience:  jwt.ClaimStrings{a.config.JWTAudience},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(a.config.JWTSecret))
+	token := jwt.NewWithClaims(a.method, claims)
+	signedToken, err := token.SignedString(a.signKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -55,15 +122,19 @@ func (a *AuthService) GenerateToken(sessionID, userID, username string, isHost b
 	return signedToken, nil
 }
 
-// ValidateToken validates a JWT token and returns the claims
+// ValidateToken validates a JWT token and returns the claims. Tokens must
+// have been issued by watchparty for this deployment's audience, so a token
+// from another deployment sharing the secret is rejected. Only the
+// configured algorithm is accepted, so an RS256 public key can never be used
+// as an HMAC secret.
 func (a *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if token.Method.Alg() != a.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(a.config.JWTSecret), nil
-	})
+		return a.verifyKey, nil
+	}, jwt.WithValidMethods([]string{a.method.Alg()}), jwt.WithIssuer(tokenIssuer), jwt.WithAudience(a.config.JWTAudience))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -71,7 +142,7 @@ func (a *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 
 	claims, ok := token.Claims.(*JWTClaims)
 	if !ok || !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+		return nil, ErrInvalidToken
 	}
 
 	return claims, nil
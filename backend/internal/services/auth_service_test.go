@@ -0,0 +1,212 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"watchparty/internal/config"
+)
+
+// newAuth returns an HS256 auth service for the given audience
+func newAuth(t *testing.T, audience string) *AuthService {
+	t.Helper()
+	cfg := config.Load()
+	cfg.JWTAlg = "HS256"
+	cfg.JWTSecret = "shared-secret"
+	cfg.JWTAudience = audience
+	auth, err := NewAuthService(cfg)
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+	return auth
+}
+
+func TestValidateTokenChecksAudience(t *testing.T) {
+	ours := newAuth(t, "https://watch.example.com")
+	theirs := newAuth(t, "https://other.example.com")
+
+	token, err := theirs.GenerateToken("session-1", "user-1", "Popcorn", true)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := ours.ValidateToken(token); !errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		t.Errorf("token for another audience: err = %v, want %v", err, jwt.ErrTokenInvalidAudience)
+	}
+
+	claims, err := theirs.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("token for our own audience rejected: %v", err)
+	}
+	if claims.Issuer != tokenIssuer || len(claims.Audience) != 1 || claims.Audience[0] != "https://other.example.com" {
+		t.Errorf("claims iss = %q aud = %v", claims.Issuer, claims.Audience)
+	}
+}
+
+func TestValidateTokenChecksIssuer(t *testing.T) {
+	auth := newAuth(t, "https://watch.example.com")
+	now := time.Now()
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, JWTClaims{
+		SessionID: "session-1",
+		UserID:    "user-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "someone-else",
+			Audience:  jwt.ClaimStrings{"https://watch.example.com"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	})
+	token, err := forged.SignedString([]byte("shared-secret"))
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	if _, err := auth.ValidateToken(token); !errors.Is(err, jwt.ErrTokenInvalidIssuer) {
+		t.Errorf("err = %v, want %v", err, jwt.ErrTokenInvalidIssuer)
+	}
+}
+
+// rsaKeyFiles writes a fresh RSA key pair as PEM files and returns their
+// paths along with the public key's PEM bytes
+func rsaKeyFiles(t *testing.T) (privatePath, publicPath string, publicPEM []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	publicPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	dir := t.TempDir()
+	privatePath = filepath.Join(dir, "jwt.key")
+	publicPath = filepath.Join(dir, "jwt.pub")
+	if err := os.WriteFile(privatePath, privatePEM, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(publicPath, publicPEM, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return privatePath, publicPath, publicPEM
+}
+
+// newRSAAuth returns an RS256 auth service using the given key files
+func newRSAAuth(t *testing.T, privatePath, publicPath string) *AuthService {
+	t.Helper()
+	cfg := config.Load()
+	cfg.JWTAlg = "RS256"
+	cfg.JWTPrivateKeyFile = privatePath
+	cfg.JWTPublicKeyFile = publicPath
+	auth, err := NewAuthService(cfg)
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+	return auth
+}
+
+func TestSignAndValidateWithEachAlgorithm(t *testing.T) {
+	privatePath, publicPath, _ := rsaKeyFiles(t)
+	tests := []struct {
+		name string
+		auth *AuthService
+		alg  string
+	}{
+		{"HS256", newAuth(t, "https://watch.example.com"), "HS256"},
+		{"RS256 with derived public key", newRSAAuth(t, privatePath, ""), "RS256"},
+		{"RS256 with public key file", newRSAAuth(t, privatePath, publicPath), "RS256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.auth.GenerateToken("session-1", "user-1", "Popcorn", true)
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &JWTClaims{})
+			if err != nil {
+				t.Fatalf("ParseUnverified: %v", err)
+			}
+			if parsed.Method.Alg() != tt.alg {
+				t.Errorf("token signed with %s, want %s", parsed.Method.Alg(), tt.alg)
+			}
+
+			claims, err := tt.auth.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+			if claims.SessionID != "session-1" || claims.UserID != "user-1" || !claims.IsHost {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestValidateTokenRejectsOtherAlgorithms(t *testing.T) {
+	privatePath, publicPath, publicPEM := rsaKeyFiles(t)
+	rs := newRSAAuth(t, privatePath, publicPath)
+	hs := newAuth(t, rs.config.JWTAudience)
+	now := time.Now()
+	claims := JWTClaims{
+		SessionID: "session-1",
+		UserID:    "user-1",
+		IsHost:    true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Audience:  jwt.ClaimStrings{rs.config.JWTAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+
+	// The classic confusion attack: HMAC-sign with the public key, hoping
+	// the verifier uses it as a shared secret
+	confused, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(publicPEM)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	if _, err := rs.ValidateToken(confused); err == nil {
+		t.Error("RS256 service accepted an HS256 token signed with its public key")
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	if _, err := rs.ValidateToken(unsigned); err == nil {
+		t.Error("RS256 service accepted an unsigned token")
+	}
+
+	rsToken, err := rs.GenerateToken("session-1", "user-1", "Popcorn", true)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := hs.ValidateToken(rsToken); err == nil {
+		t.Error("HS256 service accepted an RS256 token")
+	}
+}
+
+func TestNewAuthServiceRequiresRSAKey(t *testing.T) {
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a key"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	for _, path := range []string{"", filepath.Join(dir, "missing.pem"), garbage} {
+		cfg := config.Load()
+		cfg.JWTAlg = "RS256"
+		cfg.JWTPrivateKeyFile = path
+		if _, err := NewAuthService(cfg); err == nil {
+			t.Errorf("private key %q: NewAuthService succeeded", path)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package services
+
+import "errors"
+
+// Errors returned by the services for conditions callers are expected to
+// handle. Match them with errors.Is; they may be wrapped with extra context.
+var (
+	ErrValidationFailed       = errors.New("validation failed")
+	ErrInvalidSessionID       = errors.New("invalid session ID format")
+	ErrSessionNotFound        = errors.New("session not found")
+	ErrSessionExpired         = errors.New("session expired")
+	ErrSessionLocked          = errors.New("session locked")
+	ErrSessionFull            = errors.New("session is full")
+	ErrSessionBusy            = errors.New("session busy")
+	ErrInvalidPassword        = errors.New("invalid password")
+	ErrTooManyFailedAttempts  = errors.New("too many failed attempts")
+	ErrTooManyActiveSessions  = errors.New("too many active sessions")
+	ErrServerAtCapacity       = errors.New("server at session capacity")
+	ErrSessionConnectionLimit = errors.New("session connection limit reached")
+	ErrUserConnectionLimit    = errors.New("too many connections for this user")
+	ErrPublicSessionsDisabled = errors.New("public sessions disabled")
+	ErrRelayUnavailable       = errors.New("relay unavailable")
+	ErrJoinRequestNotFound    = errors.New("join request not found")
+	ErrJoinDenied             = errors.New("join denied")
+	ErrParticipantNotFound    = errors.New("participant not found")
+	ErrCannotMuteHost         = errors.New("cannot mute host")
+	ErrExtensionLimitReached  = errors.New("extension limit reached")
+	ErrMessageNotFound        = errors.New("message not found")
+	ErrNotMessageAuthor       = errors.New("not the message author")
+	ErrInvalidToken           = errors.New("invalid token")
+	ErrSessionHasNoPassword   = errors.New("session has no password")
+	ErrMediaURLNotAllowed     = errors.New("media URL not allowed")
+)
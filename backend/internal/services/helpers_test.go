@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"golang.org/x/crypto/bcrypt"
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+)
+
+const (
+	testPassword = "secret123"
+	testIP       = "203.0.113.7"
+)
+
+// testEnv is a set of services backed by an in-memory Redis
+type testEnv struct {
+	cfg      *config.Config
+	mr       *miniredis.Miniredis
+	redis    *RedisService
+	auth     *AuthService
+	sessions *SessionService
+}
+
+// testConfig returns the default configuration pointed at a test Redis,
+// with bcrypt at its cheapest cost so tests stay fast
+func testConfig(redisAddr string) *config.Config {
+	cfg := config.Load()
+	cfg.RedisURL = redisAddr
+	cfg.RedisRetries = 0
+	cfg.BcryptCost = bcrypt.MinCost
+	return cfg
+}
+
+// newTestEnv builds the services on a fresh in-memory Redis. configure, if
+// not nil, adjusts the configuration first.
+func newTestEnv(t *testing.T, configure func(*config.Config)) *testEnv {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	cfg := testConfig(mr.Addr())
+	if configure != nil {
+		configure(cfg)
+	}
+
+	redis, err := NewRedisService(cfg)
+	if err != nil {
+		t.Fatalf("NewRedisService: %v", err)
+	}
+	t.Cleanup(func() { redis.Close() })
+
+	auth, err := NewAuthService(cfg)
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+	webhooks := NewWebhookService(cfg)
+	return &testEnv{
+		cfg:      cfg,
+		mr:       mr,
+		redis:    redis,
+		auth:     auth,
+		sessions: NewSessionService(redis, auth, NewICEService(redis, cfg), webhooks, cfg),
+	}
+}
+
+// createSession creates a password-protected session from testIP
+func (e *testEnv) createSession(t *testing.T) *models.CreateSessionResponse {
+	t.Helper()
+	resp, err := e.sessions.CreateSession(context.Background(), &models.CreateSessionRequest{
+		Name:     "Movie night",
+		Password: testPassword,
+	}, "http://localhost:5173", testIP, "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	return resp
+}
+
+// join joins a session with the test password
+func (e *testEnv) join(t *testing.T, sessionID string) *models.JoinSessionResponse {
+	t.Helper()
+	resp, err := e.sessions.JoinSession(context.Background(), &models.JoinSessionRequest{
+		SessionID: sessionID,
+		Password:  testPassword,
+	}, testIP, "")
+	if err != nil {
+		t.Fatalf("JoinSession: %v", err)
+	}
+	return resp
+}
+
+// session reads a session straight from Redis
+func (e *testEnv) session(t *testing.T, sessionID string) *models.Session {
+	t.Helper()
+	session, err := e.redis.GetSession(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	return session
+}
+
+// claims validates a token issued by the env's auth service
+func (e *testEnv) claims(t *testing.T, token string) *JWTClaims {
+	t.Helper()
+	claims, err := e.auth.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	return claims
+}
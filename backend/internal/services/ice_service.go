@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"watchparty/internal/config"
+)
+
+// iceServersCacheKey holds the last Metered.ca response shared by all sessions
+const iceServersCacheKey = "sys:ice_servers"
+
+// ICEService provides WebRTC ICE server configuration, fetching short-lived
+// TURN credentials from Metered.ca when an API key is configured
+type ICEService struct {
+	redis      *RedisService
+	config     *config.Config
+	httpClient *http.Client
+}
+
+// NewICEService creates a new ICE service instance
+func NewICEService(redis *RedisService, cfg *config.Config) *ICEService {
+	return &ICEService{
+		redis:  redis,
+		config: cfg,
+		httpClient: &http.Client{
+			Timeout: cfg.IceFetchTimeout,
+		},
+	}
+}
+
+// GetIceServers returns the ICE servers a client should use. country is the
+// client's country code as reported by the edge (empty if unknown); servers
+// configured for its region take precedence. Otherwise Metered.ca
+// credentials are used, cached in Redis, and if they can't be fetched the
+// statically configured servers are returned instead.
+func (s *ICEService) GetIceServers(ctx context.Context, country string) []interface{} {
+	if servers, ok := s.regionalServers(country); ok {
+		return servers
+	}
+
+	if s.config.MeteredAPIKey == "" {
+		return s.config.IceServers
+	}
+
+	// Try to get from cache
+	if cached, err := s.redis.Get(ctx, iceServersCacheKey); err == nil {
+		var servers []interface{}
+		if err := json.Unmarshal([]byte(cached), &servers); err == nil {
+			return servers
+		}
+	}
+
+	servers, err := s.fetchWithRetry(ctx)
+	if err != nil {
+		slog.Error("Failed to fetch ICE servers", "error", err)
+		return s.config.IceServers
+	}
+
+	if data, err := json.Marshal(servers); err == nil {
+		s.redis.Set(ctx, iceServersCacheKey, string(data), s.cacheTTL(servers))
+	}
+
+	return servers
+}
+
+// cacheTTL returns how long fetched credentials may be served from cache:
+// ICE_CACHE_TTL, shortened to half the credentials' lifetime when Metered
+// reports one. A client handed cached credentials just before the cache
+// expires then still has half their validity left.
+func (s *ICEService) cacheTTL(servers []interface{}) time.Duration {
+	ttl := s.config.IceCacheTTL
+	if lifetime, ok := credentialLifetime(servers); ok && lifetime/2 < ttl {
+		ttl = lifetime / 2
+	}
+	return ttl
+}
+
+// credentialLifetime returns the shortest expiryInSeconds among the
+// servers, if any of them carry one
+func credentialLifetime(servers []interface{}) (time.Duration, bool) {
+	var shortest time.Duration
+	found := false
+	for _, server := range servers {
+		entry, ok := server.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		seconds, ok := entry["expiryInSeconds"].(float64)
+		if !ok || seconds <= 0 {
+			continue
+		}
+		lifetime := time.Duration(seconds * float64(time.Second))
+		if !found || lifetime < shortest {
+			shortest = lifetime
+			found = true
+		}
+	}
+	return shortest, found
+}
+
+// regionalServers looks up the servers configured for a country's region
+func (s *ICEService) regionalServers(country string) ([]interface{}, bool) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	// Cloudflare reports XX for unknown and T1 for Tor
+	if country == "" || country == "XX" || country == "T1" || len(s.config.IceServersByRegion) == 0 {
+		return nil, false
+	}
+
+	region := country
+	if mapped, ok := s.config.IceCountryRegions[country]; ok {
+		region = mapped
+	}
+	servers, ok := s.config.IceServersByRegion[region]
+	if !ok || len(servers) == 0 {
+		return nil, false
+	}
+	return servers, true
+}
+
+// fetchWithRetry calls the Metered API, retrying transient failures with
+// exponential backoff until the retry budget or the context runs out
+func (s *ICEService) fetchWithRetry(ctx context.Context) ([]interface{}, error) {
+	backoff := 200 * time.Millisecond
+
+	var lastErr error
+	for attempt := 0; attempt <= s.config.IceFetchRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		servers, retry, err := s.fetch(ctx)
+		if err == nil {
+			return servers, nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+		slog.Warn("ICE server fetch failed, retrying", "attempt", attempt+1, "error", err)
+	}
+	return nil, lastErr
+}
+
+// fetch requests TURN credentials once. The retry result reports whether
+// the failure is transient (network error, 429 or 5xx).
+func (s *ICEService) fetch(ctx context.Context) (servers []interface{}, retry bool, err error) {
+	endpoint := fmt.Sprintf("https://%s/api/v1/turn/credentials?apiKey=%s", s.config.MeteredDomain, url.QueryEscape(s.config.MeteredAPIKey))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		transient := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return nil, transient, fmt.Errorf("metered API returned status %d", resp.StatusCode)
+	}
+
+	// Metered returns a JSON array of {"urls", "username", "credential"} objects
+	if err := json.NewDecoder(resp.Body).Decode(&servers); err != nil {
+		return nil, false, fmt.Errorf("failed to decode ICE servers: %w", err)
+	}
+	return servers, false, nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"watchparty/internal/config"
+)
+
+var meteredServers = `[{"urls":"turn:relay.example.com:443","username":"u","credential":"c"}]`
+
+// fallbackServers stands in for the statically configured ICE servers
+var fallbackServers = []interface{}{map[string]interface{}{"urls": "stun:stun.example.com:3478"}}
+
+// newMeteredTestService returns an ICE service whose Metered API is served
+// by handler. It also returns a count of the requests the API received.
+func newMeteredTestService(t *testing.T, handler http.HandlerFunc, configure func(*config.Config)) (*ICEService, *testEnv, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parse server URL: %v", err)
+	}
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.MeteredAPIKey = "test-key"
+		cfg.MeteredDomain = serverURL.Host
+		cfg.IceServers = fallbackServers
+		cfg.IceFetchTimeout = 100 * time.Millisecond
+		cfg.IceFetchRetries = 2
+		if configure != nil {
+			configure(cfg)
+		}
+	})
+
+	ice := NewICEService(env.redis, env.cfg)
+	client := server.Client()
+	client.Timeout = env.cfg.IceFetchTimeout
+	ice.httpClient = client
+	return ice, env, &calls
+}
+
+func TestIceServersFromMeteredAreCached(t *testing.T) {
+	ice, env, calls := newMeteredTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("apiKey") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(meteredServers))
+	}, nil)
+
+	servers := ice.GetIceServers(context.Background(), "")
+	if len(servers) != 1 {
+		t.Fatalf("servers = %v, want the Metered credentials", servers)
+	}
+	if !env.mr.Exists(iceServersCacheKey) {
+		t.Error("credentials were not cached")
+	}
+
+	ice.GetIceServers(context.Background(), "")
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Metered API called %d times, want 1", got)
+	}
+}
+
+func TestSlowIceEndpointTimesOutAndFallsBack(t *testing.T) {
+	ice, env, calls := newMeteredTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}, func(cfg *config.Config) {
+		cfg.IceFetchRetries = 1
+	})
+
+	start := time.Now()
+	servers := ice.GetIceServers(context.Background(), "")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("GetIceServers took %v with a hung endpoint", elapsed)
+	}
+	if !reflect.DeepEqual(servers, fallbackServers) {
+		t.Errorf("servers = %v, want the configured fallback", servers)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Metered API called %d times, want 2 (one retry)", got)
+	}
+	if env.mr.Exists(iceServersCacheKey) {
+		t.Error("fallback servers were cached")
+	}
+}
+
+func TestIceEndpointTransientFailureIsRetried(t *testing.T) {
+	var failures atomic.Int32
+	ice, _, calls := newMeteredTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if failures.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(meteredServers))
+	}, nil)
+
+	servers := ice.GetIceServers(context.Background(), "")
+	if len(servers) != 1 || reflect.DeepEqual(servers, fallbackServers) {
+		t.Errorf("servers = %v, want the Metered credentials after retrying", servers)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("Metered API called %d times, want 3", got)
+	}
+}
+
+func TestIceEndpointPermanentFailureIsNotRetried(t *testing.T) {
+	ice, _, calls := newMeteredTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}, nil)
+
+	servers := ice.GetIceServers(context.Background(), "")
+	if !reflect.DeepEqual(servers, fallbackServers) {
+		t.Errorf("servers = %v, want the configured fallback", servers)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Metered API called %d times, want 1", got)
+	}
+}
+
+func TestIceFetchStopsWhenCallerGivesUp(t *testing.T) {
+	ice, _, _ := newMeteredTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}, func(cfg *config.Config) {
+		cfg.IceFetchRetries = 10
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	servers := ice.GetIceServers(ctx, "")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetIceServers kept retrying for %v after the caller's deadline", elapsed)
+	}
+	if !reflect.DeepEqual(servers, fallbackServers) {
+		t.Errorf("servers = %v, want the configured fallback", servers)
+	}
+}
+
+func TestIceCacheTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers string
+		want    time.Duration
+	}{
+		{"configured TTL", meteredServers, 10 * time.Minute},
+		{"long-lived credentials", `[{"urls":"turn:relay.example.com:443","expiryInSeconds":86400}]`, 10 * time.Minute},
+		// Half the shortest lifetime, so cached credentials stay valid for a while
+		{"short-lived credentials", `[{"urls":"turn:a.example.com","expiryInSeconds":600},{"urls":"turn:b.example.com","expiryInSeconds":120}]`, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ice, env, _ := newMeteredTestService(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.servers))
+			}, func(cfg *config.Config) {
+				cfg.IceCacheTTL = 10 * time.Minute
+			})
+
+			ice.GetIceServers(context.Background(), "")
+			if got := env.mr.TTL(iceServersCacheKey); got != tt.want {
+				t.Errorf("cache TTL = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrRedisUnavailable is returned without contacting Redis while the circuit
+// breaker is open after repeated failures
+var ErrRedisUnavailable = errors.New("redis unavailable")
+
+// circuitBreaker stops callers from hammering a Redis that is down. After
+// threshold consecutive failures it opens for cooldown, then lets calls
+// through again; the first success closes it.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	threshold int
+	cooldown  time.Duration
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a call may be attempted
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !time.Now().Before(b.openUntil)
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures >= b.threshold {
+		slog.Info("Redis recovered, closing circuit breaker")
+	}
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.threshold {
+		if b.failures == b.threshold {
+			slog.Warn("Redis failing repeatedly, opening circuit breaker", "cooldown", b.cooldown)
+		}
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// isTransientRedisError reports whether err is a connectivity problem worth
+// retrying, as opposed to a missing key or a command error
+func isTransientRedisError(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) || errors.Is(err, redis.ErrClosed) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// withRetry runs op, retrying transient failures with exponential backoff
+// and failing fast while the circuit breaker is open
+func (r *RedisService) withRetry(ctx context.Context, op func() error) error {
+	if !r.breaker.allow() {
+		return ErrRedisUnavailable
+	}
+
+	backoff := r.config.RedisRetryBackoff
+	var err error
+	for attempt := 0; attempt <= r.config.RedisRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		err = op()
+		if !isTransientRedisError(err) {
+			r.breaker.success()
+			return err
+		}
+		r.breaker.failure()
+		if !r.breaker.allow() {
+			break
+		}
+	}
+	return err
+}
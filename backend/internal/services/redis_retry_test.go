@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"watchparty/internal/config"
+)
+
+// errConnRefused is the kind of error a client returns while Redis is down
+var errConnRefused = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+// flakyOp fails with err for the first failures calls, then succeeds. It
+// counts every call.
+type flakyOp struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyOp) run() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+// newRetryService returns a RedisService for exercising withRetry; op
+// stands in for the client, so none is connected
+func newRetryService(retries, threshold int, cooldown time.Duration) *RedisService {
+	cfg := &config.Config{RedisRetries: retries, RedisRetryBackoff: time.Millisecond}
+	return &RedisService{config: cfg, breaker: newCircuitBreaker(threshold, cooldown)}
+}
+
+func TestWithRetryRecoversFromTransientFailures(t *testing.T) {
+	r := newRetryService(3, 10, time.Minute)
+	op := &flakyOp{failures: 2, err: errConnRefused}
+
+	if err := r.withRetry(context.Background(), op.run); err != nil {
+		t.Fatalf("withRetry() = %v, want success after recovery", err)
+	}
+	if op.calls != 3 {
+		t.Errorf("calls = %d, want 3", op.calls)
+	}
+}
+
+func TestWithRetryGivesUpAfterRetries(t *testing.T) {
+	r := newRetryService(2, 10, time.Minute)
+	op := &flakyOp{failures: 100, err: errConnRefused}
+
+	if err := r.withRetry(context.Background(), op.run); !errors.Is(err, errConnRefused) {
+		t.Errorf("withRetry() = %v, want the last failure", err)
+	}
+	if op.calls != 3 {
+		t.Errorf("calls = %d, want 3", op.calls)
+	}
+}
+
+func TestWithRetryReturnsCommandErrorsImmediately(t *testing.T) {
+	r := newRetryService(3, 10, time.Minute)
+	for _, err := range []error{redis.Nil, errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")} {
+		op := &flakyOp{failures: 1, err: err}
+		if got := r.withRetry(context.Background(), op.run); !errors.Is(got, err) {
+			t.Errorf("withRetry() = %v, want %v", got, err)
+		}
+		if op.calls != 1 {
+			t.Errorf("%v: calls = %d, want 1", err, op.calls)
+		}
+	}
+}
+
+func TestWithRetryStopsWhenContextEnds(t *testing.T) {
+	r := newRetryService(5, 10, time.Minute)
+	r.config.RedisRetryBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	op := &flakyOp{failures: 100, err: errConnRefused}
+
+	if err := r.withRetry(ctx, op.run); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("withRetry() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	cooldown := 50 * time.Millisecond
+	r := newRetryService(0, 2, cooldown)
+	down := &flakyOp{failures: 100, err: errConnRefused}
+
+	for i := 0; i < 2; i++ {
+		if err := r.withRetry(context.Background(), down.run); !errors.Is(err, errConnRefused) {
+			t.Fatalf("call %d: %v, want the connection error", i+1, err)
+		}
+	}
+
+	// Open: calls fail fast without reaching Redis
+	if err := r.withRetry(context.Background(), down.run); !errors.Is(err, ErrRedisUnavailable) {
+		t.Errorf("open breaker: %v, want %v", err, ErrRedisUnavailable)
+	}
+	if down.calls != 2 {
+		t.Errorf("open breaker let a call through: %d calls", down.calls)
+	}
+
+	// After the cooldown a call is tried again, and success closes it
+	time.Sleep(cooldown)
+	up := &flakyOp{}
+	if err := r.withRetry(context.Background(), up.run); err != nil {
+		t.Fatalf("after cooldown: %v", err)
+	}
+	if err := r.withRetry(context.Background(), up.run); err != nil || up.calls != 2 {
+		t.Errorf("closed breaker: err = %v, calls = %d", err, up.calls)
+	}
+}
+
+func TestGetSessionSurvivesRedisRestart(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.RedisRetries = 5
+		cfg.RedisRetryBackoff = 20 * time.Millisecond
+	})
+	created := env.createSession(t)
+
+	env.mr.Close()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		env.mr.Restart()
+	}()
+
+	// miniredis keeps its data across a restart
+	session, err := env.redis.GetSession(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetSession during a Redis restart: %v", err)
+	}
+	if session == nil || session.ID != created.ID {
+		t.Errorf("GetSession = %+v, want the session", session)
+	}
+}
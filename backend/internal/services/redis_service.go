@@ -2,8 +2,12 @@ package services
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -13,17 +17,18 @@ import (
 
 // RedisService handles all Redis operations
 type RedisService struct {
-	client *redis.Client
-	config *config.Config
+	client  *redis.Client
+	config  *config.Config
+	breaker *circuitBreaker
 }
 
 // NewRedisService creates a new Redis service instance
 func NewRedisService(cfg *config.Config) (*RedisService, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.RedisURL,
-		Password: cfg.RedisPassword,
-		DB:       cfg.RedisDB,
-	})
+	opts, err := redisOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -34,11 +39,49 @@ func NewRedisService(cfg *config.Config) (*RedisService, error) {
 	}
 
 	return &RedisService{
-		client: client,
-		config: cfg,
+		client:  client,
+		config:  cfg,
+		breaker: newCircuitBreaker(cfg.RedisBreakerThreshold, cfg.RedisBreakerCooldown),
 	}, nil
 }
 
+// redisOptions builds client options from the config. REDIS_URL may be a
+// plain host:port or a redis:// or rediss:// URL; a URL's password and
+// database win over REDIS_PASSWORD and REDIS_DB. rediss:// and REDIS_TLS
+// both enable TLS.
+func redisOptions(cfg *config.Config) (*redis.Options, error) {
+	var opts *redis.Options
+	if strings.Contains(cfg.RedisURL, "://") {
+		parsed, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+		}
+		opts = parsed
+		if opts.Password == "" {
+			opts.Password = cfg.RedisPassword
+		}
+	} else {
+		opts = &redis.Options{
+			Addr:     cfg.RedisURL,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}
+	}
+
+	if cfg.RedisTLS && opts.TLSConfig == nil {
+		host, _, _ := strings.Cut(opts.Addr, ":")
+		opts.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: host,
+		}
+	}
+	if opts.TLSConfig != nil && cfg.RedisTLSInsecureSkipVerify {
+		// Only for self-signed certificates in development
+		opts.TLSConfig.InsecureSkipVerify = true
+	}
+	return opts, nil
+}
+
 // Close closes the Redis connection
 func (r *RedisService) Close() error {
 	return r.client.Close()
@@ -53,6 +96,51 @@ func connectionsKey(sessionID string) string {
 	return fmt.Sprintf("connections:%s", sessionID)
 }
 
+func mediaStateKey(sessionID string) string {
+	return fmt.Sprintf("media_state:%s", sessionID)
+}
+
+func intermissionKey(sessionID string) string {
+	return fmt.Sprintf("intermission:%s", sessionID)
+}
+
+func playbackStateKey(sessionID string) string {
+	return fmt.Sprintf("playback_state:%s", sessionID)
+}
+
+func expiredKey(sessionID string) string {
+	return fmt.Sprintf("expired:%s", sessionID)
+}
+
+func presenceKey(sessionID string) string {
+	return fmt.Sprintf("presence:%s", sessionID)
+}
+
+func failedJoinsKey(sessionID string) string {
+	return fmt.Sprintf("join_failures:%s", sessionID)
+}
+
+func joinBlockKey(sessionID string) string {
+	return fmt.Sprintf("join_blocked:%s", sessionID)
+}
+
+func ipSessionsKey(ip string) string {
+	return fmt.Sprintf("ip_sessions:%s", ip)
+}
+
+// activeSessionsKey holds every active session, scored by expiry, for the
+// server-wide session cap. It has no TTL; lapsed entries are pruned as
+// sessions are added.
+const activeSessionsKey = "active_sessions"
+
+func mutedKey(sessionID string) string {
+	return fmt.Sprintf("muted:%s", sessionID)
+}
+
+func pendingJoinKey(sessionID, requestID string) string {
+	return fmt.Sprintf("pending_join:%s:%s", sessionID, requestID)
+}
+
 // SaveSession stores a session in Redis
 func (r *RedisService) SaveSession(ctx context.Context, session *models.Session) error {
 	data, err := json.Marshal(session)
@@ -63,7 +151,10 @@ func (r *RedisService) SaveSession(ctx context.Context, session *models.Session)
 	key := sessionKey(session.ID)
 	ttl := time.Until(session.ExpiresAt)
 
-	if err := r.client.Set(ctx, key, data, ttl).Err(); err != nil {
+	err = r.withRetry(ctx, func() error {
+		return r.client.Set(ctx, key, data, ttl).Err()
+	})
+	if err != nil {
 		return fmt.Errorf("failed to save session: %w", err)
 	}
 
@@ -73,7 +164,12 @@ func (r *RedisService) SaveSession(ctx context.Context, session *models.Session)
 // GetSession retrieves a session from Redis
 func (r *RedisService) GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
 	key := sessionKey(sessionID)
-	data, err := r.client.Get(ctx, key).Bytes()
+	var data []byte
+	err := r.withRetry(ctx, func() error {
+		var err error
+		data, err = r.client.Get(ctx, key).Bytes()
+		return err
+	})
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil // Session not found
@@ -89,28 +185,176 @@ func (r *RedisService) GetSession(ctx context.Context, sessionID string) (*model
 	return &session, nil
 }
 
-// DeleteSession removes a session from Redis
+// sessionTTL returns how long a session has left, for the keys that hang
+// off it. Extending a session pushes its expiry past SESSION_TTL, so writes
+// to those keys must not reset them to the configured TTL. It falls back to
+// SESSION_TTL if the session has no expiry or can't be read.
+func (r *RedisService) sessionTTL(ctx context.Context, sessionID string) time.Duration {
+	ttl, err := r.client.PTTL(ctx, sessionKey(sessionID)).Result()
+	if err != nil || ttl <= 0 {
+		return r.config.SessionTTL
+	}
+	return ttl
+}
+
+// GetSessions fetches several sessions with a single MGET. The result lines
+// up with ids, holding nil for sessions that are missing or unreadable.
+func (r *RedisService) GetSessions(ctx context.Context, ids []string) ([]*models.Session, error) {
+	sessions := make([]*models.Session, len(ids))
+	if len(ids) == 0 {
+		return sessions, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = sessionKey(id)
+	}
+
+	var values []interface{}
+	err := r.withRetry(ctx, func() error {
+		var err error
+		values, err = r.client.MGet(ctx, keys...).Result()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue // Session not found
+		}
+		var session models.Session
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			continue
+		}
+		sessions[i] = &session
+	}
+	return sessions, nil
+}
+
+// DeleteSession removes a session from Redis and releases its slots in the
+// creator's per-IP and the server-wide session counts
 func (r *RedisService) DeleteSession(ctx context.Context, sessionID string) error {
+	session, err := r.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
 	key := sessionKey(sessionID)
 	if err := r.client.Del(ctx, key).Err(); err != nil {
 		return fmt.Errorf("failed to delete session: %w", err)
 	}
+
+	if session != nil && session.CreatorIP != "" {
+		if err := r.UntrackIPSession(ctx, session.CreatorIP, sessionID); err != nil {
+			return err
+		}
+	}
+	return r.UntrackActiveSession(ctx, sessionID)
+}
+
+// TrackIPSession records an active session created from ip and returns how
+// many sessions from that IP are currently active, including this one.
+// Entries are scored by expiry, so sessions that lapse via TTL stop counting
+// without explicit cleanup.
+func (r *RedisService) TrackIPSession(ctx context.Context, ip, sessionID string, expiresAt time.Time) (int64, error) {
+	key := ipSessionsKey(ip)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	pipe := r.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", now)
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(expiresAt.Unix()), Member: sessionID})
+	count := pipe.ZCard(ctx, key)
+	pipe.Expire(ctx, key, time.Until(expiresAt))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to track session for IP: %w", err)
+	}
+	return count.Val(), nil
+}
+
+// UntrackIPSession removes a session from its creator's active session set
+func (r *RedisService) UntrackIPSession(ctx context.Context, ip, sessionID string) error {
+	if err := r.client.ZRem(ctx, ipSessionsKey(ip), sessionID).Err(); err != nil {
+		return fmt.Errorf("failed to untrack session for IP: %w", err)
+	}
+	return nil
+}
+
+// TrackActiveSession records an active session and returns how many
+// sessions are active on the server, including this one. Like
+// TrackIPSession, lapsed sessions drop out by their expiry score.
+func (r *RedisService) TrackActiveSession(ctx context.Context, sessionID string, expiresAt time.Time) (int64, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	pipe := r.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, activeSessionsKey, "-inf", now)
+	pipe.ZAdd(ctx, activeSessionsKey, redis.Z{Score: float64(expiresAt.Unix()), Member: sessionID})
+	count := pipe.ZCard(ctx, activeSessionsKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to track active session: %w", err)
+	}
+	return count.Val(), nil
+}
+
+// UntrackActiveSession removes a session from the server-wide active set
+func (r *RedisService) UntrackActiveSession(ctx context.Context, sessionID string) error {
+	if err := r.client.ZRem(ctx, activeSessionsKey, sessionID).Err(); err != nil {
+		return fmt.Errorf("failed to untrack active session: %w", err)
+	}
 	return nil
 }
 
-// AddParticipant adds a participant to a session atomically
-func (r *RedisService) AddParticipant(ctx context.Context, sessionID, userID string) error {
+// MarkSessionExpired leaves a short-lived tombstone for a session that was
+// closed, so later lookups can tell it apart from one that never existed
+func (r *RedisService) MarkSessionExpired(ctx context.Context, sessionID string) error {
+	if err := r.client.Set(ctx, expiredKey(sessionID), "1", r.config.ExpiredTombstoneTTL).Err(); err != nil {
+		return fmt.Errorf("failed to mark session expired: %w", err)
+	}
+	return nil
+}
+
+// IsSessionExpired reports whether a tombstone exists for a closed session
+func (r *RedisService) IsSessionExpired(ctx context.Context, sessionID string) (bool, error) {
+	n, err := r.client.Exists(ctx, expiredKey(sessionID)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check session tombstone: %w", err)
+	}
+	return n > 0, nil
+}
+
+// AddParticipant adds a participant to a session atomically and returns
+// the username they were assigned, disambiguated if already taken. This is
+// the only place capacity is enforced: the check runs in the same
+// transaction as the add, so concurrent joins get ErrSessionFull rather
+// than overfilling the session. If other joins keep winning the race, it
+// backs off between attempts and finally returns ErrSessionBusy.
+// If the last host left without a successor, the new participant becomes
+// host, which the returned bool reports.
+func (r *RedisService) AddParticipant(ctx context.Context, sessionID, userID, username string) (string, bool, error) {
+	assigned := username
+	promoted := false
 	key := sessionKey(sessionID)
-	maxRetries := 5
+	maxRetries := 10
 
 	// Retry loop for optimistic locking
 	for i := 0; i < maxRetries; i++ {
+		if i > 0 {
+			// Stagger retries so a burst of joins doesn't collide again
+			select {
+			case <-ctx.Done():
+				return "", false, ctx.Err()
+			case <-time.After(time.Duration(i)*5*time.Millisecond + time.Duration(rand.Intn(5))*time.Millisecond):
+			}
+		}
+
 		err := r.client.Watch(ctx, func(tx *redis.Tx) error {
 			// Get current session
 			data, err := tx.Get(ctx, key).Bytes()
 			if err != nil {
 				if err == redis.Nil {
-					return fmt.Errorf("session not found")
+					return ErrSessionNotFound
 				}
 				return err
 			}
@@ -121,19 +365,32 @@ func (r *RedisService) AddParticipant(ctx context.Context, sessionID, userID str
 			}
 
 			// Check if already a participant
+			promoted = false
 			for _, p := range session.Participants {
 				if p == userID {
+					if name, ok := session.Usernames[userID]; ok {
+						assigned = name
+					}
 					return nil // Already a participant
 				}
 			}
 
 			// Check max participants
-			if len(session.Participants) >= session.MaxParticipants {
-				return fmt.Errorf("session is full")
+			if session.IsFull() {
+				return ErrSessionFull
 			}
 
 			// Add participant
 			session.Participants = append(session.Participants, userID)
+			assigned = session.UniqueUsername(username)
+			if session.Usernames == nil {
+				session.Usernames = make(map[string]string)
+			}
+			session.Usernames[userID] = assigned
+			if session.HostID == "" {
+				session.HostID = userID
+				promoted = true
+			}
 
 			newData, err := json.Marshal(session)
 			if err != nil {
@@ -149,16 +406,16 @@ func (r *RedisService) AddParticipant(ctx context.Context, sessionID, userID str
 		}, key)
 
 		if err == nil {
-			return nil // Success
+			return assigned, promoted, nil // Success
 		}
 		if err == redis.TxFailedErr {
 			// Optimistic lock failed, retry
 			continue
 		}
-		return err // Other error
+		return "", false, err // Other error
 	}
 
-	return fmt.Errorf("failed to add participant after retries")
+	return "", false, ErrSessionBusy
 }
 
 // RemoveParticipant removes a participant from a session atomically
@@ -197,6 +454,8 @@ func (r *RedisService) RemoveParticipant(ctx context.Context, sessionID, userID
 			}
 
 			session.Participants = newParticipants
+			delete(session.Usernames, userID)
+			session.Controllers = removeString(session.Controllers, userID)
 			newData, err := json.Marshal(session)
 			if err != nil {
 				return err
@@ -220,26 +479,664 @@ func (r *RedisService) RemoveParticipant(ctx context.Context, sessionID, userID
 	return fmt.Errorf("failed to remove participant after retries")
 }
 
+// updateSession applies fn to a session atomically using optimistic locking.
+// fn may return an error to abort the update without writing.
+func (r *RedisService) updateSession(ctx context.Context, sessionID string, fn func(session *models.Session) error) error {
+	key := sessionKey(sessionID)
+	maxRetries := 5
+
+	for i := 0; i < maxRetries; i++ {
+		err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, key).Bytes()
+			if err != nil {
+				if err == redis.Nil {
+					return ErrSessionNotFound
+				}
+				return err
+			}
+
+			var session models.Session
+			if err := json.Unmarshal(data, &session); err != nil {
+				return err
+			}
+
+			if err := fn(&session); err != nil {
+				return err
+			}
+
+			newData, err := json.Marshal(session)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, key, newData, time.Until(session.ExpiresAt))
+				return nil
+			})
+			return err
+		}, key)
+
+		if err == nil {
+			return nil
+		}
+		if err == redis.TxFailedErr {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("failed to update session after retries")
+}
+
+// SetSessionLocked sets whether a session accepts new joins
+func (r *RedisService) SetSessionLocked(ctx context.Context, sessionID string, locked bool) error {
+	return r.updateSession(ctx, sessionID, func(session *models.Session) error {
+		session.Locked = locked
+		return nil
+	})
+}
+
+// UpdatePassword replaces a session's password hash. With revoke set,
+// tokens issued before now stop working and every participant but the host
+// is removed, so they must join again with the new password. It returns the
+// removed user IDs.
+func (r *RedisService) UpdatePassword(ctx context.Context, sessionID, passwordHash string, revoke bool) ([]string, error) {
+	var removed []string
+	err := r.updateSession(ctx, sessionID, func(session *models.Session) error {
+		session.PasswordHash = passwordHash
+		removed = nil
+		if !revoke {
+			return nil
+		}
+
+		session.TokensValidFrom = time.Now().Unix()
+		for _, p := range session.Participants {
+			if p != session.HostID {
+				removed = append(removed, p)
+				delete(session.Usernames, p)
+			}
+		}
+		session.Participants = []string{session.HostID}
+		session.Controllers = nil
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// RotateSession moves a session to newID and returns it. The session record
+// is rewritten under the new key and the old one deleted in one transaction,
+// then the chat history, presence, media and playback state, mutes,
+// intermission and audit trail are renamed along with it, keeping their
+// TTLs. Live connections are not carried over: clients reconnect under the
+// new ID and register again.
+func (r *RedisService) RotateSession(ctx context.Context, oldID, newID string) (*models.Session, error) {
+	oldKey := sessionKey(oldID)
+	var rotated *models.Session
+
+	err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, oldKey).Bytes()
+		if err != nil {
+			if err == redis.Nil {
+				return ErrSessionNotFound
+			}
+			return err
+		}
+
+		var session models.Session
+		if err := json.Unmarshal(data, &session); err != nil {
+			return err
+		}
+		session.ID = newID
+
+		newData, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, sessionKey(newID), newData, time.Until(session.ExpiresAt))
+			pipe.Del(ctx, oldKey, connectionsKey(oldID))
+			if session.CreatorIP != "" {
+				// Keep the session counted against its creator under the new ID
+				ipKey := ipSessionsKey(session.CreatorIP)
+				pipe.ZRem(ctx, ipKey, oldID)
+				pipe.ZAdd(ctx, ipKey, redis.Z{Score: float64(session.ExpiresAt.Unix()), Member: newID})
+			}
+			if r.config.MaxTotalSessions > 0 {
+				pipe.ZRem(ctx, activeSessionsKey, oldID)
+				pipe.ZAdd(ctx, activeSessionsKey, redis.Z{Score: float64(session.ExpiresAt.Unix()), Member: newID})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		rotated = &session
+		return nil
+	}, oldKey)
+	if err != nil {
+		if err == ErrSessionNotFound {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to rotate session: %w", err)
+	}
+
+	// Most sessions lack some of these, so missing keys are skipped
+	keys := []func(string) string{chatKey, transcriptKey, presenceKey, mediaStateKey, mutedKey, intermissionKey, playbackStateKey, auditKey}
+	for _, key := range keys {
+		if err := renameIfExistsScript.Run(ctx, r.client, []string{key(oldID), key(newID)}).Err(); err != nil {
+			return rotated, fmt.Errorf("failed to move session data: %w", err)
+		}
+	}
+	return rotated, nil
+}
+
+// renameIfExistsScript renames KEYS[1] to KEYS[2] if KEYS[1] exists. A
+// plain RENAME fails on a missing key.
+var renameIfExistsScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('RENAME', KEYS[1], KEYS[2])
+	return 1
+end
+return 0
+`)
+
+// UpdateSessionMedia sets the now-playing media of a session
+func (r *RedisService) UpdateSessionMedia(ctx context.Context, sessionID, title, mediaURL string) error {
+	return r.updateSession(ctx, sessionID, func(session *models.Session) error {
+		session.MediaTitle = title
+		session.MediaURL = mediaURL
+		return nil
+	})
+}
+
+// SetSessionController grants or revokes playback control for a participant
+// and returns the updated controller list
+func (r *RedisService) SetSessionController(ctx context.Context, sessionID, userID string, enabled bool) ([]string, error) {
+	var controllers []string
+	err := r.updateSession(ctx, sessionID, func(session *models.Session) error {
+		found := false
+		for _, p := range session.Participants {
+			if p == userID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrParticipantNotFound
+		}
+
+		session.Controllers = removeString(session.Controllers, userID)
+		if enabled {
+			session.Controllers = append(session.Controllers, userID)
+		}
+		controllers = session.Controllers
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return controllers, nil
+}
+
+// SetUserMuted adds or removes a user from a session's chat mute list. The
+// list lives in Redis so mutes survive reconnects.
+func (r *RedisService) SetUserMuted(ctx context.Context, sessionID, userID string, muted bool) error {
+	key := mutedKey(sessionID)
+	if !muted {
+		if err := r.client.SRem(ctx, key, userID).Err(); err != nil {
+			return fmt.Errorf("failed to unmute user: %w", err)
+		}
+		return nil
+	}
+
+	if err := r.client.SAdd(ctx, key, userID).Err(); err != nil {
+		return fmt.Errorf("failed to mute user: %w", err)
+	}
+	r.client.Expire(ctx, key, r.sessionTTL(ctx, sessionID))
+	return nil
+}
+
+// IsUserMuted reports whether the host has muted a user's chat
+func (r *RedisService) IsUserMuted(ctx context.Context, sessionID, userID string) (bool, error) {
+	muted, err := r.client.SIsMember(ctx, mutedKey(sessionID), userID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check mute: %w", err)
+	}
+	return muted, nil
+}
+
+// removeString returns list without any occurrence of s
+func removeString(list []string, s string) []string {
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != s {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtendSession pushes a session's expiry back by increment, capped at
+// maxLifetime from creation, and carries the new expiry over to the
+// session's auxiliary keys. It returns the new expiry.
+func (r *RedisService) ExtendSession(ctx context.Context, sessionID string, increment, maxLifetime time.Duration) (time.Time, error) {
+	var expiresAt time.Time
+	var creatorIP string
+	err := r.updateSession(ctx, sessionID, func(session *models.Session) error {
+		limit := session.CreatedAt.Add(maxLifetime)
+		if !session.ExpiresAt.Before(limit) {
+			return ErrExtensionLimitReached
+		}
+
+		expiresAt = session.ExpiresAt.Add(increment)
+		if expiresAt.After(limit) {
+			expiresAt = limit
+		}
+		session.ExpiresAt = expiresAt
+		creatorIP = session.CreatorIP
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ttl := time.Until(expiresAt)
+	pipe := r.client.Pipeline()
+	for _, key := range []string{chatKey(sessionID), connectionsKey(sessionID), presenceKey(sessionID), mediaStateKey(sessionID), mutedKey(sessionID), intermissionKey(sessionID), playbackStateKey(sessionID)} {
+		pipe.Expire(ctx, key, ttl)
+	}
+	if creatorIP != "" {
+		// Keep the session counted against its creator for the new lifetime
+		ipKey := ipSessionsKey(creatorIP)
+		pipe.ZAddXX(ctx, ipKey, redis.Z{Score: float64(expiresAt.Unix()), Member: sessionID})
+		pipe.ExpireGT(ctx, ipKey, ttl)
+	}
+	pipe.ZAddXX(ctx, activeSessionsKey, redis.Z{Score: float64(expiresAt.Unix()), Member: sessionID})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return expiresAt, fmt.Errorf("failed to extend session keys: %w", err)
+	}
+	return expiresAt, nil
+}
+
+// TransferHost hands host privileges from fromUserID to the first remaining
+// participant. It returns the new host ID, or an empty string if fromUserID
+// was not the host or nobody is left to take over. In the latter case the
+// session is left without a host until the next participant joins.
+func (r *RedisService) TransferHost(ctx context.Context, sessionID, fromUserID string) (string, error) {
+	return r.transferHost(ctx, sessionID, fromUserID, nil)
+}
+
+// HandOffHost is TransferHost restricted to participants in online, for
+// when the host has gone offline rather than left. Nobody takes over if no
+// other participant is online.
+func (r *RedisService) HandOffHost(ctx context.Context, sessionID, fromUserID string, online map[string]bool) (string, error) {
+	return r.transferHost(ctx, sessionID, fromUserID, func(userID string) bool {
+		return online[userID]
+	})
+}
+
+// transferHost moves host privileges to the first other participant that
+// eligible accepts; a nil eligible accepts anyone. A host who has left the
+// participant list is cleared even when nobody can take over.
+func (r *RedisService) transferHost(ctx context.Context, sessionID, fromUserID string, eligible func(string) bool) (string, error) {
+	var newHostID string
+	err := r.updateSession(ctx, sessionID, func(session *models.Session) error {
+		newHostID = ""
+		if session.HostID != fromUserID {
+			return nil
+		}
+		for _, p := range session.Participants {
+			if p != fromUserID && (eligible == nil || eligible(p)) {
+				newHostID = p
+				break
+			}
+		}
+		if newHostID != "" {
+			session.HostID = newHostID
+		} else if !containsString(session.Participants, fromUserID) {
+			session.HostID = ""
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return newHostID, nil
+}
+
+// RecordFailedJoin increments the failed join counter for a session and
+// blocks further joins once the configured threshold is reached
+func (r *RedisService) RecordFailedJoin(ctx context.Context, sessionID string) (int64, error) {
+	key := failedJoinsKey(sessionID)
+	// The counting window starts on the first failure, in the same
+	// transaction so the counter can't be left without an expiry
+	count, _, err := r.IncrWithExpiry(ctx, key, r.config.JoinFailureWindow)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record failed join: %w", err)
+	}
+
+	if count >= int64(r.config.JoinMaxFailedAttempts) {
+		if err := r.client.Set(ctx, joinBlockKey(sessionID), "1", r.config.JoinLockoutDuration).Err(); err != nil {
+			return count, fmt.Errorf("failed to block joins: %w", err)
+		}
+		r.client.Del(ctx, key)
+	}
+
+	return count, nil
+}
+
+// IsJoinBlocked reports whether joins to a session are temporarily blocked
+func (r *RedisService) IsJoinBlocked(ctx context.Context, sessionID string) (bool, error) {
+	n, err := r.client.Exists(ctx, joinBlockKey(sessionID)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check join block: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ResetFailedJoins clears the failed join counter for a session
+func (r *RedisService) ResetFailedJoins(ctx context.Context, sessionID string) error {
+	if err := r.client.Del(ctx, failedJoinsKey(sessionID)).Err(); err != nil {
+		return fmt.Errorf("failed to reset failed joins: %w", err)
+	}
+	return nil
+}
+
+// SavePendingJoin stores a join request awaiting host approval. The request
+// expires on its own if the host never answers.
+func (r *RedisService) SavePendingJoin(ctx context.Context, pending *models.PendingJoin) error {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending join: %w", err)
+	}
+	key := pendingJoinKey(pending.SessionID, pending.ID)
+	if err := r.client.Set(ctx, key, data, r.config.JoinApprovalTimeout).Err(); err != nil {
+		return fmt.Errorf("failed to save pending join: %w", err)
+	}
+	return nil
+}
+
+// GetPendingJoin retrieves a join request, returning nil if it has expired
+func (r *RedisService) GetPendingJoin(ctx context.Context, sessionID, requestID string) (*models.PendingJoin, error) {
+	data, err := r.client.Get(ctx, pendingJoinKey(sessionID, requestID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending join: %w", err)
+	}
+
+	var pending models.PendingJoin
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pending join: %w", err)
+	}
+	return &pending, nil
+}
+
+// ResolvePendingJoin records the host's decision on a join request without
+// extending its expiry. The key is watched so a request claimed or resolved
+// in the meantime is never written back.
+func (r *RedisService) ResolvePendingJoin(ctx context.Context, sessionID, requestID string, approved bool) error {
+	key := pendingJoinKey(sessionID, requestID)
+	maxRetries := 5
+
+	for i := 0; i < maxRetries; i++ {
+		err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, key).Bytes()
+			if err != nil {
+				if err == redis.Nil {
+					return ErrJoinRequestNotFound
+				}
+				return err
+			}
+
+			var pending models.PendingJoin
+			if err := json.Unmarshal(data, &pending); err != nil {
+				return fmt.Errorf("failed to unmarshal pending join: %w", err)
+			}
+			if pending.Status != models.JoinStatusPending {
+				return ErrJoinRequestNotFound
+			}
+
+			pending.Status = models.JoinStatusDenied
+			if approved {
+				pending.Status = models.JoinStatusApproved
+			}
+			newData, err := json.Marshal(pending)
+			if err != nil {
+				return fmt.Errorf("failed to marshal pending join: %w", err)
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.SetArgs(ctx, key, newData, redis.SetArgs{KeepTTL: true})
+				return nil
+			})
+			return err
+		}, key)
+
+		if err == nil {
+			return nil
+		}
+		if err == redis.TxFailedErr {
+			// Claimed or resolved meanwhile; the next read sees which
+			continue
+		}
+		if err == ErrJoinRequestNotFound {
+			return err
+		}
+		return fmt.Errorf("failed to resolve pending join: %w", err)
+	}
+	return fmt.Errorf("failed to resolve pending join after retries")
+}
+
+// ClaimPendingJoin atomically removes a join request so an approval can only
+// be redeemed once. It returns nil if another caller got there first.
+func (r *RedisService) ClaimPendingJoin(ctx context.Context, sessionID, requestID string) (*models.PendingJoin, error) {
+	data, err := r.client.GetDel(ctx, pendingJoinKey(sessionID, requestID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim pending join: %w", err)
+	}
+
+	var pending models.PendingJoin
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pending join: %w", err)
+	}
+	return &pending, nil
+}
+
+// connectionMember encodes a connection as "userID:connectionID" so live
+// connections can be attributed to participants
+func connectionMember(userID, connectionID string) string {
+	return userID + ":" + connectionID
+}
+
+// reserveConnectionScript adds a connection to a session's set unless the
+// session or the user is at its limit (0 disables a limit). It returns 0
+// on success, 1 for the session limit and 2 for the user limit.
+var reserveConnectionScript = redis.NewScript(`
+local members = redis.call('SMEMBERS', KEYS[1])
+local maxSession = tonumber(ARGV[3])
+local maxUser = tonumber(ARGV[4])
+if maxSession > 0 and #members >= maxSession then
+	return 1
+end
+if maxUser > 0 then
+	local prefix = ARGV[1] .. ':'
+	local count = 0
+	for _, member in ipairs(members) do
+		if string.sub(member, 1, #prefix) == prefix then
+			count = count + 1
+		end
+	end
+	if count >= maxUser then
+		return 2
+	end
+end
+redis.call('SADD', KEYS[1], ARGV[1] .. ':' .. ARGV[2])
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+return 0
+`)
+
+// ReserveConnection records a WebSocket connection if neither the session
+// nor the user has reached its connection limit. Checking and adding happen
+// in one script, so concurrent connections can't both slip under a limit.
+func (r *RedisService) ReserveConnection(ctx context.Context, sessionID, userID, connectionID string, maxPerSession, maxPerUser int) error {
+	// EXPIRE takes whole seconds, and 0 would delete the set
+	ttl := int64(r.sessionTTL(ctx, sessionID) / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+	result, err := reserveConnectionScript.Run(ctx, r.client, []string{connectionsKey(sessionID)},
+		userID, connectionID, maxPerSession, maxPerUser, ttl).Int()
+	if err != nil {
+		return fmt.Errorf("failed to reserve connection: %w", err)
+	}
+	switch result {
+	case 1:
+		return ErrSessionConnectionLimit
+	case 2:
+		return ErrUserConnectionLimit
+	}
+	return nil
+}
+
 // AddConnection tracks an active WebSocket connection
-func (r *RedisService) AddConnection(ctx context.Context, sessionID, connectionID string) error {
+func (r *RedisService) AddConnection(ctx context.Context, sessionID, userID, connectionID string) error {
 	key := connectionsKey(sessionID)
-	if err := r.client.SAdd(ctx, key, connectionID).Err(); err != nil {
+	if err := r.client.SAdd(ctx, key, connectionMember(userID, connectionID)).Err(); err != nil {
 		return fmt.Errorf("failed to add connection: %w", err)
 	}
-	// Set TTL on connections set
-	r.client.Expire(ctx, key, r.config.SessionTTL)
+	// Expire along with the session
+	r.client.Expire(ctx, key, r.sessionTTL(ctx, sessionID))
 	return nil
 }
 
 // RemoveConnection removes a WebSocket connection
-func (r *RedisService) RemoveConnection(ctx context.Context, sessionID, connectionID string) error {
+func (r *RedisService) RemoveConnection(ctx context.Context, sessionID, userID, connectionID string) error {
 	key := connectionsKey(sessionID)
-	if err := r.client.SRem(ctx, key, connectionID).Err(); err != nil {
+	if err := r.client.SRem(ctx, key, connectionMember(userID, connectionID)).Err(); err != nil {
 		return fmt.Errorf("failed to remove connection: %w", err)
 	}
 	return nil
 }
 
+// Connection identifies one WebSocket connection to a session
+type Connection struct {
+	SessionID string
+	UserID    string
+	ID        string
+}
+
+// RemoveConnections removes many WebSocket connections and records their
+// users as seen now, in a single round trip
+func (r *RedisService) RemoveConnections(ctx context.Context, conns []Connection) error {
+	if len(conns) == 0 {
+		return nil
+	}
+	now := time.Now().Unix()
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, conn := range conns {
+			pipe.SRem(ctx, connectionsKey(conn.SessionID), connectionMember(conn.UserID, conn.ID))
+			pipe.HSet(ctx, presenceKey(conn.SessionID), conn.UserID, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove connections: %w", err)
+	}
+	return nil
+}
+
+// GetConnectedUsers returns the set of user IDs with at least one live connection
+func (r *RedisService) GetConnectedUsers(ctx context.Context, sessionID string) (map[string]bool, error) {
+	members, err := r.client.SMembers(ctx, connectionsKey(sessionID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connections: %w", err)
+	}
+
+	users := make(map[string]bool, len(members))
+	for _, m := range members {
+		if userID, _, ok := strings.Cut(m, ":"); ok {
+			users[userID] = true
+		}
+	}
+	return users, nil
+}
+
+// TouchPresence records that a participant was seen now
+func (r *RedisService) TouchPresence(ctx context.Context, sessionID, userID string) error {
+	key := presenceKey(sessionID)
+	if err := r.client.HSet(ctx, key, userID, time.Now().Unix()).Err(); err != nil {
+		return fmt.Errorf("failed to update presence: %w", err)
+	}
+	r.client.Expire(ctx, key, r.sessionTTL(ctx, sessionID))
+	return nil
+}
+
+// GetPresence returns when each participant was last seen
+func (r *RedisService) GetPresence(ctx context.Context, sessionID string) (map[string]time.Time, error) {
+	values, err := r.client.HGetAll(ctx, presenceKey(sessionID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get presence: %w", err)
+	}
+
+	presence := make(map[string]time.Time, len(values))
+	for userID, v := range values {
+		if ts, err := strconv.ParseInt(v, 10, 64); err == nil {
+			presence[userID] = time.Unix(ts, 0)
+		}
+	}
+	return presence, nil
+}
+
+// ListSessions returns all stored sessions. Sessions that expire or fail
+// to decode while scanning are skipped.
+func (r *RedisService) ListSessions(ctx context.Context) ([]*models.Session, error) {
+	ids, err := r.ListSessionIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*models.Session, 0, len(ids))
+	for _, id := range ids {
+		session, err := r.GetSession(ctx, id)
+		if err != nil || session == nil {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// ListSessionIDs returns the IDs of all stored sessions
+func (r *RedisService) ListSessionIDs(ctx context.Context) ([]string, error) {
+	var ids []string
+	iter := r.client.Scan(ctx, 0, sessionKey("*"), 100).Iterator()
+	for iter.Next(ctx) {
+		ids = append(ids, strings.TrimPrefix(iter.Val(), sessionKey("")))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan sessions: %w", err)
+	}
+	return ids, nil
+}
+
 // GetConnectionCount returns the number of active connections for a session
 func (r *RedisService) GetConnectionCount(ctx context.Context, sessionID string) (int64, error) {
 	key := connectionsKey(sessionID)
@@ -257,12 +1154,37 @@ func (r *RedisService) Health(ctx context.Context) error {
 
 // Set stores a key-value pair with expiration
 func (r *RedisService) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	return r.client.Set(ctx, key, value, expiration).Err()
+	return r.withRetry(ctx, func() error {
+		return r.client.Set(ctx, key, value, expiration).Err()
+	})
 }
 
 // Get retrieves a string value for a key
 func (r *RedisService) Get(ctx context.Context, key string) (string, error) {
-	return r.client.Get(ctx, key).Result()
+	var value string
+	err := r.withRetry(ctx, func() error {
+		var err error
+		value, err = r.client.Get(ctx, key).Result()
+		return err
+	})
+	return value, err
+}
+
+// IncrWithExpiry increments a counter and starts its expiry on first use.
+// It returns the new count and the remaining time until the counter resets.
+func (r *RedisService) IncrWithExpiry(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	var incr *redis.IntCmd
+	var ttl *redis.DurationCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.ExpireNX(ctx, key, window)
+		ttl = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to increment counter: %w", err)
+	}
+	return incr.Val(), ttl.Val(), nil
 }
 
 // Chat Persistence based on session ID
@@ -270,7 +1192,13 @@ func chatKey(sessionID string) string {
 	return fmt.Sprintf("chat:%s", sessionID)
 }
 
-// SaveChatMessage stores a chat message in a Redis list
+// transcriptKey holds the longer chat log hosts can export
+func transcriptKey(sessionID string) string {
+	return fmt.Sprintf("transcript:%s", sessionID)
+}
+
+// SaveChatMessage stores a chat message in a Redis list, and in the
+// session's transcript when transcripts are enabled
 func (r *RedisService) SaveChatMessage(ctx context.Context, sessionID string, message []byte) error {
 	key := chatKey(sessionID)
 	// Push to right
@@ -279,11 +1207,45 @@ func (r *RedisService) SaveChatMessage(ctx context.Context, sessionID string, me
 	}
 	// Limit history to 50 messages
 	r.client.LTrim(ctx, key, -50, -1)
-	// Set expiry same as session (approx) - actually we should match session TTL logic or just set a long TTL
-	r.client.Expire(ctx, key, r.config.SessionTTL)
+	// Expire along with the session
+	r.client.Expire(ctx, key, r.sessionTTL(ctx, sessionID))
+
+	if limit := r.config.TranscriptMaxMessages; limit > 0 {
+		tkey := transcriptKey(sessionID)
+		pipe := r.client.Pipeline()
+		pipe.RPush(ctx, tkey, message)
+		pipe.LTrim(ctx, tkey, int64(-limit), -1)
+		pipe.Expire(ctx, tkey, r.config.TranscriptTTL)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to save transcript: %w", err)
+		}
+	}
+	return nil
+}
+
+// ClearChatHistory removes all chat messages for a session, including its
+// transcript
+func (r *RedisService) ClearChatHistory(ctx context.Context, sessionID string) error {
+	if err := r.client.Del(ctx, chatKey(sessionID), transcriptKey(sessionID)).Err(); err != nil {
+		return fmt.Errorf("failed to clear chat history: %w", err)
+	}
 	return nil
 }
 
+// GetTranscript returns every message in a session's transcript, oldest first
+func (r *RedisService) GetTranscript(ctx context.Context, sessionID string) ([][]byte, error) {
+	results, err := r.client.LRange(ctx, transcriptKey(sessionID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transcript: %w", err)
+	}
+
+	messages := make([][]byte, len(results))
+	for i, res := range results {
+		messages[i] = []byte(res)
+	}
+	return messages, nil
+}
+
 // GetChatHistory retrieves recent chat messages
 func (r *RedisService) GetChatHistory(ctx context.Context, sessionID string) ([][]byte, error) {
 	key := chatKey(sessionID)
@@ -299,3 +1261,188 @@ func (r *RedisService) GetChatHistory(ctx context.Context, sessionID string) ([]
 	}
 	return messages, nil
 }
+
+// GetChatMessage finds a chat message in the history by its ID
+func (r *RedisService) GetChatMessage(ctx context.Context, sessionID, messageID string) (*models.ChatPayload, error) {
+	raw, payload, err := r.findChatMessage(ctx, sessionID, messageID)
+	if err != nil || raw == "" {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// DeleteChatMessage removes a chat message from the history by its ID
+func (r *RedisService) DeleteChatMessage(ctx context.Context, sessionID, messageID string) error {
+	raw, _, err := r.findChatMessage(ctx, sessionID, messageID)
+	if err != nil {
+		return err
+	}
+	if raw == "" {
+		return ErrMessageNotFound
+	}
+
+	if err := r.client.LRem(ctx, chatKey(sessionID), 1, raw).Err(); err != nil {
+		return fmt.Errorf("failed to delete chat message: %w", err)
+	}
+	// Deleted messages shouldn't resurface in an export
+	if err := r.client.LRem(ctx, transcriptKey(sessionID), 1, raw).Err(); err != nil {
+		return fmt.Errorf("failed to delete chat message from transcript: %w", err)
+	}
+	return nil
+}
+
+// findChatMessage returns the raw stored entry and decoded payload for a
+// chat message, or an empty string if it isn't in the history
+func (r *RedisService) findChatMessage(ctx context.Context, sessionID, messageID string) (string, *models.ChatPayload, error) {
+	results, err := r.client.LRange(ctx, chatKey(sessionID), 0, -1).Result()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get chat history: %w", err)
+	}
+
+	for _, res := range results {
+		var msg models.WebSocketMessage
+		if err := json.Unmarshal([]byte(res), &msg); err != nil {
+			continue
+		}
+		var payload models.ChatPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			continue
+		}
+		if payload.ID == messageID {
+			return res, &payload, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// SaveMediaState stores the latest media state message for a user
+func (r *RedisService) SaveMediaState(ctx context.Context, sessionID, userID string, message []byte) error {
+	key := mediaStateKey(sessionID)
+	if err := r.client.HSet(ctx, key, userID, message).Err(); err != nil {
+		return fmt.Errorf("failed to save media state: %w", err)
+	}
+	r.client.Expire(ctx, key, r.sessionTTL(ctx, sessionID))
+	return nil
+}
+
+// GetMediaStates returns the latest media state message for every user
+func (r *RedisService) GetMediaStates(ctx context.Context, sessionID string) ([][]byte, error) {
+	values, err := r.client.HVals(ctx, mediaStateKey(sessionID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media states: %w", err)
+	}
+
+	messages := make([][]byte, len(values))
+	for i, v := range values {
+		messages[i] = []byte(v)
+	}
+	return messages, nil
+}
+
+// ClearMediaState removes a user's stored media state
+func (r *RedisService) ClearMediaState(ctx context.Context, sessionID, userID string) error {
+	if err := r.client.HDel(ctx, mediaStateKey(sessionID), userID).Err(); err != nil {
+		return fmt.Errorf("failed to clear media state: %w", err)
+	}
+	return nil
+}
+
+// SetIntermission stores the message announcing a session's current
+// intermission so it can be replayed to late joiners
+func (r *RedisService) SetIntermission(ctx context.Context, sessionID string, message []byte) error {
+	if err := r.client.Set(ctx, intermissionKey(sessionID), message, r.sessionTTL(ctx, sessionID)).Err(); err != nil {
+		return fmt.Errorf("failed to save intermission: %w", err)
+	}
+	return nil
+}
+
+// GetIntermission returns the stored intermission message, or nil when the
+// session isn't in an intermission
+func (r *RedisService) GetIntermission(ctx context.Context, sessionID string) ([]byte, error) {
+	data, err := r.client.Get(ctx, intermissionKey(sessionID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get intermission: %w", err)
+	}
+	return data, nil
+}
+
+// ClearIntermission ends a session's intermission
+func (r *RedisService) ClearIntermission(ctx context.Context, sessionID string) error {
+	if err := r.client.Del(ctx, intermissionKey(sessionID)).Err(); err != nil {
+		return fmt.Errorf("failed to clear intermission: %w", err)
+	}
+	return nil
+}
+
+// SavePlaybackState stores the message carrying a session's latest
+// playback state so it can be replayed to late joiners
+func (r *RedisService) SavePlaybackState(ctx context.Context, sessionID string, message []byte) error {
+	if err := r.client.Set(ctx, playbackStateKey(sessionID), message, r.sessionTTL(ctx, sessionID)).Err(); err != nil {
+		return fmt.Errorf("failed to save playback state: %w", err)
+	}
+	return nil
+}
+
+// GetPlaybackState returns the stored playback state message, or nil when
+// the host hasn't sent one
+func (r *RedisService) GetPlaybackState(ctx context.Context, sessionID string) ([]byte, error) {
+	data, err := r.client.Get(ctx, playbackStateKey(sessionID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get playback state: %w", err)
+	}
+	return data, nil
+}
+
+// Audit trail based on session ID
+func auditKey(sessionID string) string {
+	return fmt.Sprintf("audit:%s", sessionID)
+}
+
+// AppendAudit adds an entry to a session's audit trail, keeping only the most
+// recent AuditLogSize entries. The trail outlives the session so closed rooms
+// can still be investigated.
+func (r *RedisService) AppendAudit(ctx context.Context, sessionID string, entry *models.AuditEntry) error {
+	if entry.Timestamp == 0 {
+		entry.Timestamp = time.Now().UnixMilli()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	key := auditKey(sessionID)
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, data)
+		pipe.LTrim(ctx, key, int64(-r.config.AuditLogSize), -1)
+		pipe.Expire(ctx, key, r.config.AuditLogTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	return nil
+}
+
+// GetAudit returns a session's audit trail, oldest first
+func (r *RedisService) GetAudit(ctx context.Context, sessionID string) ([]models.AuditEntry, error) {
+	results, err := r.client.LRange(ctx, auditKey(sessionID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit trail: %w", err)
+	}
+
+	entries := make([]models.AuditEntry, 0, len(results))
+	for _, res := range results {
+		var entry models.AuditEntry
+		if err := json.Unmarshal([]byte(res), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -3,9 +3,10 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
-	"os"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,37 +17,61 @@ import (
 
 // SessionService handles session business logic
 type SessionService struct {
-	redis  *RedisService
-	auth   *AuthService
-	config *config.Config
+	redis    *RedisService
+	auth     *AuthService
+	ice      *ICEService
+	webhooks *WebhookService
+	config   *config.Config
 }
 
 // NewSessionService creates a new session service instance
-func NewSessionService(redis *RedisService, auth *AuthService, cfg *config.Config) *SessionService {
+func NewSessionService(redis *RedisService, auth *AuthService, ice *ICEService, webhooks *WebhookService, cfg *config.Config) *SessionService {
 	return &SessionService{
-		redis:  redis,
-		auth:   auth,
-		config: cfg,
+		redis:    redis,
+		auth:     auth,
+		ice:      ice,
+		webhooks: webhooks,
+		config:   cfg,
 	}
 }
 
-// CreateSession creates a new watch party session
-func (s *SessionService) CreateSession(ctx context.Context, req *models.CreateSessionRequest, baseURL string) (*models.CreateSessionResponse, error) {
+// CreateSession creates a new watch party session. country is the client's
+// country code, used to pick nearby ICE servers.
+func (s *SessionService) CreateSession(ctx context.Context, req *models.CreateSessionRequest, baseURL, clientIP, country string) (*models.CreateSessionResponse, error) {
 	// Validate request
 	if errors := req.Validate(); len(errors) > 0 {
-		return nil, fmt.Errorf("validation failed")
+		return nil, ErrValidationFailed
+	}
+
+	if req.Public && !s.config.AllowPublicSessions {
+		return nil, ErrPublicSessionsDisabled
+	}
+
+	if !s.mediaURLAllowed(req.MediaURL) {
+		return nil, ErrMediaURLNotAllowed
+	}
+
+	// Relay-only sessions can't connect without a TURN server
+	if req.ForceRelay && !s.config.HasTurnServers() {
+		return nil, ErrRelayUnavailable
 	}
 
 	// Generate session ID and user ID
 	sessionID := uuid.New().String()
 	hostID := uuid.New().String()
 
-	// Hash password
-	passwordHash, err := utils.HashPassword(req.Password)
-	if err != nil {
-		return nil, fmt.Errorf("failed to hash password: %w", err)
+	// Hash password; public sessions leave the hash empty
+	var passwordHash string
+	if !req.Public {
+		hash, err := utils.HashPassword(req.Password, s.config.BcryptCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+		passwordHash = hash
 	}
 
+	hostUsername := chooseUsername(req.Username)
+
 	// Create session
 	now := time.Now()
 	session := &models.Session{
@@ -54,46 +79,91 @@ func (s *SessionService) CreateSession(ctx context.Context, req *models.CreateSe
 		Name:            utils.SanitizeString(req.Name),
 		HostID:          hostID,
 		PasswordHash:    passwordHash,
+		Public:          req.Public,
+		RequireApproval: req.RequireApproval,
+		CreatorIP:       clientIP,
 		Participants:    []string{hostID},
+		ForceRelay:      req.ForceRelay,
+		EncryptedChat:   req.EncryptedChat,
+		Usernames:       map[string]string{hostID: hostUsername},
 		MaxParticipants: s.config.MaxParticipants,
+		MediaTitle:      utils.SanitizeString(req.MediaTitle),
+		MediaURL:        strings.TrimSpace(req.MediaURL),
 		CreatedAt:       now,
 		ExpiresAt:       now.Add(s.config.SessionTTL),
 	}
 
+	// Claim one of the server's concurrent session slots before saving
+	if s.config.MaxTotalSessions > 0 {
+		active, err := s.redis.TrackActiveSession(ctx, sessionID, session.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		if active > int64(s.config.MaxTotalSessions) {
+			s.releaseActiveSession(ctx, sessionID)
+			return nil, ErrServerAtCapacity
+		}
+	}
+
+	// Claim one of the IP's concurrent session slots before saving
+	if s.config.MaxSessionsPerIP > 0 {
+		active, err := s.redis.TrackIPSession(ctx, clientIP, sessionID, session.ExpiresAt)
+		if err != nil {
+			s.releaseActiveSession(ctx, sessionID)
+			return nil, err
+		}
+		if active > int64(s.config.MaxSessionsPerIP) {
+			s.releaseIPSession(ctx, clientIP, sessionID)
+			s.releaseActiveSession(ctx, sessionID)
+			return nil, ErrTooManyActiveSessions
+		}
+	}
+
 	// Save to Redis
 	if err := s.redis.SaveSession(ctx, session); err != nil {
+		s.releaseIPSession(ctx, clientIP, sessionID)
+		s.releaseActiveSession(ctx, sessionID)
 		return nil, fmt.Errorf("failed to save session: %w", err)
 	}
+	s.audit(ctx, sessionID, models.AuditEventCreate, hostID, clientIP, "")
+	s.webhooks.Notify(models.WebhookSessionCreated, sessionID, &models.WebhookSessionData{Name: session.Name})
+
+	// Start the host's presence clock so an unused slot can be reclaimed
+	if err := s.redis.TouchPresence(ctx, sessionID, hostID); err != nil {
+		slog.Warn("Failed to record presence", "session_id", sessionID, "user_id", hostID, "error", err)
+	}
 
 	// Generate token for host
-    hostUsername := utils.GenerateRandomUsername()
 	token, err := s.auth.GenerateToken(sessionID, hostID, hostUsername, true)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
 	// Build share URL
-	shareURL := fmt.Sprintf("%s/join/%s", baseURL, sessionID)
+	shareURL := buildShareURL(baseURL, s.config.ShareJoinPath, sessionID)
 
 	return &models.CreateSessionResponse{
-		ID:         sessionID,
-		Name:       session.Name,
-		ShareURL:   shareURL,
-		Token:      token,
-		IceServers: s.getIceServers(ctx),
+		ID:                 sessionID,
+		Name:               session.Name,
+		ShareURL:           shareURL,
+		Username:           hostUsername,
+		Token:              token,
+		IceServers:         s.ice.GetIceServers(ctx, country),
+		IceTransportPolicy: s.iceTransportPolicy(session),
+		EncryptedChat:      session.EncryptedChat,
 	}, nil
 }
 
 // JoinSession allows a user to join an existing session
-func (s *SessionService) JoinSession(ctx context.Context, req *models.JoinSessionRequest) (*models.JoinSessionResponse, error) {
+func (s *SessionService) JoinSession(ctx context.Context, req *models.JoinSessionRequest, clientIP, country string) (*models.JoinSessionResponse, error) {
 	// Validate request
 	if errors := req.Validate(); len(errors) > 0 {
-		return nil, fmt.Errorf("validation failed")
+		return nil, ErrValidationFailed
 	}
 
 	// Validate session ID format
 	if !utils.IsValidUUID(req.SessionID) {
-		return nil, fmt.Errorf("invalid session ID format")
+		return nil, ErrInvalidSessionID
 	}
 
 	// Get session
@@ -102,45 +172,271 @@ func (s *SessionService) JoinSession(ctx context.Context, req *models.JoinSessio
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 	if session == nil {
-		return nil, fmt.Errorf("session not found")
+		return nil, s.missingSessionError(ctx, req.SessionID)
 	}
 
-	// Verify password
-	if !utils.CheckPassword(req.Password, session.PasswordHash) {
-		return nil, fmt.Errorf("invalid password")
+	// A still-valid token from an earlier join lets an existing participant
+	// back in without a password or a new slot. Anything else falls through
+	// to a normal join.
+	if req.Token != "" {
+		if response, ok := s.rejoin(ctx, session, req.Token, clientIP, country); ok {
+			return response, nil
+		}
 	}
 
-	// Check if session is full
-	if len(session.Participants) >= session.MaxParticipants {
-		return nil, fmt.Errorf("session is full")
+	// Reject new joins while the host has the session locked
+	if session.Locked {
+		return nil, ErrSessionLocked
 	}
 
-	// Generate user ID and add to participants
+	// Refuse joins while the session is cooling down from repeated failures
+	blocked, err := s.redis.IsJoinBlocked(ctx, req.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrTooManyFailedAttempts
+	}
+
+	// Verify password unless the session is public
+	if !session.Public && !utils.CheckPassword(req.Password, session.PasswordHash) {
+		if _, err := s.redis.RecordFailedJoin(ctx, req.SessionID); err != nil {
+			slog.Error("Failed to record failed join", "session_id", req.SessionID, "error", err)
+		}
+		return nil, ErrInvalidPassword
+	}
+
+	if err := s.redis.ResetFailedJoins(ctx, req.SessionID); err != nil {
+		slog.Error("Failed to reset failed joins", "session_id", req.SessionID, "error", err)
+	}
+
+	// Hold the user in the waiting room until the host decides. Capacity
+	// is checked when the request is admitted, atomically with the add.
+	if session.RequireApproval {
+		pending := &models.PendingJoin{
+			ID:          uuid.New().String(),
+			SessionID:   session.ID,
+			Username:    chooseUsername(req.Username),
+			Spectator:   req.Spectator,
+			Status:      models.JoinStatusPending,
+			RequestedAt: time.Now(),
+		}
+		if err := s.redis.SavePendingJoin(ctx, pending); err != nil {
+			return nil, err
+		}
+		return &models.JoinSessionResponse{
+			ID:        session.ID,
+			Name:      session.Name,
+			Pending:   true,
+			RequestID: pending.ID,
+			Username:  pending.Username,
+		}, nil
+	}
+
+	if req.Spectator {
+		return s.admitSpectator(ctx, session, chooseUsername(req.Username), clientIP, country)
+	}
+	return s.admitParticipant(ctx, session, chooseUsername(req.Username), clientIP, country)
+}
+
+// GetJoinStatus reports the state of a pending join request. Once the host
+// approves, the first call redeems the request and returns the full join
+// response with a token.
+func (s *SessionService) GetJoinStatus(ctx context.Context, sessionID, requestID, clientIP, country string) (*models.JoinSessionResponse, error) {
+	if !utils.IsValidUUID(sessionID) || !utils.IsValidUUID(requestID) {
+		return nil, ErrInvalidSessionID
+	}
+
+	session, err := s.redis.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, s.missingSessionError(ctx, sessionID)
+	}
+
+	pending, err := s.redis.GetPendingJoin(ctx, sessionID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if pending == nil {
+		return nil, ErrJoinRequestNotFound
+	}
+
+	switch pending.Status {
+	case models.JoinStatusApproved:
+		// Only one poll may turn an approval into a participant slot
+		claimed, err := s.redis.ClaimPendingJoin(ctx, sessionID, requestID)
+		if err != nil {
+			return nil, err
+		}
+		if claimed == nil {
+			return nil, ErrJoinRequestNotFound
+		}
+		if claimed.Spectator {
+			return s.admitSpectator(ctx, session, claimed.Username, clientIP, country)
+		}
+		return s.admitParticipant(ctx, session, claimed.Username, clientIP, country)
+	case models.JoinStatusDenied:
+		if _, err := s.redis.ClaimPendingJoin(ctx, sessionID, requestID); err != nil {
+			slog.Warn("Failed to clear denied join request", "session_id", sessionID, "request_id", requestID, "error", err)
+		}
+		return nil, ErrJoinDenied
+	default:
+		return &models.JoinSessionResponse{
+			ID:        session.ID,
+			Name:      session.Name,
+			Pending:   true,
+			RequestID: pending.ID,
+			Username:  pending.Username,
+		}, nil
+	}
+}
+
+// admitParticipant adds a viewer to the session and issues their token
+func (s *SessionService) admitParticipant(ctx context.Context, session *models.Session, username, clientIP, country string) (*models.JoinSessionResponse, error) {
+	// Generate user ID and add to participants under a unique name
 	userID := uuid.New().String()
-	if err := s.redis.AddParticipant(ctx, req.SessionID, userID); err != nil {
+	viewerUsername, isHost, err := s.redis.AddParticipant(ctx, session.ID, userID, username)
+	if err != nil {
 		return nil, fmt.Errorf("failed to add participant: %w", err)
 	}
+	s.audit(ctx, session.ID, models.AuditEventJoin, userID, clientIP, viewerUsername)
+	if isHost {
+		// The previous host left with nobody to take over
+		s.audit(ctx, session.ID, models.AuditEventHostTransfer, "system", "", userID)
+	}
+
+	if err := s.redis.TouchPresence(ctx, session.ID, userID); err != nil {
+		slog.Warn("Failed to record presence", "session_id", session.ID, "user_id", userID, "error", err)
+	}
 
 	// Generate token for viewer
-    viewerUsername := utils.GenerateRandomUsername()
-	token, err := s.auth.GenerateToken(req.SessionID, userID, viewerUsername, false)
+	token, err := s.auth.GenerateToken(session.ID, userID, viewerUsername, isHost)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
+	// The snapshot predates the join, so count the new viewer
+	session.Participants = append(session.Participants, userID)
+
 	return &models.JoinSessionResponse{
-		ID:         session.ID,
-		Name:       session.Name,
-		Token:      token,
-		IceServers: s.getIceServers(ctx),
+		ID:                 session.ID,
+		Name:               session.Name,
+		Username:           viewerUsername,
+		Token:              token,
+		IceServers:         s.ice.GetIceServers(ctx, country),
+		IceTransportPolicy: s.iceTransportPolicy(session),
+		EncryptedChat:      session.EncryptedChat,
+		SpotsRemaining:     session.SpotsRemaining(),
+		IsFull:             session.IsFull(),
 	}, nil
 }
 
+// admitSpectator issues a read-only token without adding the user to the
+// participant list, so spectators never count toward MaxParticipants
+func (s *SessionService) admitSpectator(ctx context.Context, session *models.Session, username, clientIP, country string) (*models.JoinSessionResponse, error) {
+	userID := uuid.New().String()
+	s.audit(ctx, session.ID, models.AuditEventSpectate, userID, clientIP, username)
+
+	token, err := s.auth.GenerateSpectatorToken(session.ID, userID, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return &models.JoinSessionResponse{
+		ID:                 session.ID,
+		Name:               session.Name,
+		Username:           username,
+		Token:              token,
+		IceServers:         s.ice.GetIceServers(ctx, country),
+		IceTransportPolicy: s.iceTransportPolicy(session),
+		EncryptedChat:      session.EncryptedChat,
+		SpotsRemaining:     session.SpotsRemaining(),
+		IsFull:             session.IsFull(),
+		Spectator:          true,
+	}, nil
+}
+
+// rejoin reissues a token for a participant identified by a previous token.
+// It reports false if the token is invalid, belongs to another session, or
+// its user is no longer a participant.
+func (s *SessionService) rejoin(ctx context.Context, session *models.Session, token, clientIP, country string) (*models.JoinSessionResponse, bool) {
+	claims, err := s.auth.ValidateToken(token)
+	if err != nil || claims.SessionID != session.ID || session.TokenRevoked(claims.Issued()) {
+		return nil, false
+	}
+
+	userID := claims.UserID
+	isParticipant := false
+	for _, p := range session.Participants {
+		if p == userID {
+			isParticipant = true
+			break
+		}
+	}
+	if !isParticipant {
+		return nil, false
+	}
+
+	username := session.Usernames[userID]
+	if username == "" {
+		username = claims.Username
+	}
+
+	if err := s.redis.TouchPresence(ctx, session.ID, userID); err != nil {
+		slog.Warn("Failed to record presence", "session_id", session.ID, "user_id", userID, "error", err)
+	}
+
+	refreshed, err := s.auth.GenerateToken(session.ID, userID, username, session.HostID == userID)
+	if err != nil {
+		slog.Error("Failed to refresh token", "session_id", session.ID, "user_id", userID, "error", err)
+		return nil, false
+	}
+	s.audit(ctx, session.ID, models.AuditEventRejoin, userID, clientIP, username)
+
+	return &models.JoinSessionResponse{
+		ID:                 session.ID,
+		Name:               session.Name,
+		Username:           username,
+		Token:              refreshed,
+		IceServers:         s.ice.GetIceServers(ctx, country),
+		IceTransportPolicy: s.iceTransportPolicy(session),
+		EncryptedChat:      session.EncryptedChat,
+		SpotsRemaining:     session.SpotsRemaining(),
+		IsFull:             session.IsFull(),
+	}, true
+}
+
+// buildShareURL joins the frontend base URL, the configured join route and
+// a session ID, tolerating stray slashes around the route
+func buildShareURL(baseURL, joinPath, sessionID string) string {
+	url := strings.TrimSuffix(baseURL, "/") + "/"
+	if route := strings.Trim(joinPath, "/"); route != "" {
+		url += route + "/"
+	}
+	return url + sessionID
+}
+
+// TokenRevoked reports whether a token for sessionID issued at issuedAt was
+// revoked by a password change. A session that no longer exists reports
+// false, leaving callers to answer with their own not-found error.
+func (s *SessionService) TokenRevoked(ctx context.Context, sessionID string, issuedAt time.Time) (bool, error) {
+	session, err := s.redis.GetSession(ctx, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return false, nil
+	}
+	return session.TokenRevoked(issuedAt), nil
+}
+
 // GetSession retrieves session details
 func (s *SessionService) GetSession(ctx context.Context, sessionID string) (*models.SessionInfoResponse, error) {
 	// Validate session ID format
 	if !utils.IsValidUUID(sessionID) {
-		return nil, fmt.Errorf("invalid session ID format")
+		return nil, ErrInvalidSessionID
 	}
 
 	session, err := s.redis.GetSession(ctx, sessionID)
@@ -148,84 +444,575 @@ func (s *SessionService) GetSession(ctx context.Context, sessionID string) (*mod
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 	if session == nil {
-		return nil, fmt.Errorf("session not found")
+		return nil, ErrSessionNotFound
+	}
+
+	// Live connection count is informational, so a Redis hiccup shouldn't fail the request
+	activeConnections, err := s.redis.GetConnectionCount(ctx, sessionID)
+	if err != nil {
+		slog.Warn("Failed to get connection count", "session_id", sessionID, "error", err)
+		activeConnections = 0
 	}
 
 	return &models.SessionInfoResponse{
-		ID:              session.ID,
-		Name:            session.Name,
-		HostID:          session.HostID,
-		Participants:    session.Participants,
-		MaxParticipants: session.MaxParticipants,
-		CreatedAt:       session.CreatedAt.Format(time.RFC3339),
-		ExpiresAt:       session.ExpiresAt.Format(time.RFC3339),
+		ID:                session.ID,
+		Name:              session.Name,
+		HostID:            session.HostID,
+		Participants:      session.Participants,
+		MaxParticipants:   session.MaxParticipants,
+		SpotsRemaining:    session.SpotsRemaining(),
+		IsFull:            session.IsFull(),
+		ActiveConnections: activeConnections,
+		Locked:            session.Locked,
+		Public:            session.Public,
+		EncryptedChat:     session.EncryptedChat,
+		Controllers:       session.Controllers,
+		MediaTitle:        session.MediaTitle,
+		MediaURL:          session.MediaURL,
+		CreatedAt:         session.CreatedAt.Format(time.RFC3339),
+		ExpiresAt:         session.ExpiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// SessionExists reports whether a session can be joined without revealing
+// anything beyond its name. Expired and unknown sessions both report false.
+func (s *SessionService) SessionExists(ctx context.Context, sessionID string) (*models.SessionExistsResponse, error) {
+	if !utils.IsValidUUID(sessionID) {
+		return nil, ErrInvalidSessionID
+	}
+
+	session, err := s.redis.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return &models.SessionExistsResponse{Exists: false}, nil
+	}
+
+	return &models.SessionExistsResponse{
+		Exists:           true,
+		Name:             session.Name,
+		RequiresPassword: !session.Public,
+		IsFull:           session.IsFull(),
+	}, nil
+}
+
+// UpdateMedia changes the now-playing media of a session
+func (s *SessionService) UpdateMedia(ctx context.Context, sessionID string, req *models.UpdateMediaRequest) (*models.MediaChangedPayload, error) {
+	if errors := req.Validate(); len(errors) > 0 {
+		return nil, ErrValidationFailed
+	}
+
+	media := &models.MediaChangedPayload{
+		MediaTitle: utils.SanitizeString(req.MediaTitle),
+		MediaURL:   strings.TrimSpace(req.MediaURL),
+	}
+	if !s.mediaURLAllowed(media.MediaURL) {
+		return nil, ErrMediaURLNotAllowed
+	}
+	if err := s.redis.UpdateSessionMedia(ctx, sessionID, media.MediaTitle, media.MediaURL); err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// releaseIPSession gives back a per-IP session slot claimed by a session
+// that was never created
+func (s *SessionService) releaseIPSession(ctx context.Context, clientIP, sessionID string) {
+	if s.config.MaxSessionsPerIP <= 0 {
+		return
+	}
+	if err := s.redis.UntrackIPSession(ctx, clientIP, sessionID); err != nil {
+		slog.Error("Failed to release IP session slot", "session_id", sessionID, "error", err)
+	}
+}
+
+// releaseActiveSession gives back a server-wide session slot claimed by a
+// session that was never created
+func (s *SessionService) releaseActiveSession(ctx context.Context, sessionID string) {
+	if s.config.MaxTotalSessions <= 0 {
+		return
+	}
+	if err := s.redis.UntrackActiveSession(ctx, sessionID); err != nil {
+		slog.Error("Failed to release server session slot", "session_id", sessionID, "error", err)
+	}
+}
+
+// mediaURLAllowed reports whether a session may point at the given media URL.
+// An empty URL clears the media and is always allowed.
+func (s *SessionService) mediaURLAllowed(mediaURL string) bool {
+	mediaURL = strings.TrimSpace(mediaURL)
+	return mediaURL == "" || utils.IsAllowedMediaURL(mediaURL, s.config.AllowedMediaDomains)
+}
+
+// ListSessions returns a summary of every active session
+func (s *SessionService) ListSessions(ctx context.Context) ([]models.AdminSessionSummary, error) {
+	sessions, err := s.redis.ListSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]models.AdminSessionSummary, 0, len(sessions))
+	for _, session := range sessions {
+		summaries = append(summaries, models.AdminSessionSummary{
+			ID:               session.ID,
+			Name:             session.Name,
+			ParticipantCount: len(session.Participants),
+			ExpiresAt:        session.ExpiresAt.Format(time.RFC3339),
+		})
+	}
+	return summaries, nil
+}
+
+// GetSessionSummaries returns admin summaries for the given sessions in
+// request order, with nil entries for sessions that don't exist
+func (s *SessionService) GetSessionSummaries(ctx context.Context, ids []string) ([]*models.AdminSessionSummary, error) {
+	sessions, err := s.redis.GetSessions(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]*models.AdminSessionSummary, len(sessions))
+	for i, session := range sessions {
+		if session == nil {
+			continue
+		}
+		summaries[i] = &models.AdminSessionSummary{
+			ID:               session.ID,
+			Name:             session.Name,
+			ParticipantCount: len(session.Participants),
+			ExpiresAt:        session.ExpiresAt.Format(time.RFC3339),
+		}
+	}
+	return summaries, nil
+}
+
+// CountSessions returns the number of active sessions. It scans every
+// session key, so callers should cache the result.
+func (s *SessionService) CountSessions(ctx context.Context) (int, error) {
+	ids, err := s.redis.ListSessionIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// TerminateSession deletes a session and its chat history
+func (s *SessionService) TerminateSession(ctx context.Context, sessionID string) error {
+	if !utils.IsValidUUID(sessionID) {
+		return ErrInvalidSessionID
+	}
+
+	session, err := s.redis.GetSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return ErrSessionNotFound
+	}
+
+	if err := s.redis.DeleteSession(ctx, sessionID); err != nil {
+		return err
+	}
+	s.audit(ctx, sessionID, models.AuditEventTerminate, "admin", "", "")
+	s.webhooks.Notify(models.WebhookSessionEnded, sessionID, &models.WebhookSessionData{Reason: "terminated"})
+	if err := s.redis.ClearChatHistory(ctx, sessionID); err != nil {
+		slog.Warn("Failed to clear chat history", "session_id", sessionID, "error", err)
+	}
+	if err := s.redis.MarkSessionExpired(ctx, sessionID); err != nil {
+		slog.Warn("Failed to mark session expired", "session_id", sessionID, "error", err)
+	}
+	return nil
+}
+
+// IsHost reports whether userID is the current host of a session
+func (s *SessionService) IsHost(ctx context.Context, sessionID, userID string) (bool, error) {
+	session, err := s.redis.GetSession(ctx, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return false, ErrSessionNotFound
+	}
+	return session.HostID == userID, nil
+}
+
+// ToggleLock flips the lock state of a session and returns the new state
+func (s *SessionService) ToggleLock(ctx context.Context, sessionID string) (*models.LockSessionResponse, error) {
+	if !utils.IsValidUUID(sessionID) {
+		return nil, ErrInvalidSessionID
+	}
+
+	session, err := s.redis.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+
+	locked := !session.Locked
+	if err := s.redis.SetSessionLocked(ctx, sessionID, locked); err != nil {
+		return nil, err
+	}
+
+	return &models.LockSessionResponse{
+		ID:     sessionID,
+		Locked: locked,
+	}, nil
+}
+
+// ChangePassword rotates a session's password. If PasswordChangeRevokes is
+// set, everyone but the host is signed out and the host gets a fresh token,
+// since theirs was revoked too. The removed user IDs are returned so their
+// connections can be closed. req must already be validated; the handler
+// does so to report each violation.
+func (s *SessionService) ChangePassword(ctx context.Context, sessionID, hostID string, req *models.ChangePasswordRequest) (*models.ChangePasswordResponse, []string, error) {
+	if !utils.IsValidUUID(sessionID) {
+		return nil, nil, ErrInvalidSessionID
+	}
+
+	session, err := s.redis.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, nil, ErrSessionNotFound
+	}
+	if session.Public {
+		return nil, nil, ErrSessionHasNoPassword
+	}
+
+	hash, err := utils.HashPassword(req.Password, s.config.BcryptCost)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	revoke := s.config.PasswordChangeRevokes
+	removed, err := s.redis.UpdatePassword(ctx, sessionID, hash, revoke)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.audit(ctx, sessionID, models.AuditEventPassword, hostID, "", "")
+
+	response := &models.ChangePasswordResponse{
+		ID:            sessionID,
+		TokensRevoked: revoke,
+	}
+	if revoke {
+		// Issued after the revocation, so it stays valid
+		token, err := s.auth.GenerateToken(sessionID, hostID, session.Usernames[hostID], true)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate token: %w", err)
+		}
+		response.Token = token
+	}
+	return response, removed, nil
+}
+
+// RotateSession moves a session to a new ID, e.g. after its share link
+// leaked. Chat, participants and settings carry over, while the old ID and
+// tokens issued for it stop working. Alongside the host's response it
+// returns a fresh token for every participant and for each of spectators,
+// the connected spectators' usernames keyed by user ID, to hand to
+// connected clients.
+func (s *SessionService) RotateSession(ctx context.Context, sessionID, hostID, baseURL string, spectators map[string]string) (*models.RotateSessionResponse, map[string]string, error) {
+	if !utils.IsValidUUID(sessionID) {
+		return nil, nil, ErrInvalidSessionID
+	}
+
+	newID := uuid.New().String()
+	session, err := s.redis.RotateSession(ctx, sessionID, newID)
+	if session == nil {
+		return nil, nil, err
+	}
+	if err != nil {
+		// The session already lives under the new ID, so carry on with
+		// whatever data did move
+		slog.Error("Failed to move all session data", "session_id", sessionID, "new_session_id", newID, "error", err)
+	}
+	s.audit(ctx, newID, models.AuditEventRotate, hostID, "", sessionID)
+
+	tokens := make(map[string]string, len(session.Participants)+len(spectators))
+	for _, userID := range session.Participants {
+		token, err := s.auth.GenerateToken(newID, userID, session.Usernames[userID], userID == session.HostID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate token: %w", err)
+		}
+		tokens[userID] = token
+	}
+	// Spectators aren't recorded in the session, so only connected ones
+	// can be handed a token
+	for userID, username := range spectators {
+		token, err := s.auth.GenerateSpectatorToken(newID, userID, username)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate token: %w", err)
+		}
+		tokens[userID] = token
+	}
+
+	return &models.RotateSessionResponse{
+		ID:         newID,
+		PreviousID: sessionID,
+		ShareURL:   buildShareURL(baseURL, s.config.ShareJoinPath, newID),
+		Token:      tokens[hostID],
+	}, tokens, nil
+}
+
+// ExtendSession lengthens a session by the configured increment, up to the
+// configured maximum lifetime
+func (s *SessionService) ExtendSession(ctx context.Context, sessionID string) (*models.SessionExtendedPayload, error) {
+	if !utils.IsValidUUID(sessionID) {
+		return nil, ErrInvalidSessionID
+	}
+
+	expiresAt, err := s.redis.ExtendSession(ctx, sessionID, s.config.SessionExtendIncrement, s.config.SessionMaxLifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SessionExtendedPayload{
+		ExpiresAt: expiresAt.Format(time.RFC3339),
 	}, nil
 }
 
+// SetController grants or revokes a participant's permission to control playback
+func (s *SessionService) SetController(ctx context.Context, sessionID, userID string, enabled bool) (*models.ControllersChangedPayload, error) {
+	if !utils.IsValidUUID(sessionID) || !utils.IsValidUUID(userID) {
+		return nil, ErrInvalidSessionID
+	}
+
+	controllers, err := s.redis.SetSessionController(ctx, sessionID, userID, enabled)
+	if err != nil {
+		return nil, err
+	}
+	if controllers == nil {
+		controllers = []string{}
+	}
+
+	return &models.ControllersChangedPayload{Controllers: controllers}, nil
+}
+
+// SetMuted mutes or unmutes a participant's chat. The host can't be muted.
+func (s *SessionService) SetMuted(ctx context.Context, sessionID, userID string, muted bool) error {
+	if !utils.IsValidUUID(sessionID) || !utils.IsValidUUID(userID) {
+		return ErrInvalidSessionID
+	}
+
+	session, err := s.redis.GetSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return ErrSessionNotFound
+	}
+	if _, ok := session.Usernames[userID]; !ok {
+		return ErrParticipantNotFound
+	}
+	if session.HostID == userID {
+		return ErrCannotMuteHost
+	}
+
+	return s.redis.SetUserMuted(ctx, sessionID, userID, muted)
+}
+
+// LeaveSession removes a participant from a session. If the participant was
+// the host, host privileges pass to another participant whose ID is returned.
+func (s *SessionService) LeaveSession(ctx context.Context, sessionID, userID string) (string, error) {
+	if err := s.redis.RemoveParticipant(ctx, sessionID, userID); err != nil {
+		return "", fmt.Errorf("failed to remove participant: %w", err)
+	}
+	s.audit(ctx, sessionID, models.AuditEventLeave, userID, "", "")
+
+	newHostID, err := s.redis.TransferHost(ctx, sessionID, userID)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to transfer host: %w", err)
+	}
+	if newHostID != "" {
+		s.audit(ctx, sessionID, models.AuditEventHostTransfer, userID, "", newHostID)
+	}
+	return newHostID, nil
+}
+
+// RunParticipantReaper periodically removes participants who have had no
+// live connection for longer than the configured grace period, freeing
+// slots held by people who joined but never connected or never came back
+func (s *SessionService) RunParticipantReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.reapParticipants(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *SessionService) reapParticipants(ctx context.Context) {
+	sessionIDs, err := s.redis.ListSessionIDs(ctx)
+	if err != nil {
+		slog.Error("Participant reaper failed to list sessions", "error", err)
+		return
+	}
+
+	cutoff := time.Now().Add(-s.config.ParticipantGracePeriod)
+	for _, sessionID := range sessionIDs {
+		session, err := s.redis.GetSession(ctx, sessionID)
+		if err != nil || session == nil {
+			continue
+		}
+		connected, err := s.redis.GetConnectedUsers(ctx, sessionID)
+		if err != nil {
+			continue
+		}
+		presence, err := s.redis.GetPresence(ctx, sessionID)
+		if err != nil {
+			continue
+		}
+
+		for _, userID := range session.Participants {
+			// The host keeps their slot so the session isn't orphaned
+			if userID == session.HostID || connected[userID] {
+				continue
+			}
+			// Participants with no presence record predate tracking; start their clock now
+			lastSeen, ok := presence[userID]
+			if !ok {
+				s.redis.TouchPresence(ctx, sessionID, userID)
+				continue
+			}
+			if lastSeen.After(cutoff) {
+				continue
+			}
+
+			if err := s.redis.RemoveParticipant(ctx, sessionID, userID); err != nil {
+				slog.Error("Failed to reap participant", "session_id", sessionID, "user_id", userID, "error", err)
+				continue
+			}
+			s.audit(ctx, sessionID, models.AuditEventKick, "system", "", userID)
+			slog.Info("Reaped disconnected participant", "session_id", sessionID, "user_id", userID)
+		}
+	}
+}
+
 // RemoveParticipant removes a participant from a session
 func (s *SessionService) RemoveParticipant(ctx context.Context, sessionID, userID string) error {
 	return s.redis.RemoveParticipant(ctx, sessionID, userID)
 }
 
-// getIceServers retrieves ICE servers from Metered.ca or config
-func (s *SessionService) getIceServers(ctx context.Context) []interface{} {
-	if s.config.MeteredAPIKey == "" {
-		return s.config.IceServers
+// GetAuditLog returns a session's audit trail, which remains available for a
+// while after the session itself has closed
+func (s *SessionService) GetAuditLog(ctx context.Context, sessionID string) ([]models.AuditEntry, error) {
+	if !utils.IsValidUUID(sessionID) {
+		return nil, ErrInvalidSessionID
 	}
+	return s.redis.GetAudit(ctx, sessionID)
+}
 
-	// Try to get from cache
-	if cached, err := s.redis.Get(ctx, "sys:ice_servers"); err == nil {
-		var servers []interface{}
-		if err := json.Unmarshal([]byte(cached), &servers); err == nil {
-			return servers
-		}
+// GetTranscript returns a session's chat transcript for the host to export.
+// Stored entries that can't be decoded are skipped.
+func (s *SessionService) GetTranscript(ctx context.Context, sessionID string) (*models.TranscriptResponse, error) {
+	if !utils.IsValidUUID(sessionID) {
+		return nil, ErrInvalidSessionID
 	}
 
-	// Fetch from Metered API
-	// Format: https://<app-name>.metered.live/api/v1/turn/credentials?apiKey=<api-key>
-	// We need app name. Actually usually it's just metered.live/api/v1/turn/credentials?apiKey=... 
-	// The user provided "vibecodingisreal.metered.live".
-    // I should probably make the domain configurable or extract it.
-	// User said: "Metered Domain: vibecodingisreal.metered.live"
-	// So URL is https://vibecodingisreal.metered.live/api/v1/turn/credentials?apiKey=...
-
-	domain := "vibecodingisreal.metered.live" // Default or from env if I added it.
-    // I didn't add MeteredDomain to config, I should have. 
-    // I will hardcode it for now as user provided it or try to fetch it from env "METERED_DOMAIN" using `os.Getenv` if I didn't add it to config struct.
-    // Actually I can check if config has it. I didn't add it.
-    // I'll use os.Getenv for now.
-    
-    if envDomain := os.Getenv("METERED_DOMAIN"); envDomain != "" {
-        domain = envDomain
-    }
+	session, err := s.redis.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
 
-	url := fmt.Sprintf("https://%s/api/v1/turn/credentials?apiKey=%s", domain, s.config.MeteredAPIKey)
-	
-    resp, err := http.Get(url)
+	raw, err := s.redis.GetTranscript(ctx, sessionID)
 	if err != nil {
-		fmt.Printf("Failed to fetch ICE servers: %v\n", err)
-		return s.config.IceServers
+		return nil, err
+	}
+
+	entries := make([]models.TranscriptEntry, 0, len(raw))
+	for _, data := range raw {
+		var msg models.WebSocketMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		var chat models.ChatPayload
+		if err := json.Unmarshal(msg.Payload, &chat); err != nil {
+			continue
+		}
+		entries = append(entries, models.TranscriptEntry{
+			ID:        chat.ID,
+			UserID:    chat.UserID,
+			Username:  chat.Username,
+			Message:   chat.Message,
+			Timestamp: chat.Timestamp,
+			Encrypted: chat.Encrypted,
+		})
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Metered API returned status: %d\n", resp.StatusCode)
-		return s.config.IceServers
+	return &models.TranscriptResponse{
+		ID:       session.ID,
+		Name:     session.Name,
+		Messages: entries,
+	}, nil
+}
+
+// audit records an entry in a session's audit trail. The IP is truncated
+// before storage. Failures are logged and never fail the caller.
+func (s *SessionService) audit(ctx context.Context, sessionID, event, actor, ip, detail string) {
+	entry := &models.AuditEntry{
+		Event:  event,
+		Actor:  actor,
+		IP:     utils.TruncateIP(ip),
+		Detail: detail,
+	}
+	if err := s.redis.AppendAudit(ctx, sessionID, entry); err != nil {
+		slog.Warn("Failed to write audit entry", "session_id", sessionID, "event", event, "error", err)
 	}
+}
 
-    // Metered returns a JSON array of ICE servers directly? Or an object?
-    // Docs: Returns [ { "urls": "...", "username": "...", "credential": "..." } ]
-    // So we can unmarshal directly into []interface{}
-	var servers []interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&servers); err != nil {
-		fmt.Printf("Failed to decode ICE servers: %v\n", err)
-		return s.config.IceServers
+// missingSessionError distinguishes sessions that were closed from IDs that never existed
+func (s *SessionService) missingSessionError(ctx context.Context, sessionID string) error {
+	if expired, err := s.redis.IsSessionExpired(ctx, sessionID); err == nil && expired {
+		return ErrSessionExpired
 	}
+	return ErrSessionNotFound
+}
 
-	// Cache for 1 hour
-	if data, err := json.Marshal(servers); err == nil {
-		s.redis.Set(ctx, "sys:ice_servers", string(data), 1*time.Hour)
+// chooseUsername returns the sanitized requested name, or a random one if none was given
+func chooseUsername(requested string) string {
+	if requested == "" {
+		return utils.GenerateRandomUsername()
 	}
+	return utils.SanitizeString(requested)
+}
 
-	return servers
+// GetIceConfig returns the current ICE servers and transport policy for a
+// session, so long-running calls can refresh rotated TURN credentials
+func (s *SessionService) GetIceConfig(ctx context.Context, sessionID, country string) (*models.IceConfigResponse, error) {
+	session, err := s.redis.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, s.missingSessionError(ctx, sessionID)
+	}
+
+	return &models.IceConfigResponse{
+		IceServers:         s.ice.GetIceServers(ctx, country),
+		IceTransportPolicy: s.iceTransportPolicy(session),
+	}, nil
+}
+
+// iceTransportPolicy returns "relay" when TURN is forced globally or for the session
+func (s *SessionService) iceTransportPolicy(session *models.Session) string {
+	if s.config.IceForceRelay || session.ForceRelay {
+		return models.IceTransportPolicyRelay
+	}
+	return models.IceTransportPolicyAll
 }
@@ -0,0 +1,834 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+)
+
+func TestCreateAndJoinReturnUsername(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	created := env.createSession(t)
+	if created.Username == "" {
+		t.Fatal("create response has no username")
+	}
+	if claims := env.claims(t, created.Token); claims.Username != created.Username {
+		t.Errorf("create username = %q, token says %q", created.Username, claims.Username)
+	}
+
+	joined := env.join(t, created.ID)
+	if joined.Username == "" {
+		t.Fatal("join response has no username")
+	}
+	if claims := env.claims(t, joined.Token); claims.Username != joined.Username {
+		t.Errorf("join username = %q, token says %q", joined.Username, claims.Username)
+	}
+
+	named, err := env.sessions.JoinSession(context.Background(), &models.JoinSessionRequest{
+		SessionID: created.ID,
+		Password:  testPassword,
+		Username:  "Popcorn",
+	}, testIP, "")
+	if err != nil {
+		t.Fatalf("JoinSession: %v", err)
+	}
+	if named.Username != "Popcorn" {
+		t.Errorf("requested username: got %q, want %q", named.Username, "Popcorn")
+	}
+}
+
+func TestGetSessionReportsActiveConnections(t *testing.T) {
+	env := newTestEnv(t, nil)
+	ctx := context.Background()
+	created := env.createSession(t)
+	hostID := env.claims(t, created.Token).UserID
+
+	for _, connectionID := range []string{"tab-1", "tab-2"} {
+		if err := env.redis.AddConnection(ctx, created.ID, hostID, connectionID); err != nil {
+			t.Fatalf("AddConnection: %v", err)
+		}
+	}
+
+	info, err := env.sessions.GetSession(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if info.ActiveConnections != 2 {
+		t.Errorf("ActiveConnections = %d, want 2", info.ActiveConnections)
+	}
+}
+
+func TestGetSessionToleratesConnectionCountFailure(t *testing.T) {
+	env := newTestEnv(t, nil)
+	created := env.createSession(t)
+
+	// A key of the wrong type makes the count fail while the session loads
+	env.mr.Set("connections:"+created.ID, "corrupt")
+
+	info, err := env.sessions.GetSession(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed with the count unavailable: %v", err)
+	}
+	if info.ActiveConnections != 0 {
+		t.Errorf("ActiveConnections = %d, want 0", info.ActiveConnections)
+	}
+}
+
+func TestPublicSessionJoinsWithoutPassword(t *testing.T) {
+	env := newTestEnv(t, nil)
+	ctx := context.Background()
+
+	created, err := env.sessions.CreateSession(ctx, &models.CreateSessionRequest{
+		Name:   "Open room",
+		Public: true,
+	}, "http://localhost:5173", testIP, "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if session := env.session(t, created.ID); session.PasswordHash != "" {
+		t.Error("public session stored a password hash")
+	}
+
+	if _, err := env.sessions.JoinSession(ctx, &models.JoinSessionRequest{SessionID: created.ID}, testIP, ""); err != nil {
+		t.Fatalf("JoinSession without password: %v", err)
+	}
+
+	info, err := env.sessions.GetSession(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if !info.Public {
+		t.Error("session info does not report the session as public")
+	}
+}
+
+func TestPrivateSessionRequiresPassword(t *testing.T) {
+	env := newTestEnv(t, nil)
+	ctx := context.Background()
+	created := env.createSession(t)
+
+	_, err := env.sessions.JoinSession(ctx, &models.JoinSessionRequest{SessionID: created.ID}, testIP, "")
+	if !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("join without password: err = %v, want %v", err, ErrInvalidPassword)
+	}
+
+	info, err := env.sessions.GetSession(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if info.Public {
+		t.Error("session info reports a private session as public")
+	}
+}
+
+func TestPublicSessionsCanBeDisabled(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.AllowPublicSessions = false
+	})
+
+	_, err := env.sessions.CreateSession(context.Background(), &models.CreateSessionRequest{
+		Name:   "Open room",
+		Public: true,
+	}, "http://localhost:5173", testIP, "")
+	if !errors.Is(err, ErrPublicSessionsDisabled) {
+		t.Errorf("err = %v, want %v", err, ErrPublicSessionsDisabled)
+	}
+	if keys := env.mr.Keys(); len(keys) != 0 {
+		t.Errorf("refused session left keys behind: %v", keys)
+	}
+}
+
+func TestSetController(t *testing.T) {
+	env := newTestEnv(t, nil)
+	ctx := context.Background()
+	created := env.createSession(t)
+	viewerID := env.claims(t, env.join(t, created.ID).Token).UserID
+
+	granted, err := env.sessions.SetController(ctx, created.ID, viewerID, true)
+	if err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	if len(granted.Controllers) != 1 || granted.Controllers[0] != viewerID {
+		t.Errorf("after grant: controllers = %v, want [%s]", granted.Controllers, viewerID)
+	}
+	if !env.session(t, created.ID).IsController(viewerID) {
+		t.Error("grant was not stored")
+	}
+
+	// Granting twice does not duplicate the entry
+	if again, err := env.sessions.SetController(ctx, created.ID, viewerID, true); err != nil || len(again.Controllers) != 1 {
+		t.Errorf("second grant: controllers = %v, err = %v", again, err)
+	}
+
+	revoked, err := env.sessions.SetController(ctx, created.ID, viewerID, false)
+	if err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if revoked.Controllers == nil || len(revoked.Controllers) != 0 {
+		t.Errorf("after revoke: controllers = %#v, want an empty list", revoked.Controllers)
+	}
+	if env.session(t, created.ID).IsController(viewerID) {
+		t.Error("revoke was not stored")
+	}
+
+	stranger := "6f1c1a4e-7a61-4c3b-9a53-0d1f5f3e2b7c"
+	if _, err := env.sessions.SetController(ctx, created.ID, stranger, true); !errors.Is(err, ErrParticipantNotFound) {
+		t.Errorf("non-participant: err = %v, want %v", err, ErrParticipantNotFound)
+	}
+}
+
+func TestMaxSessionsPerIP(t *testing.T) {
+	const limit = 3
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.MaxSessionsPerIP = limit
+	})
+	ctx := context.Background()
+
+	var created []*models.CreateSessionResponse
+	for i := 0; i < limit; i++ {
+		created = append(created, env.createSession(t))
+	}
+
+	_, err := env.sessions.CreateSession(ctx, &models.CreateSessionRequest{
+		Name:     "One too many",
+		Password: testPassword,
+	}, "http://localhost:5173", testIP, "")
+	if !errors.Is(err, ErrTooManyActiveSessions) {
+		t.Fatalf("session %d: err = %v, want %v", limit+1, err, ErrTooManyActiveSessions)
+	}
+	if members, _ := env.mr.ZMembers(ipSessionsKey(testIP)); len(members) != limit {
+		t.Errorf("refused session left %d slots claimed, want %d", len(members), limit)
+	}
+
+	// Another IP has its own allowance
+	if _, err := env.sessions.CreateSession(ctx, &models.CreateSessionRequest{
+		Name:     "Elsewhere",
+		Password: testPassword,
+	}, "http://localhost:5173", "198.51.100.1", ""); err != nil {
+		t.Errorf("other IP: %v", err)
+	}
+
+	// Ending a session frees its slot
+	if err := env.sessions.TerminateSession(ctx, created[0].ID); err != nil {
+		t.Fatalf("TerminateSession: %v", err)
+	}
+	env.createSession(t)
+}
+
+func TestMaxSessionsPerIPIgnoresExpiredSessions(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.MaxSessionsPerIP = 1
+	})
+
+	// A session that lapsed via TTL without being deleted
+	expired := float64(time.Now().Add(-time.Minute).Unix())
+	if _, err := env.mr.ZAdd(ipSessionsKey(testIP), expired, "lapsed-session"); err != nil {
+		t.Fatalf("ZAdd: %v", err)
+	}
+
+	env.createSession(t)
+}
+
+func TestExtendSession(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.SessionTTL = time.Hour
+		cfg.SessionExtendIncrement = 30 * time.Minute
+		cfg.SessionMaxLifetime = 2 * time.Hour
+	})
+	ctx := context.Background()
+	created := env.createSession(t)
+	session := env.session(t, created.ID)
+	chat := "chat:" + created.ID
+	env.mr.Lpush(chat, `{"type":"chat"}`)
+	env.mr.SetTTL(chat, time.Hour)
+
+	// assertExpiry checks ExpiresAt and the TTLs of the session and its chat
+	assertExpiry := func(want time.Time) {
+		t.Helper()
+		if got := env.session(t, created.ID).ExpiresAt; !got.Equal(want) {
+			t.Errorf("ExpiresAt = %v, want %v", got, want)
+		}
+		for _, key := range []string{"session:" + created.ID, chat} {
+			if ttl := env.mr.TTL(key); ttl < time.Until(want)-time.Minute || ttl > time.Until(want)+time.Minute {
+				t.Errorf("%s TTL = %v, want about %v", key, ttl, time.Until(want).Round(time.Minute))
+			}
+		}
+	}
+
+	extended, err := env.sessions.ExtendSession(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("ExtendSession: %v", err)
+	}
+	want := session.ExpiresAt.Add(30 * time.Minute)
+	if extended.ExpiresAt != want.Format(time.RFC3339) {
+		t.Errorf("response expires_at = %s, want %s", extended.ExpiresAt, want.Format(time.RFC3339))
+	}
+	assertExpiry(want)
+
+	// The next extension is capped at the maximum lifetime
+	if _, err := env.sessions.ExtendSession(ctx, created.ID); err != nil {
+		t.Fatalf("second ExtendSession: %v", err)
+	}
+	assertExpiry(session.CreatedAt.Add(2 * time.Hour))
+
+	if _, err := env.sessions.ExtendSession(ctx, created.ID); !errors.Is(err, ErrExtensionLimitReached) {
+		t.Errorf("beyond the maximum: err = %v, want %v", err, ErrExtensionLimitReached)
+	}
+	assertExpiry(session.CreatedAt.Add(2 * time.Hour))
+}
+
+func TestSetMuted(t *testing.T) {
+	env := newTestEnv(t, nil)
+	ctx := context.Background()
+	created := env.createSession(t)
+	hostID := env.claims(t, created.Token).UserID
+	viewerID := env.claims(t, env.join(t, created.ID).Token).UserID
+
+	if err := env.sessions.SetMuted(ctx, created.ID, viewerID, true); err != nil {
+		t.Fatalf("mute: %v", err)
+	}
+	if muted, err := env.redis.IsUserMuted(ctx, created.ID, viewerID); err != nil || !muted {
+		t.Errorf("after mute: muted = %v, err = %v", muted, err)
+	}
+
+	if err := env.sessions.SetMuted(ctx, created.ID, viewerID, false); err != nil {
+		t.Fatalf("unmute: %v", err)
+	}
+	if muted, err := env.redis.IsUserMuted(ctx, created.ID, viewerID); err != nil || muted {
+		t.Errorf("after unmute: muted = %v, err = %v", muted, err)
+	}
+
+	if err := env.sessions.SetMuted(ctx, created.ID, hostID, true); !errors.Is(err, ErrCannotMuteHost) {
+		t.Errorf("muting the host: err = %v, want %v", err, ErrCannotMuteHost)
+	}
+	stranger := "6f1c1a4e-7a61-4c3b-9a53-0d1f5f3e2b7c"
+	if err := env.sessions.SetMuted(ctx, created.ID, stranger, true); !errors.Is(err, ErrParticipantNotFound) {
+		t.Errorf("muting a non-participant: err = %v, want %v", err, ErrParticipantNotFound)
+	}
+}
+
+func TestJoinIsAudited(t *testing.T) {
+	env := newTestEnv(t, nil)
+	ctx := context.Background()
+	created := env.createSession(t)
+	joined := env.join(t, created.ID)
+	joinerID := env.claims(t, joined.Token).UserID
+
+	entries, err := env.sessions.GetAuditLog(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetAuditLog: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Event != models.AuditEventCreate {
+		t.Fatalf("audit log = %+v, want create then join", entries)
+	}
+	join := entries[1]
+	if join.Event != models.AuditEventJoin || join.Actor != joinerID {
+		t.Errorf("join entry = %+v, want a join by %s", join, joinerID)
+	}
+	if join.IP != "203.0.113.0" {
+		t.Errorf("join IP = %q, want it truncated to 203.0.113.0", join.IP)
+	}
+	if join.Timestamp == 0 {
+		t.Error("join entry has no timestamp")
+	}
+
+	raw, err := env.mr.List("audit:" + created.ID)
+	if err != nil {
+		t.Fatalf("read audit list: %v", err)
+	}
+	for _, entry := range raw {
+		for _, secret := range []string{testPassword, testIP, created.Token, joined.Token} {
+			if strings.Contains(entry, secret) {
+				t.Errorf("audit entry %s contains a password, full IP or token", entry)
+			}
+		}
+	}
+}
+
+func TestAuditLogIsCapped(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.AuditLogSize = 3
+	})
+	created := env.createSession(t)
+	var last string
+	for i := 0; i < 4; i++ {
+		last = env.claims(t, env.join(t, created.ID).Token).UserID
+	}
+
+	entries, err := env.sessions.GetAuditLog(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetAuditLog: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("audit log has %d entries, want 3", len(entries))
+	}
+	if entries[2].Actor != last {
+		t.Errorf("newest entry is by %s, want the last joiner %s", entries[2].Actor, last)
+	}
+}
+
+func TestConcurrentJoinsNeverOverfill(t *testing.T) {
+	const spots = 5
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.MaxParticipants = spots + 1 // the host takes one place
+	})
+	created := env.createSession(t)
+
+	var wg sync.WaitGroup
+	results := make(chan error, spots+5)
+	for i := 0; i < spots+5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := env.sessions.JoinSession(context.Background(), &models.JoinSessionRequest{
+				SessionID: created.ID,
+				Password:  testPassword,
+			}, testIP, "")
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	joined := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			joined++
+		case !errors.Is(err, ErrSessionFull):
+			t.Errorf("join failed with %v, want %v", err, ErrSessionFull)
+		}
+	}
+	if joined != spots {
+		t.Errorf("%d joins succeeded, want %d", joined, spots)
+	}
+	if n := len(env.session(t, created.ID).Participants); n != spots+1 {
+		t.Errorf("session has %d participants, want %d", n, spots+1)
+	}
+}
+
+func TestRejoinWithPriorTokenKeepsParticipantCount(t *testing.T) {
+	env := newTestEnv(t, nil)
+	ctx := context.Background()
+	created := env.createSession(t)
+	first := env.join(t, created.ID)
+	userID := env.claims(t, first.Token).UserID
+	before := len(env.session(t, created.ID).Participants)
+
+	rejoined, err := env.sessions.JoinSession(ctx, &models.JoinSessionRequest{
+		SessionID: created.ID,
+		Token:     first.Token,
+	}, testIP, "")
+	if err != nil {
+		t.Fatalf("rejoin: %v", err)
+	}
+	if after := len(env.session(t, created.ID).Participants); after != before {
+		t.Errorf("participants went from %d to %d on rejoin", before, after)
+	}
+	claims := env.claims(t, rejoined.Token)
+	if claims.UserID != userID || rejoined.Username != first.Username {
+		t.Errorf("rejoined as %s (%s), want %s (%s)", claims.UserID, rejoined.Username, userID, first.Username)
+	}
+}
+
+func TestRejoinIgnoresTokensThatDontBelong(t *testing.T) {
+	env := newTestEnv(t, nil)
+	ctx := context.Background()
+	created := env.createSession(t)
+	other := env.createSession(t)
+	foreign := env.join(t, other.ID).Token
+
+	// A token for another session is no shortcut: it needs the password
+	// and joins as a new participant
+	_, err := env.sessions.JoinSession(ctx, &models.JoinSessionRequest{
+		SessionID: created.ID,
+		Token:     foreign,
+	}, testIP, "")
+	if !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("foreign token without password: err = %v, want %v", err, ErrInvalidPassword)
+	}
+
+	joined, err := env.sessions.JoinSession(ctx, &models.JoinSessionRequest{
+		SessionID: created.ID,
+		Password:  testPassword,
+		Token:     foreign,
+	}, testIP, "")
+	if err != nil {
+		t.Fatalf("foreign token with password: %v", err)
+	}
+	if env.claims(t, joined.Token).UserID == env.claims(t, foreign).UserID {
+		t.Error("a token from another session kept its user ID")
+	}
+	if n := len(env.session(t, created.ID).Participants); n != 2 {
+		t.Errorf("session has %d participants, want 2", n)
+	}
+}
+
+func TestRejoinAfterRemovalNeedsPassword(t *testing.T) {
+	env := newTestEnv(t, nil)
+	ctx := context.Background()
+	created := env.createSession(t)
+	first := env.join(t, created.ID)
+	if err := env.redis.RemoveParticipant(ctx, created.ID, env.claims(t, first.Token).UserID); err != nil {
+		t.Fatalf("RemoveParticipant: %v", err)
+	}
+
+	_, err := env.sessions.JoinSession(ctx, &models.JoinSessionRequest{
+		SessionID: created.ID,
+		Token:     first.Token,
+	}, testIP, "")
+	if !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidPassword)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	for _, revoke := range []bool{true, false} {
+		name := "keep tokens"
+		if revoke {
+			name = "revoke tokens"
+		}
+		t.Run(name, func(t *testing.T) {
+			env := newTestEnv(t, func(cfg *config.Config) {
+				cfg.PasswordChangeRevokes = revoke
+			})
+			ctx := context.Background()
+			created := env.createSession(t)
+			hostID := env.claims(t, created.Token).UserID
+			viewer := env.join(t, created.ID)
+			viewerID := env.claims(t, viewer.Token).UserID
+
+			resp, removed, err := env.sessions.ChangePassword(ctx, created.ID, hostID, &models.ChangePasswordRequest{Password: "fresh-secret456"})
+			if err != nil {
+				t.Fatalf("ChangePassword: %v", err)
+			}
+			if resp.TokensRevoked != revoke || (resp.Token != "") != revoke {
+				t.Errorf("response %+v, want tokens revoked = %v", resp, revoke)
+			}
+
+			_, err = env.sessions.JoinSession(ctx, &models.JoinSessionRequest{
+				SessionID: created.ID,
+				Password:  testPassword,
+			}, testIP, "")
+			if !errors.Is(err, ErrInvalidPassword) {
+				t.Errorf("join with old password: err = %v, want %v", err, ErrInvalidPassword)
+			}
+
+			// A revoked viewer has to join again with the new password
+			_, err = env.sessions.JoinSession(ctx, &models.JoinSessionRequest{
+				SessionID: created.ID,
+				Token:     viewer.Token,
+			}, testIP, "")
+			if revoke {
+				if len(removed) != 1 || removed[0] != viewerID {
+					t.Errorf("removed = %v, want [%s]", removed, viewerID)
+				}
+				if !errors.Is(err, ErrInvalidPassword) {
+					t.Errorf("rejoin with revoked token: err = %v, want %v", err, ErrInvalidPassword)
+				}
+				if tokenUser := env.claims(t, resp.Token).UserID; tokenUser != hostID {
+					t.Errorf("new token is for %s, not the host", tokenUser)
+				}
+			} else {
+				if len(removed) != 0 {
+					t.Errorf("removed = %v, want none", removed)
+				}
+				if err != nil {
+					t.Errorf("rejoin with kept token: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestChangePasswordOfPublicSession(t *testing.T) {
+	env := newTestEnv(t, nil)
+	ctx := context.Background()
+	created, err := env.sessions.CreateSession(ctx, &models.CreateSessionRequest{
+		Name:   "Open house",
+		Public: true,
+	}, "http://localhost:5173", testIP, "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	_, _, err = env.sessions.ChangePassword(ctx, created.ID, env.claims(t, created.Token).UserID, &models.ChangePasswordRequest{Password: "fresh-secret456"})
+	if !errors.Is(err, ErrSessionHasNoPassword) {
+		t.Errorf("err = %v, want %v", err, ErrSessionHasNoPassword)
+	}
+}
+
+func TestBuildShareURL(t *testing.T) {
+	tests := []struct {
+		baseURL  string
+		joinPath string
+		want     string
+	}{
+		{"https://watch.example.com", "/join/", "https://watch.example.com/join/abc"},
+		{"https://watch.example.com/", "/join", "https://watch.example.com/join/abc"},
+		{"https://watch.example.com", "/rooms/enter/", "https://watch.example.com/rooms/enter/abc"},
+		{"https://example.com/app", "/#/party/", "https://example.com/app/#/party/abc"},
+		{"https://watch.example.com", "/", "https://watch.example.com/abc"},
+	}
+	for _, tt := range tests {
+		if got := buildShareURL(tt.baseURL, tt.joinPath, "abc"); got != tt.want {
+			t.Errorf("buildShareURL(%q, %q) = %q, want %q", tt.baseURL, tt.joinPath, got, tt.want)
+		}
+	}
+}
+
+func TestCreateSessionUsesCustomJoinPath(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.ShareJoinPath = "/watch/room/"
+	})
+
+	created := env.createSession(t)
+	if want := "http://localhost:5173/watch/room/" + created.ID; created.ShareURL != want {
+		t.Errorf("share URL = %q, want %q", created.ShareURL, want)
+	}
+}
+
+func TestRotateSessionMigratesData(t *testing.T) {
+	env := newTestEnv(t, nil)
+	ctx := context.Background()
+	created := env.createSession(t)
+	hostID := env.claims(t, created.Token).UserID
+	viewer := env.join(t, created.ID)
+	viewerID := env.claims(t, viewer.Token).UserID
+	if err := env.redis.SaveChatMessage(ctx, created.ID, []byte(`{"type":"chat","payload":{"message":"hi"}}`)); err != nil {
+		t.Fatalf("SaveChatMessage: %v", err)
+	}
+	before := env.session(t, created.ID)
+
+	spectatorID := uuid.NewString()
+	spectators := map[string]string{spectatorID: "Quiet Heron"}
+
+	resp, tokens, err := env.sessions.RotateSession(ctx, created.ID, hostID, "https://watch.example.com", spectators)
+	if err != nil {
+		t.Fatalf("RotateSession: %v", err)
+	}
+	if resp.ID == created.ID || resp.PreviousID != created.ID {
+		t.Fatalf("response %+v, want a new ID replacing %s", resp, created.ID)
+	}
+	if want := "https://watch.example.com/join/" + resp.ID; resp.ShareURL != want {
+		t.Errorf("share URL = %q, want %q", resp.ShareURL, want)
+	}
+
+	after := env.session(t, resp.ID)
+	if after.ID != resp.ID || after.Name != before.Name || after.HostID != hostID || after.PasswordHash != before.PasswordHash {
+		t.Errorf("migrated session %+v does not match the original %+v", after, before)
+	}
+	if len(after.Participants) != 2 || after.Usernames[viewerID] != viewer.Username {
+		t.Errorf("participants = %v, usernames = %v", after.Participants, after.Usernames)
+	}
+	if old, err := env.redis.GetSession(ctx, created.ID); err != nil || old != nil {
+		t.Errorf("old session still readable: %+v (%v)", old, err)
+	}
+	history, err := env.redis.GetChatHistory(ctx, resp.ID)
+	if err != nil || len(history) != 1 {
+		t.Errorf("chat history under the new ID = %d messages (%v), want 1", len(history), err)
+	}
+
+	// Everyone gets a token for the new ID under the same identity
+	for _, userID := range []string{hostID, viewerID} {
+		claims := env.claims(t, tokens[userID])
+		if claims.SessionID != resp.ID || claims.UserID != userID || claims.IsHost != (userID == hostID) {
+			t.Errorf("token for %s has claims %+v", userID, claims)
+		}
+	}
+	if resp.Token != tokens[hostID] {
+		t.Error("host's response token differs from the re-issued one")
+	}
+	// Connected spectators stay spectators
+	if claims := env.claims(t, tokens[spectatorID]); claims.SessionID != resp.ID || claims.Username != "Quiet Heron" || !claims.IsSpectator || claims.IsHost {
+		t.Errorf("spectator token has claims %+v", claims)
+	}
+	if after := env.session(t, resp.ID); slices.Contains(after.Participants, spectatorID) {
+		t.Error("spectator was made a participant")
+	}
+
+	// The old ID is gone for good
+	_, err = env.sessions.JoinSession(ctx, &models.JoinSessionRequest{
+		SessionID: created.ID,
+		Password:  testPassword,
+	}, testIP, "")
+	if err == nil {
+		t.Error("joined the old session ID after rotation")
+	}
+	if _, err := env.sessions.JoinSession(ctx, &models.JoinSessionRequest{
+		SessionID: resp.ID,
+		Password:  testPassword,
+	}, testIP, ""); err != nil {
+		t.Errorf("join under the new ID: %v", err)
+	}
+}
+
+func TestJoinAndInfoReportSpotsRemaining(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.MaxParticipants = 3
+	})
+	ctx := context.Background()
+	created := env.createSession(t)
+
+	for _, want := range []struct {
+		spots int
+		full  bool
+	}{{1, false}, {0, true}} {
+		joined := env.join(t, created.ID)
+		if joined.SpotsRemaining != want.spots || joined.IsFull != want.full {
+			t.Errorf("join: spots_remaining = %d, is_full = %v, want %d and %v",
+				joined.SpotsRemaining, joined.IsFull, want.spots, want.full)
+		}
+		info, err := env.sessions.GetSession(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("GetSession: %v", err)
+		}
+		if info.SpotsRemaining != want.spots || info.IsFull != want.full {
+			t.Errorf("info: spots_remaining = %d, is_full = %v, want %d and %v",
+				info.SpotsRemaining, info.IsFull, want.spots, want.full)
+		}
+	}
+
+	_, err := env.sessions.JoinSession(ctx, &models.JoinSessionRequest{
+		SessionID: created.ID,
+		Password:  testPassword,
+	}, testIP, "")
+	if !errors.Is(err, ErrSessionFull) {
+		t.Errorf("join of full session: got %v, want %v", err, ErrSessionFull)
+	}
+}
+
+func TestSpectatorTakesNoParticipantSlot(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.MaxParticipants = 2
+	})
+	created := env.createSession(t)
+	env.join(t, created.ID)
+
+	// The session is full, but spectators don't need a slot
+	resp, err := env.sessions.JoinSession(context.Background(), &models.JoinSessionRequest{
+		SessionID: created.ID,
+		Password:  testPassword,
+		Spectator: true,
+	}, testIP, "")
+	if err != nil {
+		t.Fatalf("spectator join of full session: %v", err)
+	}
+	if !resp.Spectator || !resp.IsFull {
+		t.Errorf("response spectator = %v, is_full = %v, want both true", resp.Spectator, resp.IsFull)
+	}
+	claims := env.claims(t, resp.Token)
+	if !claims.IsSpectator || claims.IsHost {
+		t.Errorf("claims = %+v, want a non-host spectator", claims)
+	}
+	session := env.session(t, created.ID)
+	if len(session.Participants) != 2 || slices.Contains(session.Participants, claims.UserID) {
+		t.Errorf("participants = %v, want the spectator left out", session.Participants)
+	}
+
+	// Spectators still need the password
+	_, err = env.sessions.JoinSession(context.Background(), &models.JoinSessionRequest{
+		SessionID: created.ID,
+		Password:  "wrong-password",
+		Spectator: true,
+	}, testIP, "")
+	if !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("spectator join with wrong password: got %v, want %v", err, ErrInvalidPassword)
+	}
+}
+
+func TestMediaURLMustBeOnAllowList(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.AllowedMediaDomains = []string{"youtube.com"}
+	})
+	ctx := context.Background()
+
+	_, err := env.sessions.CreateSession(ctx, &models.CreateSessionRequest{
+		Name:     "Movie night",
+		Password: testPassword,
+		MediaURL: "https://example.com/movie.mp4",
+	}, "http://localhost:5173", testIP, "")
+	if !errors.Is(err, ErrMediaURLNotAllowed) {
+		t.Errorf("create with unlisted media: got %v, want %v", err, ErrMediaURLNotAllowed)
+	}
+
+	created := env.createSession(t)
+	const allowed = "https://www.youtube.com/watch?v=abc"
+	for _, tt := range []struct {
+		url     string
+		wantErr error
+		stored  string
+	}{
+		{allowed, nil, allowed},
+		{"https://example.com/movie.mp4", ErrMediaURLNotAllowed, allowed},
+		{"", nil, ""}, // clearing the media is always allowed
+	} {
+		_, err := env.sessions.UpdateMedia(ctx, created.ID, &models.UpdateMediaRequest{MediaTitle: "Trailer", MediaURL: tt.url})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("UpdateMedia(%q): got %v, want %v", tt.url, err, tt.wantErr)
+		}
+		if got := env.session(t, created.ID).MediaURL; got != tt.stored {
+			t.Errorf("after UpdateMedia(%q), media URL = %q, want %q", tt.url, got, tt.stored)
+		}
+	}
+}
+
+func TestMaxTotalSessions(t *testing.T) {
+	const limit = 2
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.MaxTotalSessions = limit
+	})
+	ctx := context.Background()
+	createFrom := func(ip string) (*models.CreateSessionResponse, error) {
+		return env.sessions.CreateSession(ctx, &models.CreateSessionRequest{
+			Name:     "Movie night",
+			Password: testPassword,
+		}, "http://localhost:5173", ip, "")
+	}
+
+	// The cap spans every client, unlike the per-IP limit
+	first, err := createFrom("198.51.100.1")
+	if err != nil {
+		t.Fatalf("first session: %v", err)
+	}
+	if _, err := createFrom("198.51.100.2"); err != nil {
+		t.Fatalf("second session: %v", err)
+	}
+	if _, err := createFrom("198.51.100.3"); !errors.Is(err, ErrServerAtCapacity) {
+		t.Fatalf("session %d: err = %v, want %v", limit+1, err, ErrServerAtCapacity)
+	}
+	if members, _ := env.mr.ZMembers(activeSessionsKey); len(members) != limit {
+		t.Errorf("refused session left %d slots claimed, want %d", len(members), limit)
+	}
+
+	// Ending a session frees its slot
+	if err := env.sessions.TerminateSession(ctx, first.ID); err != nil {
+		t.Fatalf("TerminateSession: %v", err)
+	}
+	if _, err := createFrom("198.51.100.3"); err != nil {
+		t.Errorf("after a session ended: %v", err)
+	}
+}
+
+func TestMaxTotalSessionsIgnoresExpiredSessions(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.MaxTotalSessions = 1
+	})
+
+	// A session that lapsed via TTL without being deleted
+	expired := float64(time.Now().Add(-time.Minute).Unix())
+	if _, err := env.mr.ZAdd(activeSessionsKey, expired, "lapsed-session"); err != nil {
+		t.Fatalf("ZAdd: %v", err)
+	}
+
+	env.createSession(t)
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+)
+
+// webhookQueueSize is how many events may wait for delivery before new
+// ones are dropped
+const webhookQueueSize = 256
+
+// Headers sent with every webhook delivery
+const (
+	WebhookSignatureHeader = "X-Watchparty-Signature"
+	WebhookTimestampHeader = "X-Watchparty-Timestamp"
+	WebhookEventHeader     = "X-Watchparty-Event"
+)
+
+// WebhookService POSTs session events to the configured URLs. Deliveries
+// happen on a background worker with a timeout and retries, so callers such
+// as the hub never wait on a slow receiver.
+//
+// When WEBHOOK_SECRET is set, each request carries
+//
+//	X-Watchparty-Timestamp: <unix seconds>
+//	X-Watchparty-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// Receivers should recompute the HMAC over the raw body with the shared
+// secret, compare it in constant time, and reject stale timestamps to
+// prevent replays.
+type WebhookService struct {
+	config     *config.Config
+	httpClient *http.Client
+	queue      chan *webhookDelivery
+}
+
+type webhookDelivery struct {
+	event string
+	body  []byte
+}
+
+// NewWebhookService creates a new webhook service instance
+func NewWebhookService(cfg *config.Config) *WebhookService {
+	return &WebhookService{
+		config: cfg,
+		httpClient: &http.Client{
+			Timeout: cfg.WebhookTimeout,
+		},
+		queue: make(chan *webhookDelivery, webhookQueueSize),
+	}
+}
+
+// Enabled reports whether any webhook URL is configured
+func (s *WebhookService) Enabled() bool {
+	return s != nil && len(s.config.WebhookURLs) > 0
+}
+
+// Notify queues an event for delivery without blocking. Events are dropped
+// if webhooks are disabled or the queue is full.
+func (s *WebhookService) Notify(event, sessionID string, data interface{}) {
+	if !s.Enabled() {
+		return
+	}
+
+	body, err := json.Marshal(models.WebhookEvent{
+		Event:     event,
+		SessionID: sessionID,
+		Data:      data,
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		slog.Error("Failed to marshal webhook event", "event", event, "session_id", sessionID, "error", err)
+		return
+	}
+
+	select {
+	case s.queue <- &webhookDelivery{event: event, body: body}:
+	default:
+		slog.Warn("Webhook queue full, dropping event", "event", event, "session_id", sessionID)
+	}
+}
+
+// Run delivers queued events until ctx is cancelled
+func (s *WebhookService) Run(ctx context.Context) {
+	for {
+		select {
+		case delivery := <-s.queue:
+			s.deliverAll(ctx, delivery)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Drain delivers the events still queued once Run has returned, until the
+// queue is empty or ctx is done. Shutdown calls it so events raised while
+// the server was stopping aren't lost.
+func (s *WebhookService) Drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		select {
+		case delivery := <-s.queue:
+			s.deliverAll(ctx, delivery)
+		default:
+			return
+		}
+	}
+	if n := len(s.queue); n > 0 {
+		slog.Warn("Webhook queue not drained, dropping events", "events", n)
+	}
+}
+
+// deliverAll sends an event to every configured URL
+func (s *WebhookService) deliverAll(ctx context.Context, delivery *webhookDelivery) {
+	for _, url := range s.config.WebhookURLs {
+		if err := s.deliverWithRetry(ctx, url, delivery); err != nil {
+			slog.Error("Webhook delivery failed", "url", url, "event", delivery.event, "error", err)
+		}
+	}
+}
+
+// deliverWithRetry posts an event, retrying transient failures with
+// exponential backoff until the retry budget or the context runs out
+func (s *WebhookService) deliverWithRetry(ctx context.Context, url string, delivery *webhookDelivery) error {
+	backoff := 500 * time.Millisecond
+
+	var lastErr error
+	for attempt := 0; attempt <= s.config.WebhookRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		retry, err := s.deliver(ctx, url, delivery)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+		slog.Warn("Webhook delivery failed, retrying", "url", url, "event", delivery.event, "attempt", attempt+1, "error", err)
+	}
+	return lastErr
+}
+
+// deliver posts an event once. The retry result reports whether the failure
+// is transient (network error, 429 or 5xx).
+func (s *WebhookService) deliver(ctx context.Context, url string, delivery *webhookDelivery) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(delivery.body))
+	if err != nil {
+		return false, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.event)
+
+	// Sign each attempt afresh so retries carry a current timestamp
+	if s.config.WebhookSecret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(s.config.WebhookSecret, timestamp, delivery.body))
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		transient := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return transient, fmt.Errorf("receiver returned status %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>" under
+// secret, as sent in the signature header
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
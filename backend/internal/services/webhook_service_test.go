@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+)
+
+// webhookRequest is a delivery seen by a test receiver
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newWebhookReceiver starts a receiver that answers each delivery with the
+// status returned by respond and passes the requests it sees to the
+// returned channel
+func newWebhookReceiver(t *testing.T, respond func(attempt int) int) (*httptest.Server, <-chan webhookRequest) {
+	t.Helper()
+	requests := make(chan webhookRequest, 16)
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- webhookRequest{header: r.Header.Clone(), body: body}
+		w.WriteHeader(respond(int(attempts.Add(1))))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// newWebhookTestService starts a webhook service delivering to url until
+// the test ends
+func newWebhookTestService(t *testing.T, url string, configure func(*config.Config)) *WebhookService {
+	t.Helper()
+	cfg := config.Load()
+	cfg.WebhookURLs = []string{url}
+	cfg.WebhookSecret = "hook-secret"
+	cfg.WebhookTimeout = time.Second
+	cfg.WebhookRetries = 0
+	if configure != nil {
+		configure(cfg)
+	}
+	webhooks := NewWebhookService(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go webhooks.Run(ctx)
+	return webhooks
+}
+
+// nextRequest waits for the receiver's next request
+func nextRequest(t *testing.T, requests <-chan webhookRequest) webhookRequest {
+	t.Helper()
+	select {
+	case req := <-requests:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook delivered")
+		return webhookRequest{}
+	}
+}
+
+func TestWebhookIsSignedAndVerifiable(t *testing.T) {
+	server, requests := newWebhookReceiver(t, func(int) int { return http.StatusNoContent })
+	webhooks := newWebhookTestService(t, server.URL, nil)
+
+	webhooks.Notify(models.WebhookUserJoined, "session-1", &models.WebhookUserData{UserID: "user-1", Username: "Popcorn"})
+	req := nextRequest(t, requests)
+
+	if got := req.header.Get(WebhookEventHeader); got != models.WebhookUserJoined {
+		t.Errorf("event header = %q, want %q", got, models.WebhookUserJoined)
+	}
+	// Verify the way a receiver would: recompute the HMAC over the raw body
+	// and reject stale timestamps
+	timestamp := req.header.Get(WebhookTimestampHeader)
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+		t.Errorf("timestamp header = %q, want the current time", timestamp)
+	}
+	want := "sha256=" + SignWebhook("hook-secret", timestamp, req.body)
+	if got := req.header.Get(WebhookSignatureHeader); !hmac.Equal([]byte(got), []byte(want)) {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if SignWebhook("other-secret", timestamp, req.body) == SignWebhook("hook-secret", timestamp, req.body) {
+		t.Error("signature does not depend on the secret")
+	}
+
+	var event struct {
+		models.WebhookEvent
+		Data models.WebhookUserData `json:"data"`
+	}
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatalf("body %s: %v", req.body, err)
+	}
+	if event.Event != models.WebhookUserJoined || event.SessionID != "session-1" || event.Data.UserID != "user-1" || event.Timestamp == 0 {
+		t.Errorf("event = %+v", event)
+	}
+}
+
+func TestWebhookRetriesTransientFailures(t *testing.T) {
+	server, requests := newWebhookReceiver(t, func(attempt int) int {
+		if attempt == 1 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	webhooks := newWebhookTestService(t, server.URL, func(cfg *config.Config) {
+		cfg.WebhookRetries = 2
+	})
+
+	webhooks.Notify(models.WebhookSessionCreated, "session-1", nil)
+	first, second := nextRequest(t, requests), nextRequest(t, requests)
+	if string(first.body) != string(second.body) {
+		t.Errorf("retry sent %s, want the original %s", second.body, first.body)
+	}
+	select {
+	case req := <-requests:
+		t.Errorf("unexpected delivery after success: %s", req.body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookClientErrorsAreNotRetried(t *testing.T) {
+	server, requests := newWebhookReceiver(t, func(int) int { return http.StatusBadRequest })
+	webhooks := newWebhookTestService(t, server.URL, func(cfg *config.Config) {
+		cfg.WebhookRetries = 2
+	})
+
+	webhooks.Notify(models.WebhookSessionEnded, "session-1", nil)
+	nextRequest(t, requests)
+	select {
+	case <-requests:
+		t.Error("a 400 response was retried")
+	case <-time.After(700 * time.Millisecond):
+	}
+}
+
+func TestNotifyNeverBlocks(t *testing.T) {
+	// Not running, so nothing drains the queue
+	cfg := config.Load()
+	cfg.WebhookURLs = []string{"http://127.0.0.1:1"}
+	webhooks := NewWebhookService(cfg)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*webhookQueueSize; i++ {
+			webhooks.Notify(models.WebhookUserLeft, "session-1", nil)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Notify blocked on a full queue")
+	}
+
+	var disabled *WebhookService
+	if disabled.Enabled() || NewWebhookService(config.Load()).Enabled() {
+		t.Error("webhooks enabled without a URL")
+	}
+}
+
+func TestDrainDeliversQueuedEvents(t *testing.T) {
+	server, requests := newWebhookReceiver(t, func(int) int { return http.StatusOK })
+	cfg := config.Load()
+	cfg.WebhookURLs = []string{server.URL}
+	cfg.WebhookTimeout = time.Second
+	// Not running, as after shutdown stopped the worker
+	webhooks := NewWebhookService(cfg)
+	webhooks.Notify(models.WebhookUserJoined, "session-1", nil)
+	webhooks.Notify(models.WebhookUserLeft, "session-1", nil)
+
+	webhooks.Drain(context.Background())
+	for _, want := range []string{models.WebhookUserJoined, models.WebhookUserLeft} {
+		if got := nextRequest(t, requests).header.Get(WebhookEventHeader); got != want {
+			t.Errorf("delivered %q, want %q", got, want)
+		}
+	}
+}
+
+func TestDrainStopsAtDeadline(t *testing.T) {
+	// Doesn't answer until the test ends, well past the drain's deadline
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	cfg := config.Load()
+	cfg.WebhookURLs = []string{server.URL}
+	cfg.WebhookTimeout = 5 * time.Second
+	webhooks := NewWebhookService(cfg)
+	for i := 0; i < 3; i++ {
+		webhooks.Notify(models.WebhookUserLeft, "session-1", nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	webhooks.Drain(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Drain took %v past its 100ms deadline", elapsed)
+	}
+}
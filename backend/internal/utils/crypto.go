@@ -1,24 +1,36 @@
 package utils
 
 import (
+	"fmt"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
 const (
-	// BcryptCost is the cost factor for bcrypt hashing
-	BcryptCost = 12
+	// DefaultBcryptCost is the cost factor for bcrypt hashing when none is configured
+	DefaultBcryptCost = 10
 )
 
-// HashPassword creates a bcrypt hash of the password
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
+// IsValidBcryptCost checks if a cost is within bcrypt's allowed range
+func IsValidBcryptCost(cost int) bool {
+	return cost >= bcrypt.MinCost && cost <= bcrypt.MaxCost
+}
+
+// HashPassword creates a bcrypt hash of the password at the given cost.
+// Each increment of cost doubles hashing time.
+func HashPassword(password string, cost int) (string, error) {
+	if !IsValidBcryptCost(cost) {
+		return "", fmt.Errorf("bcrypt cost %d outside allowed range %d-%d", cost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return "", err
 	}
 	return string(bytes), nil
 }
 
-// CheckPassword compares a password with a hash
+// CheckPassword compares a password with a hash. The cost is read from the
+// hash itself, so hashes created at any cost remain valid.
 func CheckPassword(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
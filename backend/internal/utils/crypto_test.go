@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPasswordAcrossCosts(t *testing.T) {
+	for _, cost := range []int{bcrypt.MinCost, 6, 8} {
+		hash, err := HashPassword("secret123", cost)
+		if err != nil {
+			t.Fatalf("HashPassword at cost %d: %v", cost, err)
+		}
+		if got, _ := bcrypt.Cost([]byte(hash)); got != cost {
+			t.Errorf("hash cost = %d, want %d", got, cost)
+		}
+		if !CheckPassword("secret123", hash) {
+			t.Errorf("cost %d: correct password rejected", cost)
+		}
+		if CheckPassword("secret124", hash) {
+			t.Errorf("cost %d: wrong password accepted", cost)
+		}
+	}
+}
+
+func TestHashPasswordRejectsInvalidCost(t *testing.T) {
+	for _, cost := range []int{0, bcrypt.MinCost - 1, bcrypt.MaxCost + 1} {
+		if _, err := HashPassword("secret123", cost); err == nil {
+			t.Errorf("HashPassword accepted cost %d", cost)
+		}
+	}
+}
+
+// BenchmarkHashPassword shows what each BCRYPT_COST step costs per session
+// created: every increment doubles the time. Run with
+//
+//	go test -bench HashPassword ./internal/utils
+func BenchmarkHashPassword(b *testing.B) {
+	for _, cost := range []int{bcrypt.MinCost, 8, DefaultBcryptCost, 12} {
+		b.Run(fmt.Sprintf("cost=%d", cost), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := HashPassword("secret123", cost); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCheckPassword measures a join, which pays the cost of the hash
+// it is checked against
+func BenchmarkCheckPassword(b *testing.B) {
+	for _, cost := range []int{bcrypt.MinCost, 8, DefaultBcryptCost, 12} {
+		hash, err := HashPassword("secret123", cost)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("cost=%d", cost), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				CheckPassword("secret123", hash)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package utils
+
+import "net"
+
+// TruncateIP drops the host part of an address so it can be stored without
+// identifying a single user: IPv4 keeps its /24 and IPv6 its /48. Anything
+// that doesn't parse is dropped entirely.
+func TruncateIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"unicode"
+)
+
+// MaxPasswordBytes is the longest password bcrypt accepts. Longer ones are
+// refused by HashPassword, and rejecting them early also keeps oversized
+// input away from the hashing cost.
+const MaxPasswordBytes = 72
+
+// PasswordPolicy describes the requirements for session passwords
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int // in bytes, at most MaxPasswordBytes
+	RequireDigit  bool
+	RequireLetter bool
+}
+
+// DefaultPasswordPolicy is deliberately lenient so existing users aren't broken
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 6, MaxLength: MaxPasswordBytes}
+
+var (
+	passwordPolicyMu sync.RWMutex
+	passwordPolicy   = DefaultPasswordPolicy
+)
+
+// SetPasswordPolicy replaces the policy used by request validation
+func SetPasswordPolicy(policy PasswordPolicy) {
+	passwordPolicyMu.Lock()
+	defer passwordPolicyMu.Unlock()
+	passwordPolicy = policy
+}
+
+// GetPasswordPolicy returns the policy used by request validation
+func GetPasswordPolicy() PasswordPolicy {
+	passwordPolicyMu.RLock()
+	defer passwordPolicyMu.RUnlock()
+	return passwordPolicy
+}
+
+// ValidatePassword checks a password against a policy and returns one
+// message per violated requirement
+func ValidatePassword(password string, policy PasswordPolicy) []string {
+	var violations []string
+
+	if len(password) < policy.MinLength {
+		violations = append(violations, fmt.Sprintf("Password must be at least %d characters", policy.MinLength))
+	}
+	if policy.MaxLength > 0 && len(password) > policy.MaxLength {
+		// Bytes rather than characters, since that is what bcrypt limits
+		violations = append(violations, fmt.Sprintf("Password must be at most %d bytes", policy.MaxLength))
+	}
+
+	hasDigit, hasLetter := false, false
+	for _, r := range password {
+		switch {
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsLetter(r):
+			hasLetter = true
+		}
+	}
+
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, "Password must contain a digit")
+	}
+	if policy.RequireLetter && !hasLetter {
+		violations = append(violations, "Password must contain a letter")
+	}
+
+	return violations
+}
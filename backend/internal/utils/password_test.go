@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	lenient := DefaultPasswordPolicy
+	digit := PasswordPolicy{MinLength: 6, MaxLength: MaxPasswordBytes, RequireDigit: true}
+	letter := PasswordPolicy{MinLength: 6, MaxLength: MaxPasswordBytes, RequireLetter: true}
+	both := PasswordPolicy{MinLength: 8, MaxLength: MaxPasswordBytes, RequireDigit: true, RequireLetter: true}
+
+	tests := []struct {
+		name     string
+		password string
+		policy   PasswordPolicy
+		want     []string
+	}{
+		{"lenient ok", "abcdef", lenient, nil},
+		{"lenient too short", "abc", lenient, []string{"at least 6"}},
+		{"lenient too long", strings.Repeat("a", MaxPasswordBytes+1), lenient, []string{"at most 72 bytes"}},
+		{"digit ok", "abcde1", digit, nil},
+		{"digit missing", "abcdef", digit, []string{"digit"}},
+		{"letter ok", "12345a", letter, nil},
+		{"letter missing", "123456", letter, []string{"letter"}},
+		{"both ok", "abcd1234", both, nil},
+		{"both missing digit", "abcdefgh", both, []string{"digit"}},
+		{"both missing letter", "12345678", both, []string{"letter"}},
+		{"both short and missing letter", "123", both, []string{"at least 8", "letter"}},
+		{"unicode letters count", "пароль12", both, nil},
+		{"no maximum", strings.Repeat("a", 100), PasswordPolicy{MinLength: 6}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidatePassword(tt.password, tt.policy)
+			if len(got) != len(tt.want) {
+				t.Fatalf("ValidatePassword(%q) = %q, want %d violations", tt.password, got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("violation %d = %q, want it to mention %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
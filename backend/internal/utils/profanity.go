@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultProfanityList is used when no custom list is configured
+var DefaultProfanityList = []string{
+	"fuck", "fucking", "shit", "bitch", "ass", "asshole", "bastard",
+	"dick", "cunt", "piss", "slut", "whore",
+}
+
+var (
+	profanityMu    sync.RWMutex
+	profanityRegex = buildProfanityRegex(DefaultProfanityList)
+)
+
+// SetProfanityList replaces the words masked by FilterProfanity
+func SetProfanityList(words []string) {
+	re := buildProfanityRegex(words)
+	profanityMu.Lock()
+	profanityRegex = re
+	profanityMu.Unlock()
+}
+
+// FilterProfanity masks listed words with asterisks. Matching is
+// case-insensitive and only whole words are masked, so "assassin" is left
+// untouched while "Ass" is not.
+func FilterProfanity(text string) string {
+	profanityMu.RLock()
+	re := profanityRegex
+	profanityMu.RUnlock()
+
+	if re == nil {
+		return text
+	}
+	return re.ReplaceAllStringFunc(text, func(word string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(word))
+	})
+}
+
+// ContainsProfanity reports whether text contains any listed word
+func ContainsProfanity(text string) bool {
+	profanityMu.RLock()
+	re := profanityRegex
+	profanityMu.RUnlock()
+
+	return re != nil && re.MatchString(text)
+}
+
+func buildProfanityRegex(words []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		if w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+}
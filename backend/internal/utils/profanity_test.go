@@ -0,0 +1,50 @@
+package utils
+
+import "testing"
+
+func TestFilterProfanity(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"clean text", "what a great movie", "what a great movie"},
+		{"whole word", "you ass", "you ***"},
+		{"case insensitive", "ASS and Shit", "*** and ****"},
+		{"word inside another", "assassin in class", "assassin in class"},
+		{"prefix of a word", "passing the bass", "passing the bass"},
+		{"punctuation boundaries", "(shit), ass!", "(****), ***!"},
+		{"hyphenated", "smart-ass", "smart-***"},
+		{"longer listed word", "fucking asshole", "******* *******"},
+		{"repeated", "shit shit", "**** ****"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FilterProfanity(tt.in); got != tt.want {
+				t.Errorf("FilterProfanity(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetProfanityList(t *testing.T) {
+	t.Cleanup(func() { SetProfanityList(DefaultProfanityList) })
+
+	SetProfanityList([]string{" darn ", "", "a.b"})
+	if got := FilterProfanity("Darn it, ass"); got != "**** it, ass" {
+		t.Errorf("custom list: got %q", got)
+	}
+	// Listed words are literal, not patterns
+	if got := FilterProfanity("a.b axb"); got != "*** axb" {
+		t.Errorf("metacharacters: got %q", got)
+	}
+
+	SetProfanityList(nil)
+	if got := FilterProfanity("shit"); got != "shit" {
+		t.Errorf("empty list: got %q, want text unchanged", got)
+	}
+	if ContainsProfanity("shit") {
+		t.Error("empty list: ContainsProfanity reported a match")
+	}
+}
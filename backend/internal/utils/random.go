@@ -3,27 +3,94 @@ package utils
 import (
 	"fmt"
 	"math/rand"
+	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
+// Default word lists used when no custom lists are configured
 var (
-	adjectives = []string{
+	DefaultAdjectives = []string{
 		"Happy", "Swift", "Bright", "Calm", "Cool", "Kind", "Wise", "Brave",
 		"Lucky", "Eager", "Bold", "Fair", "Free", "Glad", "Keen", "Nice",
 	}
-	animals = []string{
+	DefaultAnimals = []string{
 		"Panda", "Eagle", "Tiger", "Lion", "Bear", "Wolf", "Fox", "Hawk",
 		"Owl", "Cat", "Dog", "Duck", "Deer", "Swan", "Seal", "Crab",
 	}
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
+// usernameRand is not safe for concurrent use on its own, so it and the
+// word lists are guarded by usernameMu
+var (
+	usernameMu   sync.Mutex
+	usernameRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	adjectives   = DefaultAdjectives
+	animals      = DefaultAnimals
+)
+
+// SetUsernameWords replaces the words random usernames are built from. An
+// empty list keeps the default for that half of the name.
+func SetUsernameWords(adjectiveList, animalList []string) {
+	usernameMu.Lock()
+	defer usernameMu.Unlock()
+
+	adjectives = DefaultAdjectives
+	if len(adjectiveList) > 0 {
+		adjectives = adjectiveList
+	}
+	animals = DefaultAnimals
+	if len(animalList) > 0 {
+		animals = animalList
+	}
+}
+
+// SetUsernameSource replaces the random source used for usernames, e.g.
+// with rand.NewSource(seed) to get a repeatable sequence in tests. A nil
+// source restores a time-seeded one.
+func SetUsernameSource(src rand.Source) {
+	if src == nil {
+		src = rand.NewSource(time.Now().UnixNano())
+	}
+
+	usernameMu.Lock()
+	defer usernameMu.Unlock()
+	usernameRand = rand.New(src)
+}
+
+// ReadWordList loads a word list from a file with one word per line. Commas
+// also separate words, and blank lines and lines starting with # are skipped.
+func ReadWordList(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read word list: %w", err)
+	}
+
+	var words []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, word := range strings.Split(line, ",") {
+			if word = strings.TrimSpace(word); word != "" {
+				words = append(words, word)
+			}
+		}
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("word list %s is empty", path)
+	}
+	return words, nil
 }
 
 // GenerateRandomUsername generates a random username in the format AdjectiveAnimal
 func GenerateRandomUsername() string {
-	adj := adjectives[rand.Intn(len(adjectives))]
-	animal := animals[rand.Intn(len(animals))]
+	usernameMu.Lock()
+	defer usernameMu.Unlock()
+
+	adj := adjectives[usernameRand.Intn(len(adjectives))]
+	animal := animals[usernameRand.Intn(len(animals))]
 	return fmt.Sprintf("%s%s", adj, animal)
 }
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCustomUsernameWordsAreUsed(t *testing.T) {
+	t.Cleanup(func() {
+		SetUsernameWords(nil, nil)
+		SetUsernameSource(nil)
+	})
+	SetUsernameSource(rand.NewSource(1))
+
+	SetUsernameWords([]string{"Sneaky", "Grumpy"}, []string{"Hobbit"})
+	for i := 0; i < 20; i++ {
+		name := GenerateRandomUsername()
+		if name != "SneakyHobbit" && name != "GrumpyHobbit" {
+			t.Fatalf("username %q is not built from the custom words", name)
+		}
+	}
+
+	// An empty list keeps the defaults for that half
+	SetUsernameWords(nil, []string{"Hobbit"})
+	name := GenerateRandomUsername()
+	adjective, ok := strings.CutSuffix(name, "Hobbit")
+	if !ok || !slices.Contains(DefaultAdjectives, adjective) {
+		t.Errorf("username %q does not pair a default adjective with the custom animal", name)
+	}
+}
+
+func TestReadWordList(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "words.txt")
+	content := "# Movie characters\nFrodo\n\n  Gandalf , Aragorn\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	words, err := ReadWordList(path)
+	if err != nil {
+		t.Fatalf("ReadWordList: %v", err)
+	}
+	if want := []string{"Frodo", "Gandalf", "Aragorn"}; !slices.Equal(words, want) {
+		t.Errorf("words = %v, want %v", words, want)
+	}
+
+	empty := filepath.Join(dir, "empty.txt")
+	if err := os.WriteFile(empty, []byte("# nothing\n\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := ReadWordList(empty); err == nil {
+		t.Error("empty word list was accepted")
+	}
+	if _, err := ReadWordList(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("missing word list was accepted")
+	}
+}
+
+func TestRandomUsernameFormat(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		name := GenerateRandomUsername()
+		if !isAdjectiveAnimal(name) {
+			t.Fatalf("username %q is not an AdjectiveAnimal from the default lists", name)
+		}
+	}
+}
+
+func TestRandomUsernameIsDeterministicWithFixedSeed(t *testing.T) {
+	t.Cleanup(func() { SetUsernameSource(nil) })
+
+	generate := func() []string {
+		SetUsernameSource(rand.NewSource(42))
+		names := make([]string, 10)
+		for i := range names {
+			names[i] = GenerateRandomUsername()
+		}
+		return names
+	}
+	first, second := generate(), generate()
+	if !slices.Equal(first, second) {
+		t.Errorf("same seed gave %v then %v", first, second)
+	}
+	if len(slices.Compact(slices.Clone(first))) == 1 {
+		t.Errorf("seeded source always picks %q", first[0])
+	}
+}
+
+// isAdjectiveAnimal reports whether name joins a default adjective and a
+// default animal
+func isAdjectiveAnimal(name string) bool {
+	for _, adjective := range DefaultAdjectives {
+		if animal, ok := strings.CutPrefix(name, adjective); ok && slices.Contains(DefaultAnimals, animal) {
+			return true
+		}
+	}
+	return false
+}
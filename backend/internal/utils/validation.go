@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"net/url"
 	"regexp"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 var (
@@ -30,11 +32,77 @@ func SanitizeString(s string) string {
 
 // IsValidSessionName checks if a session name is valid
 func IsValidSessionName(name string) bool {
+	return len(ValidateSessionName(name)) == 0
+}
+
+// ValidateSessionName checks a session name after sanitization and returns
+// one message per problem. Names must be 3-50 characters, contain at least
+// one letter or digit, and be free of profanity.
+func ValidateSessionName(name string) []string {
+	var violations []string
 	sanitized := SanitizeString(name)
-	return len(sanitized) >= 3 && len(sanitized) <= 50
+
+	if n := utf8.RuneCountInString(sanitized); n < 3 || n > 50 {
+		violations = append(violations, "Name must be between 3 and 50 characters")
+	}
+
+	hasAlphanumeric := false
+	for _, r := range sanitized {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			hasAlphanumeric = true
+			break
+		}
+	}
+	if !hasAlphanumeric {
+		violations = append(violations, "Name must contain at least one letter or digit")
+	}
+
+	if ContainsProfanity(sanitized) {
+		violations = append(violations, "Name contains inappropriate language")
+	}
+
+	return violations
 }
 
-// IsValidPassword checks if a password meets minimum requirements
+// IsValidPassword checks if a password meets the configured policy
 func IsValidPassword(password string) bool {
-	return len(password) >= 6
+	return len(ValidatePassword(password, GetPasswordPolicy())) == 0
+}
+
+// IsValidUsername checks if a display name is 3-20 characters after sanitization
+func IsValidUsername(username string) bool {
+	n := utf8.RuneCountInString(SanitizeString(username))
+	return n >= 3 && n <= 20
+}
+
+// IsValidMediaURL checks if a string is an absolute http or https URL
+func IsValidMediaURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// IsAllowedMediaURL checks that a media URL is valid and its host is one of
+// the allow-listed domains or a subdomain of one. An empty list allows any host.
+func IsAllowedMediaURL(s string, allowlist []string) bool {
+	if !IsValidMediaURL(s) {
+		return false
+	}
+	if len(allowlist) == 0 {
+		return true
+	}
+	u, _ := url.Parse(s)
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for _, domain := range allowlist {
+		domain = strings.Trim(strings.ToLower(domain), ".")
+		if domain == "" {
+			continue
+		}
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
 }
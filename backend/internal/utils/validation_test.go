@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateSessionName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"normal name", "Friday Movie Night", nil},
+		{"name with emoji", "Movie night 🍿", nil},
+		{"non-Latin letters", "映画の夜", nil},
+		{"digits only", "2024", nil},
+		{"whitespace only", "   ", []string{
+			"Name must be between 3 and 50 characters",
+			"Name must contain at least one letter or digit",
+		}},
+		{"emoji only", "😀😀😀", []string{"Name must contain at least one letter or digit"}},
+		{"symbols only", "!!! ???", []string{"Name must contain at least one letter or digit"}},
+		{"profanity", "shit movies", []string{"Name contains inappropriate language"}},
+		{"profanity inside a word", "Classic films", nil},
+		{"too short after trimming", "  ab  ", []string{"Name must be between 3 and 50 characters"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateSessionName(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateSessionName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAllowedMediaURL(t *testing.T) {
+	allowlist := []string{"youtube.com", "Vimeo.com."}
+	tests := []struct {
+		name      string
+		url       string
+		allowlist []string
+		want      bool
+	}{
+		{"listed domain", "https://youtube.com/watch?v=abc", allowlist, true},
+		{"subdomain", "https://www.youtube.com/watch?v=abc", allowlist, true},
+		{"case and trailing dot", "https://PLAYER.VIMEO.COM./video/1", allowlist, true},
+		{"with port", "https://youtube.com:443/watch?v=abc", allowlist, true},
+		{"unlisted domain", "https://example.com/movie.mp4", allowlist, false},
+		{"listed name as a suffix only", "https://notyoutube.com/watch", allowlist, false},
+		{"listed name as a subdomain", "https://youtube.com.evil.example/watch", allowlist, false},
+		{"listed name in the path", "https://evil.example/youtube.com", allowlist, false},
+		{"credentials before the host", "https://youtube.com@evil.example/", allowlist, false},
+		{"malformed", "https://you tube.com/%zz", allowlist, false},
+		{"no scheme", "youtube.com/watch?v=abc", allowlist, false},
+		{"unsupported scheme", "javascript://youtube.com/alert(1)", allowlist, false},
+		{"no host", "https:///watch", allowlist, false},
+		{"empty list allows any host", "https://example.com/movie.mp4", nil, true},
+		{"empty list still needs a valid URL", "not a url", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsAllowedMediaURL(tt.url, tt.allowlist); got != tt.want {
+				t.Errorf("IsAllowedMediaURL(%q, %q) = %v, want %v", tt.url, tt.allowlist, got, tt.want)
+			}
+		})
+	}
+}
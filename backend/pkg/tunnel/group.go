@@ -0,0 +1,55 @@
+package tunnel
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs one supervised tunnel per port. Each tunnel restarts and gives
+// up independently, so one failing port never takes the others down.
+type Group struct {
+	ports       []string
+	supervisors map[string]*Supervisor
+}
+
+// NewGroup creates tunnels for ports. Only the tunnel for sharePort updates
+// holder; the others just report their URLs.
+func NewGroup(ports []string, sharePort string, maxRetries int, holder *URLHolder) *Group {
+	g := &Group{
+		ports:       ports,
+		supervisors: make(map[string]*Supervisor, len(ports)),
+	}
+	for _, port := range ports {
+		var h *URLHolder
+		if port == sharePort {
+			h = holder
+		}
+		g.supervisors[port] = NewSupervisor(port, maxRetries, h)
+	}
+	return g
+}
+
+// Run starts every tunnel and blocks until all of them have stopped
+func (g *Group) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, port := range g.ports {
+		wg.Add(1)
+		go func(s *Supervisor) {
+			defer wg.Done()
+			s.Run(ctx)
+		}(g.supervisors[port])
+	}
+	wg.Wait()
+}
+
+// URLs returns the public URL of every tunnel that is currently up, keyed
+// by local port
+func (g *Group) URLs() map[string]string {
+	urls := make(map[string]string, len(g.supervisors))
+	for port, s := range g.supervisors {
+		if url := s.URL(); url != "" {
+			urls[port] = url
+		}
+	}
+	return urls
+}
@@ -0,0 +1,144 @@
+package tunnel
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// Initial delay before restarting a failed tunnel
+	initialBackoff = time.Second
+
+	// Upper bound for the restart delay
+	maxBackoff = 30 * time.Second
+)
+
+// URLHolder stores the public base URL and is safe for concurrent use
+type URLHolder struct {
+	mu  sync.RWMutex
+	url string
+}
+
+// NewURLHolder creates a holder with an initial URL
+func NewURLHolder(url string) *URLHolder {
+	return &URLHolder{url: url}
+}
+
+// Get returns the current URL
+func (h *URLHolder) Get() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.url
+}
+
+// Set replaces the current URL
+func (h *URLHolder) Set(url string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.url = url
+}
+
+// Supervisor keeps a cloudflared tunnel running, restarting it with
+// exponential backoff whenever the process exits
+type Supervisor struct {
+	port       string
+	maxRetries int
+	holder     *URLHolder
+	fallback   string
+
+	mu  sync.RWMutex
+	url string
+}
+
+// NewSupervisor creates a supervisor for the given port. The holder, if not
+// nil, is updated with the tunnel URL while it is up and reset to its
+// initial value while it is down.
+func NewSupervisor(port string, maxRetries int, holder *URLHolder) *Supervisor {
+	s := &Supervisor{
+		port:       port,
+		maxRetries: maxRetries,
+		holder:     holder,
+	}
+	if holder != nil {
+		s.fallback = holder.Get()
+	}
+	return s
+}
+
+// URL returns the current public tunnel URL, or an empty string if the
+// tunnel is not running
+func (s *Supervisor) URL() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.url
+}
+
+func (s *Supervisor) setURL(url string) {
+	s.mu.Lock()
+	s.url = url
+	s.mu.Unlock()
+
+	if s.holder == nil {
+		return
+	}
+	if url == "" {
+		s.holder.Set(s.fallback)
+	} else {
+		s.holder.Set(url)
+	}
+}
+
+// Run starts the tunnel and restarts it until ctx is cancelled or the
+// maximum number of consecutive retries is exceeded
+func (s *Supervisor) Run(ctx context.Context) {
+	attempt := 0
+	for {
+		err := s.runOnce(ctx, &attempt)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("Tunnel stopped", "port", s.port, "error", err)
+
+		attempt++
+		if attempt > s.maxRetries {
+			slog.Warn("Tunnel failed too many times in a row, giving up", "port", s.port, "attempts", attempt)
+			return
+		}
+
+		delay := initialBackoff << (attempt - 1)
+		if delay > maxBackoff || delay <= 0 {
+			delay = maxBackoff
+		}
+		slog.Info("Restarting tunnel", "port", s.port, "delay", delay, "attempt", attempt, "max_retries", s.maxRetries)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runOnce starts a single cloudflared process and blocks until it exits.
+// attempt is reset once the tunnel comes up successfully.
+func (s *Supervisor) runOnce(ctx context.Context, attempt *int) error {
+	p, err := launch(ctx, s.port)
+	if err != nil {
+		return err
+	}
+
+	url, err := p.waitForURL(ctx)
+	if err != nil {
+		return err
+	}
+
+	*attempt = 0
+	s.setURL(url)
+	slog.Info("Tunnel started", "port", s.port, "url", url)
+
+	err = <-p.done
+	s.setURL("")
+	return err
+}
@@ -9,49 +9,85 @@ import (
 	"time"
 )
 
+var urlRegex = regexp.MustCompile(`https://[a-zA-Z0-9-]+\.trycloudflare\.com`)
+
+// process is a running cloudflared instance
+type process struct {
+	cmd  *exec.Cmd
+	url  chan string
+	done chan error // Receives the result of cmd.Wait once output is drained
+}
+
 // StartTunnel starts a cloudflared tunnel for the given port and returns the public URL
 func StartTunnel(ctx context.Context, port string) (string, error) {
+	p, err := launch(ctx, port)
+	if err != nil {
+		return "", err
+	}
+	return p.waitForURL(ctx)
+}
+
+// launch starts cloudflared and begins scanning its output for the public URL
+func launch(ctx context.Context, port string) (*process, error) {
 	cmd := exec.CommandContext(ctx, "cloudflared", "tunnel", "--url", fmt.Sprintf("http://localhost:%s", port))
 	
 	// Create pipes for stdout and stderr (cloudflared outputs url to stderr usually)
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return "", fmt.Errorf("failed to get stderr pipe: %w", err)
+		return nil, fmt.Errorf("failed to get stderr pipe: %w", err)
 	}
 
 	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("failed to start cloudflared: %w", err)
+		return nil, fmt.Errorf("failed to start cloudflared: %w", err)
 	}
 
-	// Channel to receive the URL
-	urlChan := make(chan string)
-	errChan := make(chan error)
+	p := &process{
+		cmd:  cmd,
+		url:  make(chan string, 1),
+		done: make(chan error, 1),
+	}
 
 	go func() {
 		scanner := bufio.NewScanner(stderr)
-		urlRegex := regexp.MustCompile(`https://[a-zA-Z0-9-]+\.trycloudflare\.com`)
-		
+		found := false
+
+		// Keep draining output after the URL is found so cloudflared never
+		// blocks on a full pipe
 		for scanner.Scan() {
 			line := scanner.Text()
 			// fmt.Println("[Cloudflared]", line) // Debug log
-			if url := urlRegex.FindString(line); url != "" {
-				urlChan <- url
-				return
+			if !found {
+				if url := urlRegex.FindString(line); url != "" {
+					p.url <- url
+					found = true
+				}
 			}
 		}
-		if err := scanner.Err(); err != nil {
-			errChan <- err
-		}
+		p.done <- cmd.Wait()
 	}()
 
+	return p, nil
+}
+
+// waitForURL blocks until cloudflared reports its public URL
+func (p *process) waitForURL(ctx context.Context) (string, error) {
 	select {
-	case url := <-urlChan:
+	case url := <-p.url:
 		return url, nil
-	case err := <-errChan:
-		return "", fmt.Errorf("error reading tunnel output: %w", err)
+	case err := <-p.done:
+		return "", fmt.Errorf("cloudflared exited before reporting a URL: %v", err)
 	case <-time.After(15 * time.Second):
+		p.stop()
 		return "", fmt.Errorf("timed out waiting for tunnel URL")
 	case <-ctx.Done():
 		return "", ctx.Err()
 	}
 }
+
+// stop kills the process and waits for it to exit
+func (p *process) stop() {
+	if p.cmd.Process != nil {
+		p.cmd.Process.Kill()
+	}
+	<-p.done
+}
@@ -2,49 +2,114 @@ package websocket
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gofiber/websocket/v2"
 	"github.com/google/uuid"
+	"watchparty/internal/models"
+	"watchparty/internal/services"
+	"watchparty/internal/utils"
 )
 
-const (
-	// Time allowed to write a message to the peer
-	writeWait = 10 * time.Second
-
-	// Time allowed to read the next pong message from the peer
-	pongWait = 60 * time.Second
-
-	// Send pings to peer with this period (must be less than pongWait)
-	pingPeriod = (pongWait * 9) / 10
+// ClientConn is the part of a WebSocket connection that a Client uses. It
+// is satisfied by *websocket.Conn, and lets tests run clients and the hub
+// against fake connections.
+type ClientConn interface {
+	NextReader() (messageType int, r io.Reader, err error)
+	WriteMessage(messageType int, data []byte) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	SetPongHandler(h func(appData string) error)
+	Close() error
+}
 
-	// Maximum message size allowed from peer
-	maxMessageSize = 64 * 1024 // 64KB
-)
+var _ ClientConn = (*websocket.Conn)(nil)
 
-// NewClient creates a new WebSocket client
-func NewClient(conn *websocket.Conn, hub *Hub, sessionID, userID, username string, isHost bool) *Client {
-	return &Client{
+// NewClient creates a new WebSocket client. sendBuffer is how many outgoing
+// messages may queue while the connection is slow. Once it is full, further
+// messages are dropped, and a client that keeps dropping is evicted. A larger
+// buffer rides out longer stalls, such as bursts of ICE candidates, at the
+// cost of memory per connection and of viewers acting on older messages
+// once they catch up.
+func NewClient(conn ClientConn, hub *Hub, sessionID, userID, username string, isHost bool, sendBuffer int) *Client {
+	client := &Client{
 		ID:        uuid.New().String(),
 		SessionID: sessionID,
 		UserID:    userID,
 		Username:  username,
 		IsHost:    isHost,
 		Conn:      conn,
-		Send:      make(chan []byte, 256),
+		Send:      make(chan Frame, sendBuffer),
 		hub:       hub,
+		writeDone: make(chan struct{}),
+	}
+	client.touch()
+	return client
+}
+
+// touch records that the client just sent a message
+func (c *Client) touch() {
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
+// idleFor returns how long it has been since the client last sent a message
+func (c *Client) idleFor() time.Duration {
+	return time.Since(time.Unix(0, c.lastActive.Load()))
+}
+
+// signOut sends a final event and then closes the connection with reason.
+// Unregistering closes Send, so WritePump flushes the event before the close
+// frame.
+func (c *Client) signOut(reason models.CloseReason, msgType models.MessageType, payload interface{}) {
+	c.sendEvent(msgType, payload)
+	c.closeWith(reason)
+}
+
+// closeWith unregisters the client so its connection closes with a close
+// frame carrying reason
+func (c *Client) closeWith(reason models.CloseReason) {
+	c.setCloseReason(reason)
+	c.stop()
+}
+
+// stop unregisters the client from the hub. Either pump may call it when it
+// fails; only the first call has any effect.
+func (c *Client) stop() {
+	c.stopOnce.Do(func() {
+		c.hub.Unregister(c)
+	})
+}
+
+// closeSend closes the Send channel once. Holding mu means no message can be
+// queued by sendEvent while the channel is being closed.
+func (c *Client) closeSend() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.sendClosed {
+		c.sendClosed = true
+		close(c.Send)
 	}
 }
 
 // ReadPump pumps messages from the WebSocket connection to the hub
 func (c *Client) ReadPump() {
 	defer func() {
-		c.hub.Unregister(c)
+		c.stop()
+		// Give WritePump a chance to flush queued messages and the close frame
+		select {
+		case <-c.writeDone:
+		case <-time.After(c.hub.config.WSWriteWait):
+		}
 		c.Conn.Close()
 	}()
 
-	c.Conn.SetReadLimit(maxMessageSize)
+	maxMessageSize := c.hub.config.WSMaxMessageSize
+	pongWait := c.hub.config.WSPongWait
 	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.Conn.SetPongHandler(func(string) error {
 		c.Conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -52,40 +117,71 @@ func (c *Client) ReadPump() {
 	})
 
 	for {
-		_, message, err := c.Conn.ReadMessage()
+		messageType, r, err := c.Conn.NextReader()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				slog.Warn("WebSocket error", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
 			} else {
-				log.Printf("WebSocket closed: %v", err) // Log normal closures too for debugging
+				slog.Debug("WebSocket closed", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
 			}
 			break
 		}
 
-		// Process message
-		c.handleMessage(message)
+		// Enforce the size limit ourselves rather than via SetReadLimit, so
+		// the client can be told why it is being disconnected
+		message, err := io.ReadAll(io.LimitReader(r, maxMessageSize+1))
+		if err != nil {
+			slog.Warn("WebSocket read error", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			break
+		}
+		if int64(len(message)) > maxMessageSize {
+			slog.Warn("WebSocket message too large", "session_id", c.SessionID, "user_id", c.UserID, "limit", maxMessageSize)
+			c.sendError(models.ErrorCodeMessageTooLarge, fmt.Sprintf("Messages are limited to %d bytes", maxMessageSize))
+			c.setCloseMessage(websocket.CloseMessageTooBig, "message too large")
+			break
+		}
+
+		// Pongs only prove the tab is open; activity means real messages
+		c.touch()
+
+		// Process message; only text frames carry the JSON protocol
+		c.hub.processedMessages.Add(1)
+		if messageType == websocket.BinaryMessage {
+			c.handleBinaryMessage(message)
+		} else {
+			c.handleMessage(message)
+		}
 	}
 }
 
 // WritePump pumps messages from the hub to the WebSocket connection
 func (c *Client) WritePump() {
-	ticker := time.NewTicker(pingPeriod)
+	writeWait := c.hub.config.WSWriteWait
+	ticker := time.NewTicker(c.hub.config.WSPingInterval)
 	defer func() {
 		ticker.Stop()
+		// Closing the connection unblocks ReadPump; unregistering here too
+		// covers write failures that happen while ReadPump is busy
 		c.Conn.Close()
+		close(c.writeDone)
+		c.stop()
 	}()
 
 	for {
 		select {
-		case message, ok := <-c.Send:
+		case frame, ok := <-c.Send:
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// Hub closed the channel
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.Conn.WriteMessage(websocket.CloseMessage, c.closeMessage())
 				return
 			}
 
-			if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			messageType := websocket.TextMessage
+			if frame.Kind == KindBinary {
+				messageType = websocket.BinaryMessage
+			}
+			if err := c.Conn.WriteMessage(messageType, frame.Data); err != nil {
 				return
 			}
 
@@ -98,23 +194,56 @@ func (c *Client) WritePump() {
 	}
 }
 
+// spectatorMessageTypes are the only messages a spectator may send: the
+// WebRTC signaling needed to receive the stream, and buffering reports for
+// synchronized starts
+var spectatorMessageTypes = map[string]bool{
+	"webrtc_offer":  true,
+	"webrtc_answer": true,
+	"ice_candidate": true,
+	"renegotiate":   true,
+	"ready":         true,
+}
+
 // handleMessage processes incoming messages and routes them appropriately
 func (c *Client) handleMessage(message []byte) {
 	// Parse message to determine type and routing
 	var msg struct {
-		Type     string `json:"type"`
-		TargetID string `json:"target_id,omitempty"`
+		Type     string          `json:"type"`
+		TargetID string          `json:"target_id,omitempty"`
+		Payload  json.RawMessage `json:"payload"`
 	}
 
 	if err := json.Unmarshal(message, &msg); err != nil {
-		log.Printf("Failed to parse message: %v", err)
+		slog.Warn("Failed to parse message", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+		c.sendError(models.ErrorCodeInvalidMessage, "Message is not valid JSON")
+		return
+	}
+
+	// Only relay types clients are meant to send
+	if !models.IsClientMessageType(models.MessageType(msg.Type)) {
+		slog.Warn("Dropping message of unknown type", "session_id", c.SessionID, "user_id", c.UserID, "type", msg.Type)
+		c.sendError(models.ErrorCodeUnknownType, fmt.Sprintf("Unknown message type %q", msg.Type))
+		return
+	}
+
+	if c.IsSpectator && !spectatorMessageTypes[msg.Type] {
+		c.sendError(models.ErrorCodeForbidden, "Spectators can't send messages")
+		return
+	}
+
+	if !c.hub.AllowMessage(c.SessionID, models.MessageType(msg.Type)) {
+		c.sendError(models.ErrorCodeRateLimited, "The room is busy, please slow down")
 		return
 	}
 
 	switch msg.Type {
-	case "webrtc_offer", "webrtc_answer", "ice_candidate":
+	case "webrtc_offer", "webrtc_answer", "ice_candidate", "renegotiate":
 		// Route to specific user if target specified
 		if msg.TargetID != "" {
+			if !c.validateTarget(msg.TargetID) {
+				return
+			}
 			c.hub.SendToUser(c.SessionID, msg.TargetID, message)
 		} else {
 			// Broadcast to all except sender
@@ -122,19 +251,478 @@ func (c *Client) handleMessage(message []byte) {
 		}
 
 	case "chat":
-		// Save to history
-		c.hub.SaveMessage(c.SessionID, message)
-		// Broadcast chat to everyone including sender
-		c.hub.Broadcast(c.SessionID, message, "")
+		if c.isMuted() {
+			c.sendError(models.ErrorCodeMuted, "The host has muted you")
+			return
+		}
+		var chat models.ChatPayload
+		if err := json.Unmarshal(msg.Payload, &chat); err != nil {
+			slog.Warn("Dropping invalid chat message", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			c.sendError(models.ErrorCodeInvalidMessage, "Invalid chat payload")
+			return
+		}
+		// Ciphertext length says nothing about the plaintext, so encrypted
+		// chat is only bounded by WS_MAX_MESSAGE_SIZE
+		encrypted := c.isEncryptedChat()
+		if !encrypted && utf8.RuneCountInString(chat.Message) > c.hub.config.MaxChatLength {
+			c.sendError(models.ErrorCodeMessageTooLong, fmt.Sprintf("Chat messages are limited to %d characters", c.hub.config.MaxChatLength))
+			return
+		}
+
+		// Never trust client-supplied identity or timing: stamp the sender
+		// from the authenticated connection and assign a stable ID
+		chat.ID = uuid.New().String()
+		chat.UserID = c.UserID
+		chat.Username = c.Username
+		chat.Timestamp = time.Now().UnixMilli()
+		// The envelope is ours, but an encrypted body is relayed and
+		// stored exactly as sent
+		chat.Encrypted = encrypted
+		if c.hub.config.ChatFilterEnabled && !encrypted {
+			chat.Message = utils.FilterProfanity(chat.Message)
+		}
+		// The ack ID is only meaningful to the sender
+		ackID := chat.AckID
+		chat.AckID = ""
+
+		data, err := c.withPayload(message, chat)
+		if err != nil {
+			slog.Error("Failed to build chat message", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			return
+		}
+
+		if ackID == "" {
+			// Save to history
+			c.hub.SaveMessage(c.SessionID, data)
+			// Broadcast chat to everyone including sender
+			c.hub.Broadcast(c.SessionID, data, "")
+			return
+		}
+
+		// The sender asked for confirmation, so only broadcast and ack once
+		// the message is stored. On failure nothing is sent on, letting the
+		// client retry without creating duplicates.
+		if err := c.hub.PersistMessage(c.SessionID, data); err != nil {
+			c.sendEvent(models.MessageTypeError, models.ErrorPayload{
+				Code:    models.ErrorCodeChatNotSaved,
+				Message: "Your message could not be saved, please retry",
+				AckID:   ackID,
+			})
+			return
+		}
+		c.hub.Broadcast(c.SessionID, data, "")
+		c.sendEvent(models.MessageTypeChatAck, models.ChatAckPayload{AckID: ackID, MessageID: chat.ID})
+
+	case "delete_chat":
+		var del models.DeleteChatPayload
+		if err := json.Unmarshal(msg.Payload, &del); err != nil || del.ID == "" {
+			slog.Warn("Dropping invalid delete request", "session_id", c.SessionID, "user_id", c.UserID)
+			c.sendError(models.ErrorCodeInvalidMessage, "Invalid delete request")
+			return
+		}
+		// Only the author or the host may delete a message
+		if err := c.hub.DeleteMessage(c.SessionID, del.ID, c.UserID, c.isHost()); err != nil {
+			switch {
+			case errors.Is(err, services.ErrMessageNotFound):
+				c.sendError(models.ErrorCodeInvalidMessage, "Message not found")
+			case errors.Is(err, services.ErrNotMessageAuthor):
+				slog.Warn("Rejected chat deletion", "session_id", c.SessionID, "user_id", c.UserID, "message_id", del.ID)
+				c.sendError(models.ErrorCodeForbidden, "You can only delete your own messages")
+			default:
+				slog.Error("Failed to delete chat message", "session_id", c.SessionID, "user_id", c.UserID, "message_id", del.ID, "error", err)
+				c.sendError(models.ErrorCodeInternal, "Failed to delete message")
+			}
+			return
+		}
+		del.DeletedBy = c.UserID
+
+		data, err := c.withPayload(message, del)
+		if err != nil {
+			slog.Error("Failed to build delete message", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			return
+		}
+		// Tell everyone, including the requester, to remove the message
+		c.hub.Broadcast(c.SessionID, data, "")
+
+	case "reaction":
+		var reaction models.ReactionPayload
+		if err := json.Unmarshal(msg.Payload, &reaction); err != nil || !models.IsAllowedReaction(reaction.Emoji) {
+			slog.Debug("Dropping invalid reaction", "session_id", c.SessionID, "user_id", c.UserID)
+			c.sendError(models.ErrorCodeInvalidMessage, "Unsupported reaction")
+			return
+		}
+		reaction.UserID = c.UserID
+		reaction.Username = c.Username
+
+		data, err := c.withPayload(message, reaction)
+		if err != nil {
+			slog.Error("Failed to build reaction message", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			return
+		}
+		// Broadcast to everyone including sender, but don't persist
+		c.hub.Broadcast(c.SessionID, data, "")
+
+	case "media_state":
+		var state models.MediaStatePayload
+		if err := json.Unmarshal(msg.Payload, &state); err != nil {
+			slog.Warn("Dropping invalid media state", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			c.sendError(models.ErrorCodeInvalidMessage, "Invalid media state payload")
+			return
+		}
+		// Clients may only report their own state
+		state.UserID = c.UserID
+
+		data, err := c.withPayload(message, state)
+		if err != nil {
+			slog.Error("Failed to build media state message", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			return
+		}
+		// Keep the latest state so late joiners know who is muted
+		c.hub.SaveMediaState(c.SessionID, c.UserID, data)
+		c.hub.Broadcast(c.SessionID, data, c.ID)
+
+	case "playback_control":
+		var control models.PlaybackControlPayload
+		if err := json.Unmarshal(msg.Payload, &control); err != nil || !models.IsValidPlaybackAction(control.Action) {
+			slog.Warn("Dropping invalid playback control", "session_id", c.SessionID, "user_id", c.UserID)
+			c.sendError(models.ErrorCodeInvalidMessage, "Invalid playback control action")
+			return
+		}
+		control.FromUser = c.UserID
+		control.FromUsername = c.Username
+
+		data, err := c.withPayload(message, control)
+		if err != nil {
+			slog.Error("Failed to build playback control message", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			return
+		}
+
+		if c.canControlPlayback() {
+			// Host and controller commands apply to the whole room
+			c.hub.Broadcast(c.SessionID, data, c.ID)
+		} else {
+			// Viewer controls are only a suggestion to the host
+			c.hub.SendToHost(c.SessionID, data)
+		}
+
+	case "intermission":
+		// Intermissions interrupt everyone, so only the host may call one
+		if !c.isHost() {
+			c.sendError(models.ErrorCodeForbidden, "Only the host can start an intermission")
+			return
+		}
+		var intermission models.IntermissionPayload
+		if len(msg.Payload) > 0 {
+			if err := json.Unmarshal(msg.Payload, &intermission); err != nil {
+				c.sendError(models.ErrorCodeInvalidMessage, "Invalid intermission payload")
+				return
+			}
+		}
+		if utf8.RuneCountInString(intermission.Message) > models.MaxIntermissionMessageLength {
+			c.sendError(models.ErrorCodeMessageTooLong, fmt.Sprintf("Intermission messages are limited to %d characters", models.MaxIntermissionMessageLength))
+			return
+		}
+		intermission.FromUser = c.UserID
+		intermission.FromUsername = c.Username
+		intermission.StartedAt = time.Now().UnixMilli()
+
+		data, err := c.withPayload(message, intermission)
+		if err != nil {
+			slog.Error("Failed to build intermission message", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			return
+		}
+		if err := c.hub.SetIntermission(c.SessionID, data); err != nil {
+			c.sendError(models.ErrorCodeInternal, "Failed to start intermission")
+			return
+		}
+		// Everyone, including the host, switches to the intermission view
+		c.hub.Broadcast(c.SessionID, data, "")
+
+	case "intermission_ended":
+		if !c.isHost() {
+			c.sendError(models.ErrorCodeForbidden, "Only the host can end an intermission")
+			return
+		}
+		data, err := c.withPayload(message, struct{}{})
+		if err != nil {
+			slog.Error("Failed to build intermission ended message", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			return
+		}
+		if err := c.hub.SetIntermission(c.SessionID, nil); err != nil {
+			c.sendError(models.ErrorCodeInternal, "Failed to end intermission")
+			return
+		}
+		c.hub.Broadcast(c.SessionID, data, "")
+
+	case "wait_for_all":
+		if !c.canControlPlayback() {
+			c.sendError(models.ErrorCodeForbidden, "Only the host or a controller can start a synchronized play")
+			return
+		}
+		var wait models.WaitForAllPayload
+		if err := json.Unmarshal(msg.Payload, &wait); err != nil || wait.Position < 0 {
+			c.sendError(models.ErrorCodeInvalidMessage, "Invalid wait_for_all payload")
+			return
+		}
+		wait.TimeoutMs = c.hub.config.ReadyTimeout.Milliseconds()
+		wait.FromUser = c.UserID
+		wait.FromUsername = c.Username
+
+		data, err := c.withPayload(message, wait)
+		if err != nil {
+			slog.Error("Failed to build wait_for_all message", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			return
+		}
+		// Viewers must hear about the round before its sync_play
+		c.hub.Broadcast(c.SessionID, data, c.ID)
+		c.hub.StartReadyRound(c.SessionID, c.UserID, wait.Position)
+
+	case "ready":
+		var ready models.ReadyPayload
+		if err := json.Unmarshal(msg.Payload, &ready); err != nil {
+			c.sendError(models.ErrorCodeInvalidMessage, "Invalid ready payload")
+			return
+		}
+		ready.UserID = c.UserID
+		if !c.hub.MarkReady(c.SessionID, c.UserID, ready.Position) {
+			// Nothing to wait for, or the viewer is behind an older seek
+			return
+		}
+
+		data, err := c.withPayload(message, ready)
+		if err != nil {
+			slog.Error("Failed to build ready message", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			return
+		}
+		c.hub.SendToHost(c.SessionID, data)
+		c.hub.checkReadyQuorum(c.SessionID)
+
+	case "join_response":
+		if !c.isHost() {
+			c.sendError(models.ErrorCodeForbidden, "Only the host can admit participants")
+			return
+		}
+		var resp models.JoinResponsePayload
+		if err := json.Unmarshal(msg.Payload, &resp); err != nil || resp.RequestID == "" {
+			c.sendError(models.ErrorCodeInvalidMessage, "Invalid join response")
+			return
+		}
+		if err := c.hub.ResolveJoinRequest(c.SessionID, resp.RequestID, resp.Approved); err != nil {
+			slog.Warn("Failed to resolve join request", "session_id", c.SessionID, "request_id", resp.RequestID, "error", err)
+			c.sendError(models.ErrorCodeInvalidMessage, "Join request not found or already answered")
+			return
+		}
+		slog.Info("Join request resolved", "session_id", c.SessionID, "request_id", resp.RequestID, "approved", resp.Approved)
 
 	case "playback_state":
-		// Only host can send playback state
-		if c.IsHost {
-			c.hub.Broadcast(c.SessionID, message, c.ID)
+		// Only the host and delegated controllers can send playback state
+		if !c.canControlPlayback() {
+			c.sendError(models.ErrorCodeForbidden, "Only the host or a controller can change playback state")
+			return
+		}
+		var state models.PlaybackStatePayload
+		if err := json.Unmarshal(msg.Payload, &state); err != nil {
+			slog.Warn("Dropping invalid playback state", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			c.sendError(models.ErrorCodeInvalidMessage, "Invalid playback state payload")
+			return
 		}
+		// Give viewers a common reference point for drift compensation
+		state.ServerTime = time.Now().UnixMilli()
 
-	default:
-		// Broadcast other messages
+		data, err := c.withPayload(message, state)
+		if err != nil {
+			slog.Error("Failed to build playback state message", "session_id", c.SessionID, "user_id", c.UserID, "error", err)
+			return
+		}
+		// Keep the latest state so late joiners start at the same position
+		c.hub.SavePlaybackState(c.SessionID, data)
+		c.hub.Broadcast(c.SessionID, data, c.ID)
+
+	case "typing":
 		c.hub.Broadcast(c.SessionID, message, c.ID)
 	}
 }
+
+// handleBinaryMessage relays a binary frame. Signaling frames carrying
+// routing JSON are relayed verbatim, to their target if they name one.
+// Other JSON messages are handled as text frames, so a client can't use
+// binary frames to get around the type allowlist. Frames without a JSON
+// type, e.g. data-channel fallback, go verbatim to the rest of the session.
+func (c *Client) handleBinaryMessage(message []byte) {
+	var msg struct {
+		Type     string `json:"type"`
+		TargetID string `json:"target_id,omitempty"`
+	}
+
+	if err := json.Unmarshal(message, &msg); err == nil && msg.Type != "" {
+		switch msg.Type {
+		case "webrtc_offer", "webrtc_answer", "ice_candidate", "renegotiate":
+			if msg.TargetID == "" {
+				c.hub.BroadcastBinary(c.SessionID, message, c.ID)
+			} else if c.validateTarget(msg.TargetID) {
+				c.hub.SendBinaryToUser(c.SessionID, msg.TargetID, message)
+			}
+			return
+		default:
+			// Any other typed message gets the allowlist, permission and
+			// rate checks of a text frame, and is relayed as text
+			c.handleMessage(message)
+			return
+		}
+	}
+
+	if c.IsSpectator {
+		c.sendError(models.ErrorCodeForbidden, "Spectators can't send messages")
+		return
+	}
+	if !c.hub.AllowMessage(c.SessionID, models.MessageTypeBinary) {
+		c.sendError(models.ErrorCodeRateLimited, "The room is busy, please slow down")
+		return
+	}
+	c.hub.BroadcastBinary(c.SessionID, message, c.ID)
+}
+
+// validateTarget checks that a signaling target is another connection in
+// this session, telling the sender why if not so mesh bugs aren't silent
+func (c *Client) validateTarget(targetID string) bool {
+	if targetID == c.UserID || targetID == c.ID {
+		c.sendError(models.ErrorCodeInvalidTarget, "Signaling messages can't target yourself")
+		return false
+	}
+	if !c.hub.HasTarget(c.SessionID, targetID) {
+		c.sendError(models.ErrorCodeInvalidTarget, fmt.Sprintf("User %s is not connected to this session", targetID))
+		return false
+	}
+	return true
+}
+
+// withPayload re-encodes a message with the given payload, stamping the
+// sender's session and user IDs from the authenticated client
+func (c *Client) withPayload(message []byte, payload interface{}) ([]byte, error) {
+	var msg models.WebSocketMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.Payload = data
+	msg.SessionID = c.SessionID
+	msg.UserID = c.UserID
+	msg.Timestamp = time.Now().UnixMilli()
+	return json.Marshal(msg)
+}
+
+// sendError notifies this client that one of its messages was rejected
+func (c *Client) sendError(code, message string) {
+	c.sendEvent(models.MessageTypeError, models.ErrorPayload{
+		Code:    code,
+		Message: message,
+	})
+}
+
+// sendEvent queues a server-originated message for this client only
+func (c *Client) sendEvent(msgType models.MessageType, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	msg, _ := json.Marshal(models.WebSocketMessage{
+		Type:      msgType,
+		Payload:   data,
+		SessionID: c.SessionID,
+		UserID:    c.UserID,
+		Timestamp: time.Now().UnixMilli(),
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sendClosed {
+		return
+	}
+	select {
+	case c.Send <- Frame{Data: msg}:
+	default:
+		slog.Warn("Client buffer full, dropping message", "session_id", c.SessionID, "client_id", c.ID, "type", msgType)
+	}
+}
+
+// isHost reports whether the client currently holds host privileges
+func (c *Client) isHost() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.IsHost
+}
+
+// setHost updates the client's host privileges
+func (c *Client) setHost(isHost bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.IsHost = isHost
+}
+
+// setController updates whether the client may control playback
+func (c *Client) setController(controller bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.controller = controller
+}
+
+// setEncryptedChat records whether the session's chat is end-to-end encrypted
+func (c *Client) setEncryptedChat(encrypted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.encrypted = encrypted
+}
+
+// isEncryptedChat reports whether chat bodies must be passed through untouched
+func (c *Client) isEncryptedChat() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.encrypted
+}
+
+// setMuted updates whether the client's chat messages are suppressed
+func (c *Client) setMuted(muted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.muted = muted
+}
+
+// isMuted reports whether the host has muted this client's chat
+func (c *Client) isMuted() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.muted
+}
+
+// canControlPlayback reports whether the client is the host or a delegated controller
+func (c *Client) canControlPlayback() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.IsHost || c.controller
+}
+
+// setCloseMessage sets the close frame sent when the connection shuts down
+func (c *Client) setCloseMessage(code int, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeMsg = websocket.FormatCloseMessage(code, text)
+}
+
+// setCloseReason sets a close frame with reason's code and a JSON body the
+// client reads to decide whether to reconnect
+func (c *Client) setCloseReason(reason models.CloseReason) {
+	text, _ := json.Marshal(models.CloseFramePayload{Reason: reason, Reconnect: reason.Reconnect()})
+	c.setCloseMessage(reason.Code(), string(text))
+}
+
+// closeMessage returns the close frame payload to send on shutdown
+func (c *Client) closeMessage() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closeMsg == nil {
+		return []byte{}
+	}
+	return c.closeMsg
+}
@@ -0,0 +1,463 @@
+package websocket
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+)
+
+func TestReactionWithInvalidEmojiIsDropped(t *testing.T) {
+	hub, mr := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	viewer.send(models.MessageTypeReaction, models.ReactionPayload{Emoji: "<script>"})
+
+	if got := viewer.expectError(); got.Code != models.ErrorCodeInvalidMessage {
+		t.Errorf("error code = %q, want %q", got.Code, models.ErrorCodeInvalidMessage)
+	}
+	host.expectNone(models.MessageTypeReaction, 100*time.Millisecond)
+
+	viewer.send(models.MessageTypeReaction, models.ReactionPayload{Emoji: "🎉"})
+
+	var reaction models.ReactionPayload
+	decode(t, host.expect(models.MessageTypeReaction).Payload, &reaction)
+	if reaction.Emoji != "🎉" || reaction.UserID != viewer.UserID {
+		t.Errorf("host got reaction %+v, want 🎉 from %s", reaction, viewer.UserID)
+	}
+	// Reactions go back to the sender too, but are never stored
+	viewer.expect(models.MessageTypeReaction)
+	if mr.Exists("chat:" + sessionID) {
+		t.Error("reaction was persisted to chat history")
+	}
+}
+
+func TestChatIdentityIsStampedByServer(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	viewer.send(models.MessageTypeChat, models.ChatPayload{
+		ID:        "forged-id",
+		UserID:    host.UserID,
+		Username:  host.Username,
+		Message:   "hello",
+		Timestamp: 1,
+	})
+
+	var chat models.ChatPayload
+	decode(t, host.expect(models.MessageTypeChat).Payload, &chat)
+	if chat.UserID != viewer.UserID || chat.Username != viewer.Username {
+		t.Errorf("chat from %s (%s), want %s (%s)", chat.UserID, chat.Username, viewer.UserID, viewer.Username)
+	}
+	if chat.ID == "" || chat.ID == "forged-id" {
+		t.Errorf("chat ID = %q, want a server-assigned ID", chat.ID)
+	}
+	if chat.Timestamp <= 1 {
+		t.Errorf("chat timestamp = %d, want server time", chat.Timestamp)
+	}
+	if chat.Message != "hello" {
+		t.Errorf("chat message = %q, want %q", chat.Message, "hello")
+	}
+}
+
+func TestChatProfanityFilter(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.ChatFilterEnabled = true
+	})
+	sessionID := newID()
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	viewer.send(models.MessageTypeChat, models.ChatPayload{Message: "the assassin is a bastard"})
+
+	var chat models.ChatPayload
+	decode(t, viewer.expect(models.MessageTypeChat).Payload, &chat)
+	if want := "the assassin is a *******"; chat.Message != want {
+		t.Errorf("chat message = %q, want %q", chat.Message, want)
+	}
+}
+
+func TestChatOverMaxLengthIsRejected(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.MaxChatLength = 500
+	})
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	viewer.send(models.MessageTypeChat, models.ChatPayload{Message: strings.Repeat("a", 1000)})
+
+	if got := viewer.expectError(); got.Code != models.ErrorCodeMessageTooLong {
+		t.Errorf("error code = %q, want %q", got.Code, models.ErrorCodeMessageTooLong)
+	}
+	host.expectNone(models.MessageTypeChat, 100*time.Millisecond)
+
+	// The limit counts characters, not bytes
+	atLimit := strings.Repeat("é", 500)
+	viewer.send(models.MessageTypeChat, models.ChatPayload{Message: atLimit})
+
+	var chat models.ChatPayload
+	decode(t, host.expect(models.MessageTypeChat).Payload, &chat)
+	if chat.Message != atLimit {
+		t.Errorf("chat at the limit was altered: %d characters", len([]rune(chat.Message)))
+	}
+}
+
+func TestPlaybackControlFromHostIsBroadcast(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+	other := connect(t, hub, sessionID, newID(), false)
+
+	host.send(models.MessageTypePlaybackControl, models.PlaybackControlPayload{Action: "pause", FromUser: viewer.UserID})
+
+	for _, c := range []*testClient{viewer, other} {
+		var control models.PlaybackControlPayload
+		decode(t, c.expect(models.MessageTypePlaybackControl).Payload, &control)
+		if control.Action != "pause" || control.FromUser != host.UserID || control.FromUsername != host.Username {
+			t.Errorf("%s got %+v, want pause from the host", c.UserID, control)
+		}
+	}
+	host.expectNone(models.MessageTypePlaybackControl, 100*time.Millisecond)
+}
+
+func TestPlaybackControlFromViewerGoesToHostOnly(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+	other := connect(t, hub, sessionID, newID(), false)
+
+	viewer.send(models.MessageTypePlaybackControl, models.PlaybackControlPayload{Action: "seek_forward", SeekSeconds: 10})
+
+	var control models.PlaybackControlPayload
+	decode(t, host.expect(models.MessageTypePlaybackControl).Payload, &control)
+	if control.Action != "seek_forward" || control.SeekSeconds != 10 || control.FromUser != viewer.UserID {
+		t.Errorf("host got %+v, want a seek_forward suggestion from %s", control, viewer.UserID)
+	}
+	other.expectNone(models.MessageTypePlaybackControl, 100*time.Millisecond)
+}
+
+func TestPlaybackControlWithUnknownActionIsRejected(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	host.send(models.MessageTypePlaybackControl, models.PlaybackControlPayload{Action: "rewind"})
+
+	if got := host.expectError(); got.Code != models.ErrorCodeInvalidMessage {
+		t.Errorf("error code = %q, want %q", got.Code, models.ErrorCodeInvalidMessage)
+	}
+	viewer.expectNone(models.MessageTypePlaybackControl, 100*time.Millisecond)
+}
+
+func TestOversizedFrameClosesWithReason(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.WSMaxMessageSize = 1024
+	})
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	big := viewer.message(models.MessageTypeChat, models.ChatPayload{Message: strings.Repeat("a", 2048)})
+	viewer.sendFrame(websocket.TextMessage, big)
+
+	if got := viewer.expectError(); got.Code != models.ErrorCodeMessageTooLarge {
+		t.Errorf("error code = %q, want %q", got.Code, models.ErrorCodeMessageTooLarge)
+	}
+	code, text := viewer.expectClose()
+	if code != websocket.CloseMessageTooBig || text != "message too large" {
+		t.Errorf("close frame = %d %q, want %d %q", code, text, websocket.CloseMessageTooBig, "message too large")
+	}
+	waitFor(t, "oversized sender to be removed", func() bool {
+		return !hub.HasTarget(sessionID, viewer.ID)
+	})
+	host.expectNone(models.MessageTypeChat, 50*time.Millisecond)
+}
+
+func TestFrameAtSizeLimitIsAccepted(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.WSMaxMessageSize = 1024
+	})
+	viewer := connect(t, hub, newID(), newID(), false)
+
+	msg := viewer.message(models.MessageTypeTyping, map[string]string{})
+	padded := append(msg[:len(msg)-1], []byte(`,"pad":"`+strings.Repeat("x", 1024-len(msg)-9)+`"}`)...)
+	if len(padded) != 1024 {
+		t.Fatalf("test frame is %d bytes, want 1024", len(padded))
+	}
+	viewer.sendFrame(websocket.TextMessage, padded)
+	viewer.send(models.MessageTypeChat, models.ChatPayload{Message: "still here"})
+
+	viewer.expect(models.MessageTypeChat)
+}
+
+func TestNonHostPlaybackStateGetsError(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	viewer.send(models.MessageTypePlaybackState, models.PlaybackStatePayload{Playing: true, CurrentTime: 42})
+
+	if got := viewer.expectError(); got.Code != models.ErrorCodeForbidden || got.Message == "" {
+		t.Errorf("error = %+v, want a %q error with a message", got, models.ErrorCodeForbidden)
+	}
+	host.expectNone(models.MessageTypePlaybackState, 100*time.Millisecond)
+}
+
+func TestMalformedJSONGetsError(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	viewer := connect(t, hub, newID(), newID(), false)
+
+	viewer.sendFrame(websocket.TextMessage, []byte(`{"type":"chat",`))
+
+	if got := viewer.expectError(); got.Code != models.ErrorCodeInvalidMessage {
+		t.Errorf("error code = %q, want %q", got.Code, models.ErrorCodeInvalidMessage)
+	}
+}
+
+func TestPlaybackStateIsStampedWithServerTime(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	before := time.Now().UnixMilli()
+	host.send(models.MessageTypePlaybackState, models.PlaybackStatePayload{
+		Playing:     true,
+		CurrentTime: 90.5,
+		SentAt:      1234,
+		ServerTime:  1, // a client can't choose the server's clock
+	})
+
+	var state models.PlaybackStatePayload
+	decode(t, viewer.expect(models.MessageTypePlaybackState).Payload, &state)
+	if state.ServerTime < before || state.ServerTime > time.Now().UnixMilli() {
+		t.Errorf("server time = %d, want the relay time", state.ServerTime)
+	}
+	if state.SentAt != 1234 || state.CurrentTime != 90.5 || !state.Playing {
+		t.Errorf("relayed state %+v lost the host's fields", state)
+	}
+}
+
+func TestSignalingToValidTargetIsDelivered(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+	other := connect(t, hub, sessionID, newID(), false)
+
+	viewer.sendTo(models.MessageTypeWebRTCOffer, host.UserID, map[string]string{"sdp": "v=0"})
+
+	msg := host.expect(models.MessageTypeWebRTCOffer)
+	if msg.TargetID != host.UserID {
+		t.Errorf("offer target = %q, want %q", msg.TargetID, host.UserID)
+	}
+	other.expectNone(models.MessageTypeWebRTCOffer, 100*time.Millisecond)
+	viewer.expectNone(models.MessageTypeError, 50*time.Millisecond)
+}
+
+func TestSignalingToInvalidTargetIsRejected(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+	elsewhere := connect(t, hub, newID(), newID(), false)
+
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{"unknown user", newID()},
+		{"user in another session", elsewhere.UserID},
+		{"self", viewer.UserID},
+		{"own connection", viewer.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viewer.sendTo(models.MessageTypeWebRTCAnswer, tt.target, map[string]string{"sdp": "v=0"})
+			if got := viewer.expectError(); got.Code != models.ErrorCodeInvalidTarget {
+				t.Errorf("error code = %q, want %q", got.Code, models.ErrorCodeInvalidTarget)
+			}
+		})
+	}
+	host.expectNone(models.MessageTypeWebRTCAnswer, 50*time.Millisecond)
+	elsewhere.expectNone(models.MessageTypeWebRTCAnswer, 50*time.Millisecond)
+}
+
+func TestChatAckCarriesAssignedMessageID(t *testing.T) {
+	hub, mr := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	viewer.send(models.MessageTypeChat, models.ChatPayload{Message: "hello", AckID: "local-1"})
+
+	var ack models.ChatAckPayload
+	decode(t, viewer.expect(models.MessageTypeChatAck).Payload, &ack)
+	if ack.AckID != "local-1" || ack.MessageID == "" {
+		t.Fatalf("ack = %+v, want local-1 with a message ID", ack)
+	}
+
+	var chat models.ChatPayload
+	decode(t, host.expect(models.MessageTypeChat).Payload, &chat)
+	if chat.ID != ack.MessageID {
+		t.Errorf("broadcast chat ID = %q, ack says %q", chat.ID, ack.MessageID)
+	}
+	if chat.AckID != "" {
+		t.Errorf("ack ID %q leaked to other participants", chat.AckID)
+	}
+	// The ack is only sent once the message is stored
+	history, err := mr.List("chat:" + sessionID)
+	if err != nil || len(history) != 1 || !strings.Contains(history[0], ack.MessageID) {
+		t.Errorf("chat history = %v (%v), want the acked message", history, err)
+	}
+}
+
+func TestChatAckReportsPersistenceFailure(t *testing.T) {
+	hub, mr := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+	// A value of the wrong type makes every history write fail
+	mr.Set("chat:"+sessionID, "not a list")
+
+	viewer.send(models.MessageTypeChat, models.ChatPayload{Message: "hello", AckID: "local-2"})
+
+	got := viewer.expectError()
+	if got.Code != models.ErrorCodeChatNotSaved || got.AckID != "local-2" {
+		t.Errorf("error = %+v, want %q for local-2", got, models.ErrorCodeChatNotSaved)
+	}
+	viewer.expectNone(models.MessageTypeChatAck, 50*time.Millisecond)
+	host.expectNone(models.MessageTypeChat, 50*time.Millisecond)
+}
+
+func TestIntermissionStartAndEnd(t *testing.T) {
+	hub, mr := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	host.send(models.MessageTypeIntermission, models.IntermissionPayload{Message: "Back in 5"})
+	for _, c := range []*testClient{host, viewer} {
+		var intermission models.IntermissionPayload
+		decode(t, c.expect(models.MessageTypeIntermission).Payload, &intermission)
+		if intermission.Message != "Back in 5" || intermission.FromUser != host.UserID || intermission.StartedAt == 0 {
+			t.Errorf("%s: got intermission %+v", c.UserID, intermission)
+		}
+	}
+
+	// A late joiner lands in the intermission already under way
+	late := connect(t, hub, sessionID, newID(), false)
+	var replayed models.IntermissionPayload
+	decode(t, late.expect(models.MessageTypeIntermission).Payload, &replayed)
+	if replayed.Message != "Back in 5" {
+		t.Errorf("late joiner got intermission %+v", replayed)
+	}
+
+	host.send(models.MessageTypeIntermissionEnded, nil)
+	for _, c := range []*testClient{host, viewer, late} {
+		c.expect(models.MessageTypeIntermissionEnded)
+	}
+	if mr.Exists("intermission:" + sessionID) {
+		t.Error("ended intermission is still stored")
+	}
+	afterwards := connect(t, hub, sessionID, newID(), false)
+	afterwards.expectNone(models.MessageTypeIntermission, 100*time.Millisecond)
+}
+
+func TestViewerCannotStartOrEndIntermission(t *testing.T) {
+	hub, mr := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	viewer.send(models.MessageTypeIntermission, models.IntermissionPayload{Message: "Snack break"})
+	if got := viewer.expectError(); got.Code != models.ErrorCodeForbidden {
+		t.Errorf("start: error code = %q, want %q", got.Code, models.ErrorCodeForbidden)
+	}
+	host.expectNone(models.MessageTypeIntermission, 100*time.Millisecond)
+	if mr.Exists("intermission:" + sessionID) {
+		t.Error("intermission from a viewer was stored")
+	}
+
+	host.send(models.MessageTypeIntermission, models.IntermissionPayload{Message: "Back in 5"})
+	viewer.expect(models.MessageTypeIntermission)
+	viewer.send(models.MessageTypeIntermissionEnded, nil)
+	if got := viewer.expectError(); got.Code != models.ErrorCodeForbidden {
+		t.Errorf("end: error code = %q, want %q", got.Code, models.ErrorCodeForbidden)
+	}
+	host.expectNone(models.MessageTypeIntermissionEnded, 100*time.Millisecond)
+	if !mr.Exists("intermission:" + sessionID) {
+		t.Error("intermission was cleared by a viewer")
+	}
+}
+
+func TestClientWithCustomSendBuffer(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.WSSendBuffer = 4
+		cfg.WSMaxSendDrops = 100
+	})
+	if c := NewClient(newFakeConn(), hub, newID(), newID(), "Popcorn", false, 16); cap(c.Send) != 16 {
+		t.Errorf("send buffer = %d, want 16", cap(c.Send))
+	}
+
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	stall := make(chan struct{})
+	stalled := connect(t, hub, sessionID, newID(), false, func(c *Client) {
+		c.Conn.(*fakeConn).stall = stall
+	})
+	defer close(stall)
+	if cap(stalled.Send) != 4 {
+		t.Fatalf("send buffer = %d, want WS_SEND_BUFFER", cap(stalled.Send))
+	}
+
+	// The write pump holds one message; the buffer takes four more and the
+	// rest are dropped rather than blocking the hub
+	for i := 0; i < 10; i++ {
+		host.send(models.MessageTypeChat, models.ChatPayload{Message: "spam"})
+		host.expect(models.MessageTypeChat)
+	}
+	waitFor(t, "buffer to fill", func() bool { return len(stalled.Send) == 4 })
+	if !hub.HasTarget(sessionID, stalled.ID) {
+		t.Error("client under the drop threshold was evicted")
+	}
+}
+
+func TestMessageOfUnknownTypeIsRejected(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	tests := []struct {
+		name    string
+		kind    int
+		msgType models.MessageType
+	}{
+		{"unknown type", websocket.TextMessage, "confetti"},
+		{"server-only type", websocket.TextMessage, models.MessageTypeHostChanged},
+		{"unknown type in a binary frame", websocket.BinaryMessage, "confetti"},
+	}
+	for _, tt := range tests {
+		viewer.sendFrame(tt.kind, viewer.message(tt.msgType, map[string]string{"new_host_id": viewer.UserID}))
+		if got := viewer.expectError(); got.Code != models.ErrorCodeUnknownType {
+			t.Errorf("%s: error code = %q, want %q", tt.name, got.Code, models.ErrorCodeUnknownType)
+		}
+		host.expectNone(tt.msgType, 50*time.Millisecond)
+	}
+
+	// Allowed types are still relayed
+	viewer.send(models.MessageTypeTyping, map[string]bool{"typing": true})
+	host.expect(models.MessageTypeTyping)
+}
@@ -0,0 +1,106 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"watchparty/internal/models"
+)
+
+// expectCloseReason fails the test unless the client's close frame carries
+// reason with its close code
+func (c *testClient) expectCloseReason(reason models.CloseReason) {
+	c.t.Helper()
+	code, text := c.expectClose()
+	if len(text) > 123 {
+		c.t.Errorf("%s: close frame text is %d bytes, over the 123 allowed", c.UserID, len(text))
+	}
+	var payload models.CloseFramePayload
+	if err := json.Unmarshal([]byte(text), &payload); err != nil {
+		c.t.Fatalf("%s: close frame text %q is not JSON: %v", c.UserID, text, err)
+	}
+	want := models.CloseFramePayload{Reason: reason, Reconnect: reason == models.CloseReasonShutdown}
+	if code != reason.Code() || payload != want {
+		c.t.Errorf("%s: close frame %d %+v, want %d %+v", c.UserID, code, payload, reason.Code(), want)
+	}
+}
+
+func TestCloseFrameCarriesReason(t *testing.T) {
+	tests := []struct {
+		reason models.CloseReason
+		code   int
+		close  func(hub *Hub, c *testClient)
+	}{
+		{models.CloseReasonKicked, 4001, func(hub *Hub, c *testClient) {
+			hub.DisconnectUser(c.SessionID, c.UserID)
+		}},
+		{models.CloseReasonIdle, 4002, func(hub *Hub, c *testClient) {
+			hub.SignOutUser(c.SessionID, c.UserID, models.CloseReasonIdle, models.MessageTypeIdleTimeout, models.IdleTimeoutPayload{})
+		}},
+		{models.CloseReasonSessionEnded, 4003, func(hub *Hub, c *testClient) {
+			hub.CloseSession(c.SessionID)
+		}},
+		{models.CloseReasonUnauthorized, 4004, func(hub *Hub, c *testClient) {
+			hub.SignOutUser(c.SessionID, c.UserID, models.CloseReasonUnauthorized, models.MessageTypePasswordChanged, struct{}{})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.reason), func(t *testing.T) {
+			if got := tt.reason.Code(); got != tt.code {
+				t.Fatalf("%s.Code() = %d, want %d", tt.reason, got, tt.code)
+			}
+			hub, _ := newTestHub(t, nil)
+			viewer := connect(t, hub, newID(), newID(), false)
+
+			tt.close(hub, viewer)
+			viewer.expectCloseReason(tt.reason)
+			waitFor(t, "viewer to unregister", func() bool {
+				return !hub.HasTarget(viewer.SessionID, viewer.ID)
+			})
+		})
+	}
+}
+
+func TestShutdownCloseFrameAsksClientsToReconnect(t *testing.T) {
+	if got := models.CloseReasonShutdown.Code(); got != 4000 {
+		t.Fatalf("shutdown close code = %d, want 4000", got)
+	}
+	hub, _ := newIdleHub(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	go hub.Run(ctx)
+	waitFor(t, "hub to start", hub.Running)
+	viewer := connect(t, hub, newID(), newID(), false)
+
+	cancel()
+	viewer.expectCloseReason(models.CloseReasonShutdown)
+}
+
+func TestShutdownUntracksConnections(t *testing.T) {
+	hub, _ := newIdleHub(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	go hub.Run(ctx)
+	waitFor(t, "hub to start", hub.Running)
+	sessions := []string{newID(), newID()}
+	for _, sessionID := range sessions {
+		connect(t, hub, sessionID, newID(), true)
+		connect(t, hub, sessionID, newID(), false)
+		if count, _ := hub.redis.GetConnectionCount(context.Background(), sessionID); count != 2 {
+			t.Fatalf("session %s: %d connections tracked, want 2", sessionID, count)
+		}
+	}
+
+	cancel()
+	waitCtx, done := context.WithTimeout(context.Background(), 2*time.Second)
+	defer done()
+	if err := hub.Wait(waitCtx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	// Untracked before Wait returns, while Redis is still open
+	for _, sessionID := range sessions {
+		if count, err := hub.redis.GetConnectionCount(context.Background(), sessionID); err != nil || count != 0 {
+			t.Errorf("session %s: %d connections still tracked (%v)", sessionID, count, err)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/google/uuid"
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+	"watchparty/internal/services"
+)
+
+// testTimeout bounds how long a test waits for a message it expects
+const testTimeout = 2 * time.Second
+
+var errConnClosed = errors.New("connection closed")
+
+// fakeFrame is a WebSocket frame read or written by a fakeConn
+type fakeFrame struct {
+	kind int
+	data []byte
+}
+
+// fakeConn is an in-memory ClientConn. Frames passed to deliver are
+// returned by NextReader, and frames the client writes are queued on
+// written for the test to read.
+type fakeConn struct {
+	incoming chan fakeFrame
+	written  chan fakeFrame
+	closed   chan struct{}
+	once     sync.Once
+
+	// When set, WriteMessage blocks until it is closed, like a peer that
+	// stopped reading
+	stall chan struct{}
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		incoming: make(chan fakeFrame, 64),
+		written:  make(chan fakeFrame, 1024),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (f *fakeConn) NextReader() (int, io.Reader, error) {
+	select {
+	case frame := <-f.incoming:
+		return frame.kind, bytes.NewReader(frame.data), nil
+	case <-f.closed:
+		return 0, nil, io.EOF
+	}
+}
+
+func (f *fakeConn) WriteMessage(kind int, data []byte) error {
+	if f.stall != nil {
+		select {
+		case <-f.stall:
+		case <-f.closed:
+			return errConnClosed
+		}
+	}
+	select {
+	case <-f.closed:
+		return errConnClosed
+	default:
+	}
+	select {
+	case f.written <- fakeFrame{kind: kind, data: append([]byte(nil), data...)}:
+	default:
+	}
+	return nil
+}
+
+func (f *fakeConn) SetReadDeadline(time.Time) error           { return nil }
+func (f *fakeConn) SetWriteDeadline(time.Time) error          { return nil }
+func (f *fakeConn) SetPongHandler(func(appData string) error) {}
+
+func (f *fakeConn) Close() error {
+	f.once.Do(func() { close(f.closed) })
+	return nil
+}
+
+// deliver queues a frame as if the peer had sent it
+func (f *fakeConn) deliver(kind int, data []byte) {
+	f.incoming <- fakeFrame{kind: kind, data: data}
+}
+
+// isClosed reports whether the connection has been closed
+func (f *fakeConn) isClosed() bool {
+	select {
+	case <-f.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// testConfig returns the default configuration pointed at a test Redis,
+// with the timers that would slow tests down disabled
+func testConfig(redisAddr string) *config.Config {
+	cfg := config.Load()
+	cfg.RedisURL = redisAddr
+	cfg.RedisRetries = 0
+	cfg.UserLeftDebounce = 0
+	cfg.HostOfflineGrace = 0
+	cfg.EmptySessionGrace = 0
+	cfg.PlaybackSaveDebounce = 10 * time.Millisecond
+	cfg.WSPingInterval = time.Hour
+	cfg.WSPongWait = time.Hour
+	cfg.WSWriteWait = time.Second
+	return cfg
+}
+
+// newIdleHub builds a hub backed by an in-memory Redis without starting
+// it. configure, if not nil, adjusts the configuration first.
+func newIdleHub(t *testing.T, configure func(*config.Config)) (*Hub, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	cfg := testConfig(mr.Addr())
+	if configure != nil {
+		configure(cfg)
+	}
+
+	redis, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Fatalf("NewRedisService: %v", err)
+	}
+	t.Cleanup(func() { redis.Close() })
+	return NewHub(redis, services.NewWebhookService(cfg), cfg), mr
+}
+
+// newTestHub starts a hub backed by an in-memory Redis. configure, if not
+// nil, adjusts the configuration first. The hub is stopped when the test
+// ends.
+func newTestHub(t *testing.T, configure func(*config.Config)) (*Hub, *miniredis.Miniredis) {
+	t.Helper()
+	hub, mr := newIdleHub(t, configure)
+	ctx, cancel := context.WithCancel(context.Background())
+	go hub.Run(ctx)
+	t.Cleanup(func() {
+		cancel()
+		waitCtx, done := context.WithTimeout(context.Background(), testTimeout)
+		defer done()
+		hub.Wait(waitCtx)
+	})
+	return hub, mr
+}
+
+// testClient is a Client connected to a hub through a fakeConn
+type testClient struct {
+	*Client
+	t    *testing.T
+	conn *fakeConn
+}
+
+// connect registers a client for userID and starts its pumps. setup, if
+// given, runs on the client before it registers, e.g. to make it a
+// spectator. It returns once the hub has added the client.
+func connect(t *testing.T, hub *Hub, sessionID, userID string, isHost bool, setup ...func(*Client)) *testClient {
+	t.Helper()
+	conn := newFakeConn()
+	client := NewClient(conn, hub, sessionID, userID, "user-"+userID, isHost, hub.config.WSSendBuffer)
+	for _, fn := range setup {
+		fn(client)
+	}
+	if !hub.Register(client) {
+		t.Fatal("hub refused to register client")
+	}
+	go client.WritePump()
+	go client.ReadPump()
+
+	waitFor(t, "client to register", func() bool {
+		return hub.HasTarget(sessionID, client.ID)
+	})
+	return &testClient{Client: client, t: t, conn: conn}
+}
+
+// saveSession stores a session hosted by hostID with the other users as
+// participants, for tests that need the session record in Redis
+func saveSession(t *testing.T, hub *Hub, hostID string, participants ...string) *models.Session {
+	t.Helper()
+	now := time.Now()
+	session := &models.Session{
+		ID:              newID(),
+		Name:            "Movie night",
+		HostID:          hostID,
+		Participants:    append([]string{hostID}, participants...),
+		Usernames:       map[string]string{hostID: "user-" + hostID},
+		MaxParticipants: hub.config.MaxParticipants,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(hub.config.SessionTTL),
+	}
+	for _, id := range participants {
+		session.Usernames[id] = "user-" + id
+	}
+	if err := hub.redis.SaveSession(context.Background(), session); err != nil {
+		t.Fatalf("SaveSession: %v", err)
+	}
+	return session
+}
+
+// newID returns a random ID in the format sessions and users use
+func newID() string {
+	return uuid.New().String()
+}
+
+// waitFor polls cond until it holds, failing the test after testTimeout
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// disconnect drops the client's connection, as when a tab is closed, and
+// waits for the hub to remove it
+func (c *testClient) disconnect() {
+	c.t.Helper()
+	c.conn.Close()
+	waitFor(c.t, "client to unregister", func() bool {
+		return !c.hub.HasTarget(c.SessionID, c.ID)
+	})
+}
+
+// send delivers a JSON message from the client as a text frame
+func (c *testClient) send(msgType models.MessageType, payload interface{}) {
+	c.t.Helper()
+	c.sendFrame(websocket.TextMessage, c.message(msgType, payload))
+}
+
+// sendTo delivers a JSON message addressed to targetID
+func (c *testClient) sendTo(msgType models.MessageType, targetID string, payload interface{}) {
+	c.t.Helper()
+	msg := models.WebSocketMessage{Type: msgType, TargetID: targetID, Payload: mustMarshal(c.t, payload)}
+	c.sendFrame(websocket.TextMessage, mustMarshal(c.t, msg))
+}
+
+// message encodes a message of msgType carrying payload
+func (c *testClient) message(msgType models.MessageType, payload interface{}) []byte {
+	c.t.Helper()
+	return mustMarshal(c.t, models.WebSocketMessage{Type: msgType, Payload: mustMarshal(c.t, payload)})
+}
+
+// sendFrame delivers a raw frame from the client
+func (c *testClient) sendFrame(kind int, data []byte) {
+	c.conn.deliver(kind, data)
+}
+
+// next returns the next data frame written to the client, skipping pings
+func (c *testClient) next(timeout time.Duration) (fakeFrame, bool) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case frame := <-c.conn.written:
+			if frame.kind == websocket.PingMessage {
+				continue
+			}
+			return frame, true
+		case <-deadline:
+			return fakeFrame{}, false
+		}
+	}
+}
+
+// expect returns the next message of msgType sent to the client, skipping
+// any others, and fails the test if none arrives
+func (c *testClient) expect(msgType models.MessageType) models.WebSocketMessage {
+	c.t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		frame, ok := c.next(time.Until(deadline))
+		if !ok {
+			c.t.Fatalf("%s: no %s message received", c.UserID, msgType)
+		}
+		if frame.kind != websocket.TextMessage {
+			continue
+		}
+		var msg models.WebSocketMessage
+		if err := json.Unmarshal(frame.data, &msg); err != nil {
+			c.t.Fatalf("%s: invalid message %s: %v", c.UserID, frame.data, err)
+		}
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+// expectError returns the payload of the next error sent to the client
+func (c *testClient) expectError() models.ErrorPayload {
+	c.t.Helper()
+	var payload models.ErrorPayload
+	decode(c.t, c.expect(models.MessageTypeError).Payload, &payload)
+	return payload
+}
+
+// expectNone fails the test if a message of msgType reaches the client
+// within wait
+func (c *testClient) expectNone(msgType models.MessageType, wait time.Duration) {
+	c.t.Helper()
+	deadline := time.Now().Add(wait)
+	for {
+		frame, ok := c.next(time.Until(deadline))
+		if !ok {
+			return
+		}
+		var msg models.WebSocketMessage
+		if frame.kind == websocket.TextMessage && json.Unmarshal(frame.data, &msg) == nil && msg.Type == msgType {
+			c.t.Fatalf("%s: unexpected %s message: %s", c.UserID, msgType, frame.data)
+		}
+	}
+}
+
+// expectClose returns the code and text of the close frame sent to the
+// client, skipping any messages before it
+func (c *testClient) expectClose() (int, string) {
+	c.t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		frame, ok := c.next(time.Until(deadline))
+		if !ok {
+			c.t.Fatalf("%s: no close frame received", c.UserID)
+		}
+		if frame.kind != websocket.CloseMessage {
+			continue
+		}
+		if len(frame.data) < 2 {
+			return websocket.CloseNoStatusReceived, ""
+		}
+		return int(binary.BigEndian.Uint16(frame.data)), string(frame.data[2:])
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return data
+}
+
+func decode(t *testing.T, data []byte, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+}
@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"watchparty/internal/models"
+)
+
+// scheduleHostHandoffLocked holds the host role for HostOfflineGrace after
+// the host's last connection drops, e.g. while a phone's screen is locked,
+// and then passes it to a connected participant. The caller must hold h.mu.
+func (h *Hub) scheduleHostHandoffLocked(client *Client) {
+	if h.config.HostOfflineGrace <= 0 {
+		return
+	}
+
+	key := leaveKey(client.SessionID, client.UserID)
+	if timer, ok := h.hostTimers[key]; ok {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(h.config.HostOfflineGrace, func() {
+		h.mu.Lock()
+		// A reconnect may have cancelled this timer after it fired
+		if h.hostTimers[key] != timer {
+			h.mu.Unlock()
+			return
+		}
+		delete(h.hostTimers, key)
+		back := h.hasUserLocked(client.SessionID, client.UserID)
+		h.mu.Unlock()
+
+		// SetHost takes h.mu, so the hand-off runs without it
+		if !back {
+			h.handOffHost(client.SessionID, client.UserID)
+		}
+	})
+	h.hostTimers[key] = timer
+}
+
+// cancelHostHandoffLocked stops a pending hand-off when the offline host
+// reconnects. The caller must hold h.mu.
+func (h *Hub) cancelHostHandoffLocked(client *Client) {
+	key := leaveKey(client.SessionID, client.UserID)
+	if timer, ok := h.hostTimers[key]; ok {
+		timer.Stop()
+		delete(h.hostTimers, key)
+		slog.Debug("Host reconnected within grace window", "session_id", client.SessionID, "user_id", client.UserID)
+	}
+}
+
+// handOffHost passes host privileges from an offline host to a connected
+// participant and tells the session
+func (h *Hub) handOffHost(sessionID, hostID string) {
+	ctx := context.Background()
+
+	// The host may have reconnected to another server instance
+	online, err := h.redis.GetConnectedUsers(ctx, sessionID)
+	if err != nil {
+		slog.Error("Failed to check connected users", "session_id", sessionID, "error", err)
+		return
+	}
+	if online[hostID] {
+		return
+	}
+
+	newHostID, err := h.redis.HandOffHost(ctx, sessionID, hostID, online)
+	if err != nil {
+		slog.Warn("Failed to hand off host", "session_id", sessionID, "user_id", hostID, "error", err)
+		return
+	}
+	if newHostID == "" {
+		return
+	}
+
+	audit := &models.AuditEntry{Event: models.AuditEventHostTransfer, Actor: "system", Detail: newHostID}
+	if err := h.redis.AppendAudit(ctx, sessionID, audit); err != nil {
+		slog.Warn("Failed to write audit entry", "session_id", sessionID, "error", err)
+	}
+	slog.Info("Host offline, role handed off", "session_id", sessionID, "from", hostID, "to", newHostID)
+	h.SetHost(sessionID, newHostID)
+}
@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+)
+
+func TestHostReconnectingWithinGraceKeepsRole(t *testing.T) {
+	const grace = 200 * time.Millisecond
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.HostOfflineGrace = grace
+	})
+	hostID, viewerID := newID(), newID()
+	session := saveSession(t, hub, hostID, viewerID)
+	host := connect(t, hub, session.ID, hostID, true)
+	viewer := connect(t, hub, session.ID, viewerID, false)
+
+	host.disconnect()
+	time.Sleep(grace / 4)
+	host = connect(t, hub, session.ID, hostID, true)
+
+	viewer.expectNone(models.MessageTypeHostChanged, 2*grace)
+	if stored, _ := hub.redis.GetSession(context.Background(), session.ID); stored.HostID != hostID {
+		t.Errorf("host = %s, want %s to keep the role", stored.HostID, hostID)
+	}
+	if !host.isHost() || viewer.isHost() {
+		t.Error("host privileges moved although the host came back in time")
+	}
+}
+
+func TestHostOfflinePastGraceIsReplaced(t *testing.T) {
+	const grace = 100 * time.Millisecond
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.HostOfflineGrace = grace
+	})
+	hostID, viewerID := newID(), newID()
+	session := saveSession(t, hub, hostID, viewerID)
+	host := connect(t, hub, session.ID, hostID, true)
+	viewer := connect(t, hub, session.ID, viewerID, false)
+
+	start := time.Now()
+	host.disconnect()
+	// Still the host during the grace window
+	viewer.expectNone(models.MessageTypeHostChanged, grace/2)
+
+	var changed models.HostChangedPayload
+	decode(t, viewer.expect(models.MessageTypeHostChanged).Payload, &changed)
+	if elapsed := time.Since(start); elapsed < grace {
+		t.Errorf("host replaced after %v, before the %v grace period", elapsed, grace)
+	}
+	if changed.HostID != viewerID {
+		t.Errorf("new host = %s, want the remaining viewer %s", changed.HostID, viewerID)
+	}
+	if stored, _ := hub.redis.GetSession(context.Background(), session.ID); stored.HostID != viewerID {
+		t.Errorf("stored host = %s, want %s", stored.HostID, viewerID)
+	}
+	if !viewer.isHost() {
+		t.Error("viewer's connection wasn't given host privileges")
+	}
+}
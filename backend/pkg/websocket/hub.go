@@ -2,26 +2,45 @@ package websocket
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
     "context"
 
-	"github.com/gofiber/websocket/v2"
+	"watchparty/internal/config"
+	"watchparty/internal/models"
     "watchparty/internal/services"
 )
 
 // Client represents a connected WebSocket client
 type Client struct {
-	ID        string
-	SessionID string
-	UserID    string
-	Username  string
-	IsHost    bool
-	Conn      *websocket.Conn
-	Send      chan []byte
-	hub       *Hub
-	mu        sync.Mutex
+	ID              string
+	SessionID       string
+	UserID          string
+	Username        string
+	IsHost          bool
+	ProtocolVersion int  // Message format version negotiated on upgrade
+	IsSpectator     bool // Read-only viewer outside the participant list
+	controller      bool // Host has delegated playback control to this user
+	muted           bool // Host has muted this user's chat
+	encrypted       bool // Session uses end-to-end encrypted chat
+	Conn            ClientConn
+	Send            chan Frame
+	hub             *Hub
+	mu              sync.Mutex
+	closeMsg        []byte        // Close frame payload sent when the connection ends
+	writeDone       chan struct{} // Closed when WritePump exits
+	sendClosed      bool          // Send has been closed; guarded by mu
+	lastActive      atomic.Int64  // Unix nanoseconds of the last message received, pongs excluded
+	stopOnce        sync.Once     // Ensures the client is unregistered exactly once
+	replay          [][]byte      // State loaded by Register, queued when the hub adds the client
+
+	// Backpressure accounting for messages dropped because Send was full
+	droppedMessages  atomic.Int64
+	consecutiveDrops atomic.Int64
+	evicted          atomic.Bool
 }
 
 // Hub maintains the set of active clients and broadcasts messages
@@ -41,14 +60,78 @@ type Hub struct {
 	// Direct messages to a specific client
 	direct chan *DirectMessage
 
-	mu    sync.RWMutex
-    redis *services.RedisService
+	// Closed once Run returns, so late Register and Unregister calls don't
+	// block forever
+	done chan struct{}
+
+	// Clients closed as Run stopped, whose close frames Wait lets flush.
+	// Written before done is closed and only read after.
+	closed []*Client
+
+	// Set while Run's loop is serving, for readiness checks
+	running atomic.Bool
+
+	// Read-only feeds of session broadcasts, e.g. SSE viewers
+	subscribers map[string]map[*Subscription]struct{}
+
+	// Pending close timers for sessions whose last client disconnected
+	emptyTimers map[string]*time.Timer
+
+	// Pending user_left announcements keyed by session and user ID
+	leaveTimers map[string]*time.Timer
+
+	// Pending host hand-offs keyed by session and the offline host's user ID
+	hostTimers map[string]*time.Timer
+
+	// Last broadcast sequence number per session
+	seq   map[string]int64
+	seqMu sync.Mutex
+
+	// Hub-wide backpressure counters
+	droppedMessages   atomic.Int64
+	evictedClients    atomic.Int64
+	throttledMessages atomic.Int64
+	processedMessages atomic.Int64 // Messages received from clients since start
+	broadcastStats    queueStats
+	directStats       queueStats
+
+	// Per-session low-priority message budget for the current second
+	rates  map[string]*sessionRate
+	rateMu sync.Mutex
+
+	// Pending synchronized starts waiting for viewers to buffer
+	readyRounds map[string]*readyRound
+	readyMu     sync.Mutex
+
+	// Latest playback states not yet written to Redis
+	playbackWrites map[string]*playbackWrite
+	playbackMu     sync.Mutex
+
+	mu       sync.RWMutex
+	redis    *services.RedisService
+	webhooks *services.WebhookService
+	config   *config.Config
+}
+
+// MessageKind is the WebSocket frame type a message is relayed as
+type MessageKind int
+
+const (
+	KindText MessageKind = iota
+	KindBinary
+)
+
+// Frame is a message queued for a client's WritePump
+type Frame struct {
+	Kind MessageKind
+	Data []byte
 }
 
 // BroadcastMessage represents a message to broadcast to a session
 type BroadcastMessage struct {
 	SessionID string
 	Message   []byte
+	Kind      MessageKind
 	ExcludeID string // Optional: exclude this client ID from broadcast
 }
 
@@ -57,24 +140,46 @@ type DirectMessage struct {
 	SessionID string
 	TargetID  string
 	Message   []byte
+	Kind      MessageKind
 }
 
 // NewHub creates a new Hub instance
-func NewHub(redis *services.RedisService) *Hub {
+func NewHub(redis *services.RedisService, webhooks *services.WebhookService, cfg *config.Config) *Hub {
 	return &Hub{
 		sessions:   make(map[string]map[string]*Client),
 		register:   make(chan *Client),
 		unregister:   make(chan *Client),
-		broadcast:  make(chan *BroadcastMessage, 256),
-		direct:     make(chan *DirectMessage, 256),
+		broadcast:  make(chan *BroadcastMessage, cfg.HubBroadcastBuffer),
+		direct:     make(chan *DirectMessage, cfg.HubDirectBuffer),
+		done:       make(chan struct{}),
+		subscribers: make(map[string]map[*Subscription]struct{}),
+		emptyTimers: make(map[string]*time.Timer),
+		leaveTimers: make(map[string]*time.Timer),
+		hostTimers:  make(map[string]*time.Timer),
+		rates:       make(map[string]*sessionRate),
+		readyRounds: make(map[string]*readyRound),
+		playbackWrites: make(map[string]*playbackWrite),
+		seq:         make(map[string]int64),
         redis:      redis,
+		webhooks:   webhooks,
+		config:     cfg,
 	}
 }
 
-// Run starts the hub's main loop
-func (h *Hub) Run() {
+// Run starts the hub's main loop and returns when ctx is cancelled
+func (h *Hub) Run(ctx context.Context) {
+	h.running.Store(true)
+	defer close(h.done)
+	defer h.running.Store(false)
+
 	for {
 		select {
+		case <-ctx.Done():
+			h.closed = h.closeAll(models.CloseReasonShutdown)
+			h.untrackAll(h.closed)
+			slog.Info("Hub stopped")
+			return
+
 		case client := <-h.register:
 			h.registerClient(client)
 
@@ -90,6 +195,114 @@ func (h *Hub) Run() {
 	}
 }
 
+// closeAll tells every connected client why it is being closed and returns
+// them. It runs as the hub loop exits, so Send is closed here rather than
+// through Unregister, and no departures are announced: the viewers are
+// expected to reconnect, possibly to another instance. Subscriptions end
+// too, so SSE streams don't hold the server open.
+func (h *Hub) closeAll(reason models.CloseReason) []*Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sessionID := range h.subscribers {
+		h.endSubscriptionsLocked(sessionID)
+	}
+
+	var clients []*Client
+	for _, session := range h.sessions {
+		for _, client := range session {
+			client.setCloseReason(reason)
+			client.closeSend()
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// untrackAll removes closed clients' connections from Redis in one round
+// trip. Run calls it after closeAll has released h.mu and before Wait
+// returns, so Redis is still open.
+func (h *Hub) untrackAll(clients []*Client) {
+	conns := make([]services.Connection, 0, len(clients))
+	for _, client := range clients {
+		conns = append(conns, services.Connection{SessionID: client.SessionID, UserID: client.UserID, ID: client.ID})
+	}
+	if err := h.redis.RemoveConnections(context.Background(), conns); err != nil {
+		slog.Error("Failed to untrack connections", "connections", len(conns), "error", err)
+	}
+}
+
+// Wait blocks until Run has returned and the clients it closed have written
+// their close frames, or until ctx is done. Shutdown calls it before closing
+// Redis, which closing the clients still uses.
+func (h *Hub) Wait(ctx context.Context) error {
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for _, client := range h.closed {
+		select {
+		case <-client.writeDone:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Running reports whether the hub's main loop is serving
+func (h *Hub) Running() bool {
+	return h.running.Load()
+}
+
+// Done is closed once Run has returned
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
+// loadClientState reads what a connecting client needs from Redis: its
+// current roles and the messages that bring it up to date. Register calls
+// it from the client's goroutine so the hub loop never waits on Redis.
+func (h *Hub) loadClientState(client *Client) {
+	ctx := context.Background()
+
+	// The session record is authoritative for host privileges, since the
+	// host may have changed since the client's token was issued
+	if session, err := h.redis.GetSession(ctx, client.SessionID); err == nil && session != nil {
+		client.setHost(session.HostID == client.UserID)
+		client.setController(session.IsController(client.UserID))
+		client.setEncryptedChat(session.EncryptedChat)
+	}
+
+	// Mutes persist across reconnects
+	if muted, err := h.redis.IsUserMuted(ctx, client.SessionID, client.UserID); err == nil {
+		client.setMuted(muted)
+	}
+
+	// Chat history, if any is kept
+	if h.config.ChatPersistence {
+		if history, err := h.redis.GetChatHistory(ctx, client.SessionID); err == nil {
+			client.replay = append(client.replay, history...)
+		}
+	}
+
+	// Current media states of other users
+	if states, err := h.redis.GetMediaStates(ctx, client.SessionID); err == nil {
+		client.replay = append(client.replay, states...)
+	}
+
+	// Late joiners start where the host is, e.g. at the paused frame
+	if playback := h.playbackState(client.SessionID); playback != nil {
+		client.replay = append(client.replay, playback)
+	}
+
+	// Late joiners should see an intermission that is already under way
+	if intermission, err := h.redis.GetIntermission(ctx, client.SessionID); err == nil && intermission != nil {
+		client.replay = append(client.replay, intermission)
+	}
+}
+
 func (h *Hub) registerClient(client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -99,32 +312,192 @@ func (h *Hub) registerClient(client *Client) {
 		h.sessions[client.SessionID] = make(map[string]*Client)
 	}
 
+	// Someone came back, so the session is no longer idle
+	if timer, ok := h.emptyTimers[client.SessionID]; ok {
+		timer.Stop()
+		delete(h.emptyTimers, client.SessionID)
+	}
+
 	h.sessions[client.SessionID][client.ID] = client
-	log.Printf("Client %s registered to session %s", client.ID, client.SessionID)
-
-    // Send chat history
-    if history, err := h.redis.GetChatHistory(context.Background(), client.SessionID); err == nil {
-        for _, msg := range history {
-            // Send directly to client channel
-            select {
-            case client.Send <- msg:
-            default:
-            }
-        }
-    }
+	slog.Info("Client registered", "session_id", client.SessionID, "user_id", client.UserID, "client_id", client.ID)
+
+	// Queue the state loaded by Register ahead of any later broadcast
+	for _, msg := range client.replay {
+		select {
+		case client.Send <- Frame{Data: msg}:
+		default:
+		}
+	}
+	client.replay = nil
+
+	// A host back within the grace window keeps the role
+	h.cancelHostHandoffLocked(client)
+
+	// A quick reconnect cancels the pending leave, and neither event is sent
+	key := leaveKey(client.SessionID, client.UserID)
+	if timer, ok := h.leaveTimers[key]; ok {
+		timer.Stop()
+		delete(h.leaveTimers, key)
+		slog.Debug("Client reconnected within debounce window", "session_id", client.SessionID, "user_id", client.UserID)
+		return
+	}
+
+	// Spectators come and go without appearing in the participant list
+	if client.IsSpectator {
+		return
+	}
 
 	// Notify other clients about new user
 	h.notifyUserJoined(client)
 }
 
+// hasUserLocked reports whether a user still has a connection in a session.
+// The caller must hold h.mu.
+func (h *Hub) hasUserLocked(sessionID, userID string) bool {
+	for _, c := range h.sessions[sessionID] {
+		if c.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTarget reports whether targetID names a connected user or client in
+// the session, matching how direct messages are routed
+func (h *Hub) HasTarget(sessionID, targetID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, c := range h.sessions[sessionID] {
+		if c.UserID == targetID || c.ID == targetID {
+			return true
+		}
+	}
+	return false
+}
+
+// ReserveConnection claims a slot for client under the per-session and
+// per-user connection limits, or returns services.ErrSessionConnectionLimit
+// or services.ErrUserConnectionLimit. Counts live in Redis so the limits
+// hold across server instances; if Redis can't be reached the connection
+// is allowed. A client that is never registered must be released with
+// ReleaseConnection.
+func (h *Hub) ReserveConnection(client *Client) error {
+	err := h.redis.ReserveConnection(context.Background(), client.SessionID, client.UserID, client.ID,
+		h.config.WSMaxConnectionsPerSession, h.config.WSMaxConnectionsPerUser)
+	if errors.Is(err, services.ErrSessionConnectionLimit) || errors.Is(err, services.ErrUserConnectionLimit) {
+		return err
+	}
+	if err != nil {
+		slog.Warn("Failed to reserve connection, allowing it", "session_id", client.SessionID, "user_id", client.UserID, "error", err)
+	}
+	return nil
+}
+
+// ReleaseConnection gives back the slot of a client that was reserved but
+// never registered
+func (h *Hub) ReleaseConnection(client *Client) {
+	if err := h.redis.RemoveConnection(context.Background(), client.SessionID, client.UserID, client.ID); err != nil {
+		slog.Error("Failed to release connection", "session_id", client.SessionID, "user_id", client.UserID, "error", err)
+	}
+}
+
+// trackConnection records a client connecting or disconnecting in Redis
+func (h *Hub) trackConnection(client *Client, connected bool) {
+	ctx := context.Background()
+	var err error
+	if connected {
+		err = h.redis.AddConnection(ctx, client.SessionID, client.UserID, client.ID)
+	} else {
+		err = h.redis.RemoveConnection(ctx, client.SessionID, client.UserID, client.ID)
+	}
+	if err != nil {
+		slog.Error("Failed to track connection", "session_id", client.SessionID, "user_id", client.UserID, "error", err)
+	}
+	if err := h.redis.TouchPresence(ctx, client.SessionID, client.UserID); err != nil {
+		slog.Error("Failed to update presence", "session_id", client.SessionID, "user_id", client.UserID, "error", err)
+	}
+}
+
+// TokenRevoked reports whether a token issued at issuedAt was revoked by a
+// password change. Lookup failures are not treated as revocation.
+func (h *Hub) TokenRevoked(sessionID string, issuedAt time.Time) bool {
+	session, err := h.redis.GetSession(context.Background(), sessionID)
+	if err != nil || session == nil {
+		return false
+	}
+	return session.TokenRevoked(issuedAt)
+}
+
 // SaveMessage stores a message in Redis
 func (h *Hub) SaveMessage(sessionID string, message []byte) {
+	if !h.config.ChatPersistence {
+		return
+	}
     // Fire and forget, don't block
     go func() {
-        h.redis.SaveChatMessage(context.Background(), sessionID, message)
+        h.PersistMessage(sessionID, message)
     }()
 }
 
+// PersistMessage stores a chat message in the history and waits for the
+// result, for callers that must not report success before it is saved.
+// With CHAT_PERSISTENCE_ENABLED=false nothing is written and it succeeds.
+func (h *Hub) PersistMessage(sessionID string, message []byte) error {
+	if !h.config.ChatPersistence {
+		return nil
+	}
+	if err := h.redis.SaveChatMessage(context.Background(), sessionID, message); err != nil {
+		slog.Error("Failed to save chat message", "session_id", sessionID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// SaveMediaState stores a user's latest media state in Redis
+func (h *Hub) SaveMediaState(sessionID, userID string, message []byte) {
+	// Fire and forget, don't block
+	go func() {
+		if err := h.redis.SaveMediaState(context.Background(), sessionID, userID, message); err != nil {
+			slog.Error("Failed to save media state", "session_id", sessionID, "user_id", userID, "error", err)
+		}
+	}()
+}
+
+// SetIntermission records the start or end of a session's intermission.
+// A nil message ends it. This runs synchronously so that a quick start and
+// end from the host can't be stored out of order.
+func (h *Hub) SetIntermission(sessionID string, message []byte) error {
+	ctx := context.Background()
+	var err error
+	if message == nil {
+		err = h.redis.ClearIntermission(ctx, sessionID)
+	} else {
+		err = h.redis.SetIntermission(ctx, sessionID, message)
+	}
+	if err != nil {
+		slog.Error("Failed to save intermission", "session_id", sessionID, "error", err)
+	}
+	return err
+}
+
+// DeleteMessage removes a chat message from history if the requester is
+// its author or the session host
+func (h *Hub) DeleteMessage(sessionID, messageID, userID string, isHost bool) error {
+	ctx := context.Background()
+	payload, err := h.redis.GetChatMessage(ctx, sessionID, messageID)
+	if err != nil {
+		return err
+	}
+	if payload == nil {
+		return services.ErrMessageNotFound
+	}
+	if !isHost && payload.UserID != userID {
+		return services.ErrNotMessageAuthor
+	}
+	return h.redis.DeleteChatMessage(ctx, sessionID, messageID)
+}
+
 func (h *Hub) unregisterClient(client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -132,40 +505,188 @@ func (h *Hub) unregisterClient(client *Client) {
 	if session, ok := h.sessions[client.SessionID]; ok {
 		if _, ok := session[client.ID]; ok {
 			delete(session, client.ID)
-			close(client.Send)
+			client.closeSend()
+			// Redis is updated off the hub loop, like registration
+			go h.trackConnection(client, false)
 
-			// Remove session if empty
+			// Remove session if empty and schedule it to close
 			if len(session) == 0 {
 				delete(h.sessions, client.SessionID)
+				h.scheduleEmptyCheckLocked(client.SessionID)
+			}
+
+			slog.Info("Client unregistered", "session_id", client.SessionID, "user_id", client.UserID, "client_id", client.ID)
+
+			// Announce the departure once the user's last connection is gone
+			if !client.IsSpectator && !h.hasUserLocked(client.SessionID, client.UserID) {
+				h.scheduleUserLeftLocked(client)
+				if client.isHost() {
+					h.scheduleHostHandoffLocked(client)
+				}
+				// A departed viewer shouldn't hold up a synchronized start.
+				// The check takes h.mu, so it can't run while we hold it.
+				go h.checkReadyQuorum(client.SessionID)
 			}
+		}
+	}
+}
+
+func leaveKey(sessionID, userID string) string {
+	return sessionID + ":" + userID
+}
+
+// scheduleUserLeftLocked delays the user_left announcement so a user who
+// drops and reconnects within the debounce window doesn't spam the room.
+// The caller must hold h.mu.
+func (h *Hub) scheduleUserLeftLocked(client *Client) {
+	if h.config.UserLeftDebounce <= 0 {
+		h.userLeftLocked(client)
+		return
+	}
+
+	key := leaveKey(client.SessionID, client.UserID)
+	if timer, ok := h.leaveTimers[key]; ok {
+		timer.Stop()
+	}
 
-			log.Printf("Client %s unregistered from session %s", client.ID, client.SessionID)
+	var timer *time.Timer
+	timer = time.AfterFunc(h.config.UserLeftDebounce, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
 
-			// Notify other clients about user leaving
-			h.notifyUserLeft(client)
+		// A reconnect may have cancelled this timer after it fired
+		if h.leaveTimers[key] != timer {
+			return
 		}
+		delete(h.leaveTimers, key)
+		if !h.hasUserLocked(client.SessionID, client.UserID) {
+			h.userLeftLocked(client)
+		}
+	})
+	h.leaveTimers[key] = timer
+}
+
+// userLeftLocked forgets a departed user's media state and tells the rest of
+// the session. The caller must hold h.mu, so Redis is left to a goroutine.
+func (h *Hub) userLeftLocked(client *Client) {
+	go h.clearMediaState(client.SessionID, client.UserID)
+	h.notifyUserLeft(client)
+}
+
+// clearMediaState forgets a user's media state, logging failures
+func (h *Hub) clearMediaState(sessionID, userID string) {
+	if err := h.redis.ClearMediaState(context.Background(), sessionID, userID); err != nil {
+		slog.Error("Failed to clear media state", "session_id", sessionID, "user_id", userID, "error", err)
+	}
+}
+
+// scheduleEmptyCheckLocked arranges for a session to be closed if it is
+// still empty after the configured grace period. The caller must hold h.mu.
+func (h *Hub) scheduleEmptyCheckLocked(sessionID string) {
+	if h.config.EmptySessionGrace <= 0 {
+		return
+	}
+	if timer, ok := h.emptyTimers[sessionID]; ok {
+		timer.Stop()
+	}
+	h.emptyTimers[sessionID] = time.AfterFunc(h.config.EmptySessionGrace, func() {
+		h.closeIfEmpty(sessionID)
+	})
+}
+
+// closeIfEmpty deletes a session that has had no clients for the grace period
+func (h *Hub) closeIfEmpty(sessionID string) {
+	h.mu.Lock()
+	delete(h.emptyTimers, sessionID)
+	_, active := h.sessions[sessionID]
+	h.mu.Unlock()
+
+	if active {
+		return
+	}
+
+	ctx := context.Background()
+	// Clients may be connected to another server instance
+	if count, err := h.redis.GetConnectionCount(ctx, sessionID); err != nil || count > 0 {
+		return
+	}
+
+	if err := h.redis.DeleteSession(ctx, sessionID); err != nil {
+		slog.Error("Failed to close idle session", "session_id", sessionID, "error", err)
+		return
+	}
+	audit := &models.AuditEntry{Event: models.AuditEventTerminate, Actor: "system", Detail: "idle"}
+	if err := h.redis.AppendAudit(ctx, sessionID, audit); err != nil {
+		slog.Warn("Failed to write audit entry", "session_id", sessionID, "error", err)
+	}
+	if err := h.redis.ClearChatHistory(ctx, sessionID); err != nil {
+		slog.Error("Failed to clear chat history", "session_id", sessionID, "error", err)
 	}
+	if err := h.redis.MarkSessionExpired(ctx, sessionID); err != nil {
+		slog.Error("Failed to mark session expired", "session_id", sessionID, "error", err)
+	}
+	h.webhooks.Notify(models.WebhookSessionEnded, sessionID, &models.WebhookSessionData{Reason: "idle"})
+	h.dropPlaybackState(sessionID)
+
+	h.mu.Lock()
+	h.endSubscriptionsLocked(sessionID)
+	h.mu.Unlock()
+	h.seqMu.Lock()
+	delete(h.seq, sessionID)
+	h.seqMu.Unlock()
+	h.rateMu.Lock()
+	delete(h.rates, sessionID)
+	h.rateMu.Unlock()
+	h.cancelReadyRound(sessionID)
+	slog.Info("Closed idle session", "session_id", sessionID)
 }
 
 func (h *Hub) broadcastToSession(msg *BroadcastMessage) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	if session, ok := h.sessions[msg.SessionID]; ok {
-		for id, client := range session {
-			if msg.ExcludeID != "" && id == msg.ExcludeID {
-				continue
-			}
-			select {
-			case client.Send <- msg.Message:
-			default:
-				// Client buffer full, skip
-				log.Printf("Client %s buffer full, skipping message", id)
-			}
+	session, ok := h.sessions[msg.SessionID]
+	_, subscribed := h.subscribers[msg.SessionID]
+	if !ok && !subscribed {
+		return
+	}
+
+	// Binary frames are relayed verbatim, so only JSON text gets a sequence number
+	frame := Frame{Kind: msg.Kind, Data: msg.Message}
+	if msg.Kind == KindText {
+		frame.Data = h.stampSequence(msg.SessionID, msg.Message)
+		h.publishLocked(msg.SessionID, frame.Data)
+	}
+	for id, client := range session {
+		if msg.ExcludeID != "" && id == msg.ExcludeID {
+			continue
 		}
+		h.trySend(client, frame)
 	}
 }
 
+// stampSequence sets the next per-session sequence number on a broadcast
+// message so clients can detect gaps and reorder. Fields not known to the
+// server are preserved.
+func (h *Hub) stampSequence(sessionID string, message []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(message, &fields); err != nil {
+		return message
+	}
+
+	h.seqMu.Lock()
+	h.seq[sessionID]++
+	seq := h.seq[sessionID]
+	h.seqMu.Unlock()
+
+	fields["seq"], _ = json.Marshal(seq)
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return message
+	}
+	return data
+}
+
 func (h *Hub) sendToClient(msg *DirectMessage) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -174,17 +695,104 @@ func (h *Hub) sendToClient(msg *DirectMessage) {
 		// Find client by user ID
 		for _, client := range session {
 			if client.UserID == msg.TargetID || client.ID == msg.TargetID {
-				select {
-				case client.Send <- msg.Message:
-				default:
-					log.Printf("Client %s buffer full, skipping direct message", client.ID)
-				}
+				h.trySend(client, Frame{Kind: msg.Kind, Data: msg.Message})
 				return
 			}
 		}
 	}
 }
 
+// trySend queues a message for a client without blocking the hub. A client
+// whose buffer stays full for WSMaxSendDrops messages in a row has a stalled
+// WritePump, so it is disconnected rather than left to silently miss
+// everything. Delivery resets the streak.
+func (h *Hub) trySend(client *Client, frame Frame) bool {
+	select {
+	case client.Send <- frame:
+		client.consecutiveDrops.Store(0)
+		return true
+	default:
+	}
+
+	client.droppedMessages.Add(1)
+	h.droppedMessages.Add(1)
+	drops := client.consecutiveDrops.Add(1)
+	slog.Warn("Client buffer full, dropping message", "session_id", client.SessionID, "client_id", client.ID, "consecutive_drops", drops)
+
+	if drops >= int64(h.config.WSMaxSendDrops) && client.evicted.CompareAndSwap(false, true) {
+		h.evictedClients.Add(1)
+		slog.Warn("Evicting stalled client", "session_id", client.SessionID, "user_id", client.UserID, "client_id", client.ID, "dropped", client.droppedMessages.Load())
+		// ReadPump will error out and unregister the client
+		client.Conn.Close()
+	}
+	return false
+}
+
+// sessionRate counts low-priority messages in a one-second window
+type sessionRate struct {
+	window time.Time
+	count  int
+}
+
+// AllowMessage applies the per-session rate limit. High-priority types
+// always pass so playback and signaling stay responsive during a chat flood;
+// low-priority ones share a budget of SessionChatRate per second across
+// every client in the session.
+func (h *Hub) AllowMessage(sessionID string, msgType models.MessageType) bool {
+	limit := h.config.SessionChatRate
+	if limit <= 0 || !models.IsLowPriority(msgType) {
+		return true
+	}
+
+	now := time.Now().Truncate(time.Second)
+	h.rateMu.Lock()
+	defer h.rateMu.Unlock()
+
+	rate, ok := h.rates[sessionID]
+	if !ok || !rate.window.Equal(now) {
+		rate = &sessionRate{window: now}
+		h.rates[sessionID] = rate
+	}
+	if rate.count >= limit {
+		h.throttledMessages.Add(1)
+		return false
+	}
+	rate.count++
+	return true
+}
+
+// Metrics returns the hub's backpressure counters along with every live
+// connection that has dropped messages
+func (h *Hub) Metrics() *models.HubMetrics {
+	metrics := &models.HubMetrics{
+		DroppedMessages:   h.droppedMessages.Load(),
+		EvictedClients:    h.evictedClients.Load(),
+		ThrottledMessages: h.throttledMessages.Load(),
+		BroadcastQueue:    h.broadcastStats.snapshot(len(h.broadcast), cap(h.broadcast)),
+		DirectQueue:       h.directStats.snapshot(len(h.direct), cap(h.direct)),
+		Clients:           []models.ClientDropStats{},
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, session := range h.sessions {
+		for _, client := range session {
+			dropped := client.droppedMessages.Load()
+			if dropped == 0 {
+				continue
+			}
+			metrics.Clients = append(metrics.Clients, models.ClientDropStats{
+				SessionID:        client.SessionID,
+				UserID:           client.UserID,
+				ClientID:         client.ID,
+				DroppedMessages:  dropped,
+				ConsecutiveDrops: client.consecutiveDrops.Load(),
+			})
+		}
+	}
+	return metrics
+}
+
 func (h *Hub) notifyUserJoined(client *Client) {
 	msg := map[string]interface{}{
 		"type": "user_joined",
@@ -198,15 +806,18 @@ func (h *Hub) notifyUserJoined(client *Client) {
 	}
 
 	data, _ := json.Marshal(msg)
+	data = h.stampSequence(client.SessionID, data)
+	h.publishLocked(client.SessionID, data)
+	h.webhooks.Notify(models.WebhookUserJoined, client.SessionID, &models.WebhookUserData{
+		UserID:   client.UserID,
+		Username: client.Username,
+	})
 
 	// Broadcast to all clients in session except the new one
 	if session, ok := h.sessions[client.SessionID]; ok {
 		for id, c := range session {
 			if id != client.ID {
-				select {
-				case c.Send <- data:
-				default:
-				}
+				h.trySend(c, Frame{Data: data})
 			}
 		}
 	}
@@ -225,44 +836,234 @@ func (h *Hub) notifyUserLeft(client *Client) {
 	}
 
 	data, _ := json.Marshal(msg)
+	data = h.stampSequence(client.SessionID, data)
+	h.publishLocked(client.SessionID, data)
+	h.webhooks.Notify(models.WebhookUserLeft, client.SessionID, &models.WebhookUserData{
+		UserID:   client.UserID,
+		Username: client.Username,
+	})
 
 	// Broadcast to remaining clients in session
 	if session, ok := h.sessions[client.SessionID]; ok {
 		for _, c := range session {
-			select {
-			case c.Send <- data:
-			default:
-			}
+			h.trySend(c, Frame{Data: data})
 		}
 	}
 }
 
-// Register adds a client to the hub
-func (h *Hub) Register(client *Client) {
-	h.register <- client
+// Register adds a client to the hub. It reads the client's state from Redis
+// in the caller's goroutine first. It does nothing and returns false once
+// the hub has stopped; the connection recorded for the client must then be
+// released.
+func (h *Hub) Register(client *Client) bool {
+	h.loadClientState(client)
+	h.trackConnection(client, true)
+
+	select {
+	case h.register <- client:
+		return true
+	case <-h.done:
+		return false
+	}
 }
 
-// Unregister removes a client from the hub
+// Unregister removes a client from the hub. It does nothing once the hub
+// has stopped.
 func (h *Hub) Unregister(client *Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
 }
 
-// Broadcast sends a message to all clients in a session
+// Broadcast sends a message to all clients in a session. It never blocks;
+// the message is dropped if the hub's broadcast queue is full.
 func (h *Hub) Broadcast(sessionID string, message []byte, excludeID string) {
-	h.broadcast <- &BroadcastMessage{
+	enqueue(h.broadcast, &BroadcastMessage{
 		SessionID: sessionID,
 		Message:   message,
 		ExcludeID: excludeID,
-	}
+	}, &h.broadcastStats, "broadcast")
+}
+
+// BroadcastBinary relays a binary frame verbatim to all clients in a session
+func (h *Hub) BroadcastBinary(sessionID string, message []byte, excludeID string) {
+	enqueue(h.broadcast, &BroadcastMessage{
+		SessionID: sessionID,
+		Message:   message,
+		Kind:      KindBinary,
+		ExcludeID: excludeID,
+	}, &h.broadcastStats, "broadcast")
 }
 
-// SendToUser sends a message to a specific user
+// SendToUser sends a message to a specific user. Like Broadcast, it drops
+// the message rather than block when the hub's direct queue is full.
 func (h *Hub) SendToUser(sessionID, targetID string, message []byte) {
-	h.direct <- &DirectMessage{
+	enqueue(h.direct, &DirectMessage{
+		SessionID: sessionID,
+		TargetID:  targetID,
+		Message:   message,
+	}, &h.directStats, "direct")
+}
+
+// SendBinaryToUser relays a binary frame verbatim to a specific user
+func (h *Hub) SendBinaryToUser(sessionID, targetID string, message []byte) {
+	enqueue(h.direct, &DirectMessage{
 		SessionID: sessionID,
 		TargetID:  targetID,
 		Message:   message,
+		Kind:      KindBinary,
+	}, &h.directStats, "direct")
+}
+
+// userClients returns the connections a user has open in a session
+func (h *Hub) userClients(sessionID, userID string) []*Client {
+	var clients []*Client
+	h.mu.RLock()
+	for _, client := range h.sessions[sessionID] {
+		if client.UserID == userID {
+			clients = append(clients, client)
+		}
 	}
+	h.mu.RUnlock()
+	return clients
+}
+
+// DisconnectUser closes all connections a user has open in a session, with
+// a close frame saying they were removed from it
+func (h *Hub) DisconnectUser(sessionID, userID string) {
+	// Closing unregisters through the hub, which needs h.mu
+	for _, client := range h.userClients(sessionID, userID) {
+		client.closeWith(models.CloseReasonKicked)
+	}
+}
+
+// SignOutUser sends a final event to each of a user's connections and then
+// closes them with reason, so the client learns why it was disconnected
+func (h *Hub) SignOutUser(sessionID, userID string, reason models.CloseReason, msgType models.MessageType, payload interface{}) {
+	for _, client := range h.userClients(sessionID, userID) {
+		client.signOut(reason, msgType, payload)
+	}
+}
+
+// CloseSession closes every connection and subscription in a session, with
+// a close frame saying the session has ended
+func (h *Hub) CloseSession(sessionID string) {
+	h.mu.Lock()
+	clients := make([]*Client, 0, len(h.sessions[sessionID]))
+	for _, client := range h.sessions[sessionID] {
+		clients = append(clients, client)
+	}
+	h.endSubscriptionsLocked(sessionID)
+	h.mu.Unlock()
+
+	for _, client := range clients {
+		client.closeWith(models.CloseReasonSessionEnded)
+	}
+}
+
+// SetHost moves host privileges to userID for all live connections in a
+// session and notifies the room
+func (h *Hub) SetHost(sessionID, userID string) {
+	h.mu.RLock()
+	for _, client := range h.sessions[sessionID] {
+		client.setHost(client.UserID == userID)
+	}
+	h.mu.RUnlock()
+
+	h.BroadcastEvent(sessionID, models.MessageTypeHostChanged, models.HostChangedPayload{HostID: userID})
+}
+
+// SetControllers updates which connected users may control playback and
+// notifies the session
+func (h *Hub) SetControllers(sessionID string, controllers []string) {
+	allowed := make(map[string]bool, len(controllers))
+	for _, id := range controllers {
+		allowed[id] = true
+	}
+
+	h.mu.RLock()
+	for _, client := range h.sessions[sessionID] {
+		client.setController(allowed[client.UserID])
+	}
+	h.mu.RUnlock()
+
+	h.BroadcastEvent(sessionID, models.MessageTypeControllersChanged, models.ControllersChangedPayload{Controllers: controllers})
+}
+
+// SetMuted updates a user's chat mute on their live connections and
+// notifies the session
+func (h *Hub) SetMuted(sessionID, userID string, muted bool) {
+	h.mu.RLock()
+	for _, client := range h.sessions[sessionID] {
+		if client.UserID == userID {
+			client.setMuted(muted)
+		}
+	}
+	h.mu.RUnlock()
+
+	msgType := models.MessageTypeUserUnmuted
+	if muted {
+		msgType = models.MessageTypeUserMuted
+	}
+	h.BroadcastEvent(sessionID, msgType, models.UserMutedPayload{UserID: userID})
+}
+
+// SendToHost sends a message to the host of a session, if connected
+func (h *Hub) SendToHost(sessionID string, message []byte) {
+	h.mu.RLock()
+	var hostID string
+	for _, client := range h.sessions[sessionID] {
+		if client.isHost() {
+			hostID = client.UserID
+			break
+		}
+	}
+	h.mu.RUnlock()
+
+	if hostID != "" {
+		h.SendToUser(sessionID, hostID, message)
+	}
+}
+
+// BroadcastEvent sends a server-originated event to all clients in a session
+func (h *Hub) BroadcastEvent(sessionID string, msgType models.MessageType, payload interface{}) {
+	if msg := newEvent(sessionID, msgType, payload); msg != nil {
+		h.Broadcast(sessionID, msg, "")
+	}
+}
+
+// SendEventToHost sends a server-originated event to the session's host only
+func (h *Hub) SendEventToHost(sessionID string, msgType models.MessageType, payload interface{}) {
+	if msg := newEvent(sessionID, msgType, payload); msg != nil {
+		h.SendToHost(sessionID, msg)
+	}
+}
+
+// newEvent encodes a server-originated message, returning nil on failure
+func newEvent(sessionID string, msgType models.MessageType, payload interface{}) []byte {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Failed to marshal event payload", "session_id", sessionID, "type", msgType, "error", err)
+		return nil
+	}
+
+	msg, err := json.Marshal(models.WebSocketMessage{
+		Type:      msgType,
+		Payload:   data,
+		SessionID: sessionID,
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		slog.Error("Failed to marshal event", "session_id", sessionID, "type", msgType, "error", err)
+		return nil
+	}
+	return msg
+}
+
+// ResolveJoinRequest records the host's approval or denial of a waiting joiner
+func (h *Hub) ResolveJoinRequest(sessionID, requestID string, approved bool) error {
+	return h.redis.ResolvePendingJoin(context.Background(), sessionID, requestID, approved)
 }
 
 // GetSessionClients returns all clients in a session
@@ -279,6 +1080,25 @@ func (h *Hub) GetSessionClients(sessionID string) []*Client {
 	return clients
 }
 
+// ConnectedClients returns the number of connections across all sessions
+// on this instance
+func (h *Hub) ConnectedClients() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	total := 0
+	for _, session := range h.sessions {
+		total += len(session)
+	}
+	return total
+}
+
+// ProcessedMessages returns how many client messages the hub has handled
+// since it started
+func (h *Hub) ProcessedMessages() int64 {
+	return h.processedMessages.Load()
+}
+
 // GetClientCount returns the number of clients in a session
 func (h *Hub) GetClientCount(sessionID string) int {
 	h.mu.RLock()
@@ -289,3 +1109,33 @@ func (h *Hub) GetClientCount(sessionID string) int {
 	}
 	return 0
 }
+
+// SpectatorCount returns how many spectators are connected to a session on
+// this server
+func (h *Hub) SpectatorCount(sessionID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, client := range h.sessions[sessionID] {
+		if client.IsSpectator {
+			seen[client.UserID] = true
+		}
+	}
+	return len(seen)
+}
+
+// Spectators returns the usernames of the spectators connected to a session
+// on this server, keyed by user ID
+func (h *Hub) Spectators(sessionID string) map[string]string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	spectators := make(map[string]string)
+	for _, client := range h.sessions[sessionID] {
+		if client.IsSpectator {
+			spectators[client.UserID] = client.Username
+		}
+	}
+	return spectators
+}
@@ -0,0 +1,555 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+)
+
+func TestEmptySessionIsClosedAfterGrace(t *testing.T) {
+	hub, mr := newTestHub(t, func(cfg *config.Config) {
+		cfg.EmptySessionGrace = 50 * time.Millisecond
+	})
+	hostID := newID()
+	session := saveSession(t, hub, hostID)
+	host := connect(t, hub, session.ID, hostID, true)
+	host.send(models.MessageTypeChat, models.ChatPayload{Message: "bye"})
+	host.expect(models.MessageTypeChat)
+
+	host.disconnect()
+
+	waitFor(t, "idle session to be deleted", func() bool {
+		return !mr.Exists("session:" + session.ID)
+	})
+	waitFor(t, "chat history to be cleared", func() bool {
+		return !mr.Exists("chat:" + session.ID)
+	})
+	if !mr.Exists("expired:" + session.ID) {
+		t.Error("closed session was not marked expired")
+	}
+}
+
+func TestReconnectWithinGraceKeepsSession(t *testing.T) {
+	grace := 150 * time.Millisecond
+	hub, mr := newTestHub(t, func(cfg *config.Config) {
+		cfg.EmptySessionGrace = grace
+	})
+	hostID := newID()
+	session := saveSession(t, hub, hostID)
+	host := connect(t, hub, session.ID, hostID, true)
+
+	host.disconnect()
+	time.Sleep(grace / 3)
+	connect(t, hub, session.ID, hostID, true)
+
+	// Well past the original deadline
+	time.Sleep(2 * grace)
+	if !mr.Exists("session:" + session.ID) {
+		t.Fatal("session was deleted although the host reconnected within the grace period")
+	}
+}
+
+func TestControllerGrantUseAndRevoke(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	hostID, viewerID := newID(), newID()
+	session := saveSession(t, hub, hostID, viewerID)
+	host := connect(t, hub, session.ID, hostID, true)
+	viewer := connect(t, hub, session.ID, viewerID, false)
+
+	// Grant
+	hub.SetControllers(session.ID, []string{viewerID})
+	for _, c := range []*testClient{host, viewer} {
+		var changed models.ControllersChangedPayload
+		decode(t, c.expect(models.MessageTypeControllersChanged).Payload, &changed)
+		if len(changed.Controllers) != 1 || changed.Controllers[0] != viewerID {
+			t.Errorf("%s: controllers = %v, want [%s]", c.UserID, changed.Controllers, viewerID)
+		}
+	}
+
+	// Use
+	viewer.send(models.MessageTypePlaybackState, models.PlaybackStatePayload{Playing: true, CurrentTime: 12})
+	var state models.PlaybackStatePayload
+	decode(t, host.expect(models.MessageTypePlaybackState).Payload, &state)
+	if !state.Playing || state.CurrentTime != 12 {
+		t.Errorf("host got state %+v from the controller", state)
+	}
+	viewer.send(models.MessageTypePlaybackControl, models.PlaybackControlPayload{Action: "pause"})
+	var control models.PlaybackControlPayload
+	decode(t, host.expect(models.MessageTypePlaybackControl).Payload, &control)
+	if control.Action != "pause" || control.FromUser != viewerID {
+		t.Errorf("host got control %+v, want pause from the controller", control)
+	}
+
+	// Revoke
+	hub.SetControllers(session.ID, nil)
+	viewer.expect(models.MessageTypeControllersChanged)
+	viewer.send(models.MessageTypePlaybackState, models.PlaybackStatePayload{Playing: false})
+	if got := viewer.expectError(); got.Code != models.ErrorCodeForbidden {
+		t.Errorf("error code = %q, want %q", got.Code, models.ErrorCodeForbidden)
+	}
+	host.expectNone(models.MessageTypePlaybackState, 100*time.Millisecond)
+}
+
+func TestControllerRoleIsRestoredOnReconnect(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	hostID, viewerID := newID(), newID()
+	session := saveSession(t, hub, hostID, viewerID)
+	if _, err := hub.redis.SetSessionController(context.Background(), session.ID, viewerID, true); err != nil {
+		t.Fatalf("SetSessionController: %v", err)
+	}
+	host := connect(t, hub, session.ID, hostID, true)
+	viewer := connect(t, hub, session.ID, viewerID, false)
+
+	viewer.send(models.MessageTypePlaybackState, models.PlaybackStatePayload{Playing: true})
+
+	host.expect(models.MessageTypePlaybackState)
+}
+
+func TestStalledClientIsEvictedAfterDropThreshold(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.WSSendBuffer = 4
+		cfg.WSMaxSendDrops = 3
+	})
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	stalled := connect(t, hub, sessionID, newID(), false, func(c *Client) {
+		c.Conn.(*fakeConn).stall = make(chan struct{})
+	})
+
+	// The stalled WritePump holds one frame and the buffer the rest; the
+	// hub drops every message after that until the threshold is reached
+	for i := 0; i < 1+4+3; i++ {
+		hub.Broadcast(sessionID, host.message(models.MessageTypeTyping, map[string]int{"n": i}), host.ID)
+	}
+
+	waitFor(t, "stalled client to be evicted", func() bool {
+		return stalled.conn.isClosed() && !hub.HasTarget(sessionID, stalled.ID)
+	})
+	metrics := hub.Metrics()
+	if metrics.EvictedClients != 1 {
+		t.Errorf("evicted clients = %d, want 1", metrics.EvictedClients)
+	}
+	if metrics.DroppedMessages < 3 {
+		t.Errorf("dropped messages = %d, want at least 3", metrics.DroppedMessages)
+	}
+
+	// Everyone else keeps receiving messages
+	if !hub.HasTarget(sessionID, host.ID) {
+		t.Fatal("well-behaved client was removed")
+	}
+	hub.BroadcastEvent(sessionID, models.MessageTypeChat, models.ChatPayload{Message: "still here"})
+	host.expect(models.MessageTypeChat)
+}
+
+func TestSlowClientUnderThresholdIsKept(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.WSSendBuffer = 4
+		cfg.WSMaxSendDrops = 100
+	})
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	stall := make(chan struct{})
+	slow := connect(t, hub, sessionID, newID(), false, func(c *Client) {
+		c.Conn.(*fakeConn).stall = stall
+	})
+
+	for i := 0; i < 10; i++ {
+		hub.Broadcast(sessionID, host.message(models.MessageTypeTyping, map[string]int{"n": i}), host.ID)
+	}
+	waitFor(t, "messages to be dropped", func() bool {
+		return hub.Metrics().DroppedMessages > 0
+	})
+
+	// Once the peer reads again the client recovers
+	close(stall)
+	waitFor(t, "slow client to drain its buffer", func() bool {
+		return len(slow.Send) == 0
+	})
+	hub.BroadcastEvent(sessionID, models.MessageTypeChat, models.ChatPayload{Message: "caught up"})
+	slow.expect(models.MessageTypeChat)
+	if hub.Metrics().EvictedClients != 0 || slow.conn.isClosed() {
+		t.Error("slow client under the drop threshold was evicted")
+	}
+}
+
+func TestReconnectWithinDebounceSendsNoEvents(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.UserLeftDebounce = 200 * time.Millisecond
+	})
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+	host.expect(models.MessageTypeUserJoined)
+
+	viewer.disconnect()
+	connect(t, hub, sessionID, viewer.UserID, false)
+
+	host.expectNone(models.MessageTypeUserLeft, 400*time.Millisecond)
+	host.expectNone(models.MessageTypeUserJoined, 50*time.Millisecond)
+}
+
+func TestUserLeftIsSentAfterDebounce(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.UserLeftDebounce = 50 * time.Millisecond
+	})
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+	host.expect(models.MessageTypeUserJoined)
+
+	viewer.disconnect()
+
+	var left struct {
+		UserID string `json:"user_id"`
+	}
+	decode(t, host.expect(models.MessageTypeUserLeft).Payload, &left)
+	if left.UserID != viewer.UserID {
+		t.Errorf("user_left for %q, want %q", left.UserID, viewer.UserID)
+	}
+	host.expectNone(models.MessageTypeUserLeft, 100*time.Millisecond)
+}
+
+func TestUserLeftClearsMediaState(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+	host.expect(models.MessageTypeUserJoined)
+	mediaStates := func() int {
+		states, _ := hub.redis.GetMediaStates(context.Background(), sessionID)
+		return len(states)
+	}
+
+	viewer.send(models.MessageTypeMediaState, models.MediaStatePayload{AudioEnabled: true})
+	waitFor(t, "media state to be saved", func() bool { return mediaStates() == 1 })
+
+	viewer.disconnect()
+	host.expect(models.MessageTypeUserLeft)
+	waitFor(t, "media state to be cleared", func() bool { return mediaStates() == 0 })
+}
+
+func TestClientJoinsWhenChatHistoryIsUnreadable(t *testing.T) {
+	hub, mr := newTestHub(t, nil)
+	sessionID := newID()
+	// A value of the wrong type makes every history read fail
+	mr.Set("chat:"+sessionID, "not a list")
+
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	viewer.send(models.MessageTypeChat, models.ChatPayload{Message: "hello"})
+	viewer.expect(models.MessageTypeChat)
+}
+
+func TestMutedUserChatIsDropped(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	hub.SetMuted(sessionID, viewer.UserID, true)
+	for _, c := range []*testClient{host, viewer} {
+		var muted models.UserMutedPayload
+		decode(t, c.expect(models.MessageTypeUserMuted).Payload, &muted)
+		if muted.UserID != viewer.UserID {
+			t.Errorf("%s: user_muted for %q, want %q", c.UserID, muted.UserID, viewer.UserID)
+		}
+	}
+
+	viewer.send(models.MessageTypeChat, models.ChatPayload{Message: "can anyone hear me"})
+	if got := viewer.expectError(); got.Code != models.ErrorCodeMuted {
+		t.Errorf("error code = %q, want %q", got.Code, models.ErrorCodeMuted)
+	}
+	host.expectNone(models.MessageTypeChat, 100*time.Millisecond)
+
+	hub.SetMuted(sessionID, viewer.UserID, false)
+	host.expect(models.MessageTypeUserUnmuted)
+	viewer.send(models.MessageTypeChat, models.ChatPayload{Message: "back again"})
+	var chat models.ChatPayload
+	decode(t, host.expect(models.MessageTypeChat).Payload, &chat)
+	if chat.Message != "back again" {
+		t.Errorf("host got %q after unmute", chat.Message)
+	}
+}
+
+func TestMuteSurvivesReconnect(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID, viewerID := newID(), newID()
+	if err := hub.redis.SetUserMuted(context.Background(), sessionID, viewerID, true); err != nil {
+		t.Fatalf("SetUserMuted: %v", err)
+	}
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, viewerID, false)
+
+	viewer.send(models.MessageTypeChat, models.ChatPayload{Message: "hello"})
+
+	if got := viewer.expectError(); got.Code != models.ErrorCodeMuted {
+		t.Errorf("error code = %q, want %q", got.Code, models.ErrorCodeMuted)
+	}
+	host.expectNone(models.MessageTypeChat, 100*time.Millisecond)
+}
+
+// awayFromSecondBoundary waits out the end of the current second, so a
+// test of the per-second session budget runs within a single window
+func awayFromSecondBoundary() {
+	if left := time.Until(time.Now().Truncate(time.Second).Add(time.Second)); left < 200*time.Millisecond {
+		time.Sleep(left + 10*time.Millisecond)
+	}
+}
+
+func TestAllowMessageSharesBudgetAcrossSession(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.SessionChatRate = 3
+	})
+	sessionID := newID()
+	awayFromSecondBoundary()
+
+	for i := 0; i < 3; i++ {
+		if !hub.AllowMessage(sessionID, models.MessageTypeChat) {
+			t.Fatalf("message %d throttled within the budget", i+1)
+		}
+	}
+	for _, msgType := range []models.MessageType{models.MessageTypeChat, models.MessageTypeTyping, models.MessageTypeReaction} {
+		if hub.AllowMessage(sessionID, msgType) {
+			t.Errorf("%s allowed over the session budget", msgType)
+		}
+	}
+	for _, msgType := range []models.MessageType{models.MessageTypePlaybackControl, models.MessageTypePlaybackState, models.MessageTypeWebRTCOffer, models.MessageTypeICECandidate} {
+		if !hub.AllowMessage(sessionID, msgType) {
+			t.Errorf("high-priority %s throttled", msgType)
+		}
+	}
+	if !hub.AllowMessage(newID(), models.MessageTypeChat) {
+		t.Error("another session's budget was used up")
+	}
+}
+
+func TestChatFloodIsThrottledWhilePlaybackControlPasses(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.SessionChatRate = 3
+	})
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewers := []*testClient{
+		connect(t, hub, sessionID, newID(), false),
+		connect(t, hub, sessionID, newID(), false),
+	}
+	awayFromSecondBoundary()
+
+	// Each viewer stays well under the per-connection limit, but together
+	// they exceed the session's budget, so both are throttled
+	for i := 0; i < 4; i++ {
+		for _, v := range viewers {
+			v.send(models.MessageTypeChat, models.ChatPayload{Message: "spam"})
+		}
+	}
+	for _, v := range viewers {
+		if got := v.expectError(); got.Code != models.ErrorCodeRateLimited {
+			t.Errorf("%s: error code = %q, want %q", v.UserID, got.Code, models.ErrorCodeRateLimited)
+		}
+	}
+
+	host.send(models.MessageTypePlaybackControl, models.PlaybackControlPayload{Action: "pause"})
+	for _, v := range viewers {
+		var control models.PlaybackControlPayload
+		decode(t, v.expect(models.MessageTypePlaybackControl).Payload, &control)
+		if control.Action != "pause" {
+			t.Errorf("%s got %+v, want pause", v.UserID, control)
+		}
+	}
+}
+
+func TestRapidConnectDisconnect(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	hostID := newID()
+	session := saveSession(t, hub, hostID)
+	host := connect(t, hub, session.ID, hostID, true)
+
+	const workers, rounds = 8, 25
+	var pumps, wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			userID := newID()
+			for i := 0; i < rounds; i++ {
+				conn := newFakeConn()
+				client := NewClient(conn, hub, session.ID, userID, "user-"+userID, false, hub.config.WSSendBuffer)
+				if !hub.Register(client) {
+					t.Error("hub refused to register client")
+					return
+				}
+				pumps.Add(2)
+				go func() { defer pumps.Done(); client.WritePump() }()
+				go func() { defer pumps.Done(); client.ReadPump() }()
+
+				// Race the client's own traffic and broadcasts against the
+				// teardown, ending it from a different side each time
+				conn.deliver(websocket.TextMessage, mustMarshal(t, models.WebSocketMessage{
+					Type:    models.MessageTypeChat,
+					Payload: mustMarshal(t, models.ChatPayload{Message: "hi"}),
+				}))
+				hub.Broadcast(session.ID, []byte(`{"type":"noop"}`), "")
+				switch (w + i) % 3 {
+				case 0:
+					conn.Close()
+				case 1:
+					hub.DisconnectUser(session.ID, userID)
+					conn.Close()
+				case 2:
+					client.stop()
+					client.stop()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		pumps.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("client pumps did not exit")
+	}
+
+	waitFor(t, "disconnected clients to unregister", func() bool {
+		return hub.GetClientCount(session.ID) == 1
+	})
+	waitFor(t, "connection count to settle", func() bool {
+		count, err := hub.redis.GetConnectionCount(context.Background(), session.ID)
+		return err == nil && count == 1
+	})
+
+	// The hub still serves the clients that stayed
+	host.send(models.MessageTypeChat, models.ChatPayload{Message: "still here"})
+	host.expect(models.MessageTypeChat)
+}
+
+func TestChatIsRelayedButNotStoredWithPersistenceDisabled(t *testing.T) {
+	hub, mr := newTestHub(t, func(cfg *config.Config) {
+		cfg.ChatPersistence = false
+	})
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	for _, sender := range []*testClient{host, viewer} {
+		sender.send(models.MessageTypeChat, models.ChatPayload{Message: "hello from " + sender.UserID})
+		for _, c := range []*testClient{host, viewer} {
+			var chat models.ChatPayload
+			decode(t, c.expect(models.MessageTypeChat).Payload, &chat)
+			if chat.UserID != sender.UserID {
+				t.Errorf("%s got chat from %s, want %s", c.UserID, chat.UserID, sender.UserID)
+			}
+		}
+	}
+
+	if mr.Exists("chat:"+sessionID) || mr.Exists("transcript:"+sessionID) {
+		t.Errorf("chat was written to Redis: keys %v", mr.Keys())
+	}
+	late := connect(t, hub, sessionID, newID(), false)
+	late.expectNone(models.MessageTypeChat, 100*time.Millisecond)
+}
+
+func TestJoinAndLeaveSendWebhooks(t *testing.T) {
+	events := make(chan models.WebhookEvent, 16)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event models.WebhookEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	t.Cleanup(receiver.Close)
+
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.WebhookURLs = []string{receiver.URL}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go hub.webhooks.Run(ctx)
+
+	sessionID := newID()
+	viewer := connect(t, hub, sessionID, newID(), false)
+	viewer.disconnect()
+
+	for _, want := range []string{models.WebhookUserJoined, models.WebhookUserLeft} {
+		select {
+		case event := <-events:
+			data, _ := event.Data.(map[string]interface{})
+			if event.Event != want || event.SessionID != sessionID || data["user_id"] != viewer.UserID {
+				t.Errorf("got %+v, want %s for %s", event, want, viewer.UserID)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("no %s webhook", want)
+		}
+	}
+}
+
+func TestCancellingContextStopsRun(t *testing.T) {
+	hub, _ := newIdleHub(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(stopped)
+	}()
+	waitFor(t, "hub to start", hub.Running)
+	viewer := connect(t, hub, newID(), newID(), false)
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(testTimeout):
+		t.Fatal("Run did not return after its context was cancelled")
+	}
+	waitCtx, done := context.WithTimeout(context.Background(), testTimeout)
+	defer done()
+	if err := hub.Wait(waitCtx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if hub.Running() {
+		t.Error("hub still reports running")
+	}
+
+	if code, _ := viewer.expectClose(); code != models.CloseCodeShutdown {
+		t.Errorf("close code = %d, want %d", code, models.CloseCodeShutdown)
+	}
+	late := NewClient(newFakeConn(), hub, newID(), newID(), "late", false, hub.config.WSSendBuffer)
+	if hub.Register(late) {
+		t.Error("stopped hub accepted a new client")
+	}
+}
+
+func TestBroadcastReachesFakeClients(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	first := connect(t, hub, sessionID, newID(), true)
+	second := connect(t, hub, sessionID, newID(), false)
+	sender := connect(t, hub, sessionID, newID(), false)
+	elsewhere := connect(t, hub, newID(), newID(), false)
+
+	hub.BroadcastEvent(sessionID, models.MessageTypeReaction, models.ReactionPayload{Emoji: "🎉"})
+	hub.Broadcast(sessionID, first.message(models.MessageTypeChat, models.ChatPayload{Message: "hi"}), sender.ID)
+
+	for _, c := range []*testClient{first, second} {
+		var reaction models.ReactionPayload
+		decode(t, c.expect(models.MessageTypeReaction).Payload, &reaction)
+		if reaction.Emoji != "🎉" {
+			t.Errorf("%s: reaction = %q, want 🎉", c.UserID, reaction.Emoji)
+		}
+		c.expect(models.MessageTypeChat)
+	}
+	sender.expect(models.MessageTypeReaction)
+	sender.expectNone(models.MessageTypeChat, 50*time.Millisecond)
+	elsewhere.expectNone(models.MessageTypeReaction, 50*time.Millisecond)
+}
@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"watchparty/internal/models"
+)
+
+// maxIdleSweepInterval bounds how late an idle viewer may be noticed
+const maxIdleSweepInterval = time.Minute
+
+// RunIdleSweeper disconnects viewers that have sent no messages for
+// WSIdleTimeout. Pongs don't count, since every open tab answers pings;
+// clients that want to stay connected while passive should send a message
+// such as media_state now and then. Hosts are never disconnected. It
+// returns at once if the timeout is disabled.
+func (h *Hub) RunIdleSweeper(ctx context.Context) {
+	timeout := h.config.WSIdleTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	interval := timeout / 2
+	if interval > maxIdleSweepInterval {
+		interval = maxIdleSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.sweepIdleClients(timeout)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *Hub) sweepIdleClients(timeout time.Duration) {
+	var idle []*Client
+	h.mu.RLock()
+	for _, session := range h.sessions {
+		for _, client := range session {
+			// Spectators are passive by design, so silence means nothing
+			if !client.isHost() && !client.IsSpectator && client.idleFor() > timeout {
+				idle = append(idle, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	// Signing out unregisters through the hub, which needs h.mu
+	for _, client := range idle {
+		idleFor := client.idleFor()
+		slog.Info("Disconnecting idle client", "session_id", client.SessionID, "user_id", client.UserID, "client_id", client.ID, "idle", idleFor)
+		client.signOut(models.CloseReasonIdle, models.MessageTypeIdleTimeout, models.IdleTimeoutPayload{
+			IdleSeconds: int64(idleFor.Seconds()),
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+)
+
+func TestSilentViewerIsEvicted(t *testing.T) {
+	const timeout = 150 * time.Millisecond
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.WSIdleTimeout = timeout
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go hub.RunIdleSweeper(ctx)
+
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	silent := connect(t, hub, sessionID, newID(), false)
+	active := connect(t, hub, sessionID, newID(), false)
+	spectator := connect(t, hub, sessionID, newID(), false, func(c *Client) { c.IsSpectator = true })
+
+	// Keep one viewer busy well past the timeout
+	stop := time.After(3 * timeout)
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+keepAlive:
+	for {
+		select {
+		case <-ticker.C:
+			active.send(models.MessageTypeMediaState, models.MediaStatePayload{AudioEnabled: true})
+		case <-stop:
+			break keepAlive
+		}
+	}
+
+	var notice models.IdleTimeoutPayload
+	decode(t, silent.expect(models.MessageTypeIdleTimeout).Payload, &notice)
+	code, text := silent.expectClose()
+	var closing models.CloseFramePayload
+	if err := json.Unmarshal([]byte(text), &closing); err != nil || closing.Reason != models.CloseReasonIdle {
+		t.Errorf("close frame %d %q, want reason %q", code, text, models.CloseReasonIdle)
+	}
+	if code != models.CloseReasonIdle.Code() {
+		t.Errorf("close code = %d, want %d", code, models.CloseReasonIdle.Code())
+	}
+	waitFor(t, "idle viewer to unregister", func() bool {
+		return !hub.HasTarget(sessionID, silent.ID)
+	})
+
+	for _, c := range []*testClient{host, active, spectator} {
+		if !hub.HasTarget(sessionID, c.ID) {
+			t.Errorf("%s was disconnected although hosts, active viewers and spectators are exempt", c.UserID)
+		}
+	}
+}
+
+func TestIdleSweeperIsOptIn(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	if hub.config.WSIdleTimeout != 0 {
+		t.Fatalf("idle timeout defaults to %v, want disabled", hub.config.WSIdleTimeout)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		hub.RunIdleSweeper(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("sweeper kept running with the timeout disabled")
+	}
+}
@@ -0,0 +1,80 @@
+package websocket
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// playbackWrite is a session's latest playback state waiting to be persisted
+type playbackWrite struct {
+	message []byte
+	timer   *time.Timer
+}
+
+// SavePlaybackState stores a session's latest playback state so late joiners
+// and reconnecting viewers land at the host's position, e.g. the paused
+// frame rather than 0. Writes are debounced by PlaybackSaveDebounce, so a
+// burst of seeks costs one Redis write of the final state.
+func (h *Hub) SavePlaybackState(sessionID string, message []byte) {
+	h.playbackMu.Lock()
+	defer h.playbackMu.Unlock()
+
+	if pending, ok := h.playbackWrites[sessionID]; ok {
+		pending.message = message
+		return
+	}
+	pending := &playbackWrite{message: message}
+	h.playbackWrites[sessionID] = pending
+	pending.timer = time.AfterFunc(h.config.PlaybackSaveDebounce, func() {
+		h.flushPlaybackState(sessionID, pending)
+	})
+}
+
+// flushPlaybackState writes a pending playback state to Redis
+func (h *Hub) flushPlaybackState(sessionID string, pending *playbackWrite) {
+	h.playbackMu.Lock()
+	if h.playbackWrites[sessionID] != pending {
+		// Dropped because the session closed
+		h.playbackMu.Unlock()
+		return
+	}
+	delete(h.playbackWrites, sessionID)
+	message := pending.message
+	h.playbackMu.Unlock()
+
+	if err := h.redis.SavePlaybackState(context.Background(), sessionID, message); err != nil {
+		slog.Error("Failed to save playback state", "session_id", sessionID, "error", err)
+	}
+}
+
+// playbackState returns a session's latest playback state message, or nil
+// if none has been sent. A state still waiting to be written wins over the
+// stored one.
+func (h *Hub) playbackState(sessionID string) []byte {
+	h.playbackMu.Lock()
+	if pending, ok := h.playbackWrites[sessionID]; ok {
+		message := pending.message
+		h.playbackMu.Unlock()
+		return message
+	}
+	h.playbackMu.Unlock()
+
+	message, err := h.redis.GetPlaybackState(context.Background(), sessionID)
+	if err != nil {
+		slog.Warn("Failed to get playback state", "session_id", sessionID, "error", err)
+		return nil
+	}
+	return message
+}
+
+// dropPlaybackState cancels a closed session's pending write
+func (h *Hub) dropPlaybackState(sessionID string) {
+	h.playbackMu.Lock()
+	defer h.playbackMu.Unlock()
+
+	if pending, ok := h.playbackWrites[sessionID]; ok {
+		pending.timer.Stop()
+		delete(h.playbackWrites, sessionID)
+	}
+}
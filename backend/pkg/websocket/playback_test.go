@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+)
+
+func TestLateJoinerLandsAtPausedPosition(t *testing.T) {
+	hub, mr := newTestHub(t, nil)
+	hostID := newID()
+	session := saveSession(t, hub, hostID)
+	host := connect(t, hub, session.ID, hostID, true)
+
+	host.send(models.MessageTypePlaybackState, models.PlaybackStatePayload{Playing: true, CurrentTime: 30})
+	host.send(models.MessageTypePlaybackState, models.PlaybackStatePayload{Playing: false, CurrentTime: 120})
+	waitFor(t, "playback state to be stored", func() bool {
+		stored, err := mr.Get("playback_state:" + session.ID)
+		return err == nil && isPausedAt(stored, 120)
+	})
+	if ttl := mr.TTL("playback_state:" + session.ID); ttl <= 0 || ttl > hub.config.SessionTTL {
+		t.Errorf("playback state TTL = %v, want the session's", ttl)
+	}
+
+	late := connect(t, hub, session.ID, newID(), false)
+	var state models.PlaybackStatePayload
+	decode(t, late.expect(models.MessageTypePlaybackState).Payload, &state)
+	if state.Playing || state.CurrentTime < 119.5 || state.CurrentTime > 120.5 {
+		t.Errorf("late joiner got %+v, want paused at 120", state)
+	}
+}
+
+func TestRapidSeeksAreDebounced(t *testing.T) {
+	const debounce = 150 * time.Millisecond
+	hub, mr := newTestHub(t, func(cfg *config.Config) {
+		cfg.PlaybackSaveDebounce = debounce
+	})
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	start := time.Now()
+	for i := 1; i <= 20; i++ {
+		host.send(models.MessageTypePlaybackState, models.PlaybackStatePayload{CurrentTime: float64(i)})
+	}
+	for i := 1; i <= 20; i++ {
+		viewer.expect(models.MessageTypePlaybackState)
+	}
+	if time.Since(start) < debounce && mr.Exists("playback_state:"+sessionID) {
+		t.Error("playback state was written before the debounce elapsed")
+	}
+
+	// Joiners during the debounce already get the latest state
+	early := connect(t, hub, sessionID, newID(), false)
+	var state models.PlaybackStatePayload
+	decode(t, early.expect(models.MessageTypePlaybackState).Payload, &state)
+	if state.CurrentTime != 20 {
+		t.Errorf("joiner during debounce got position %v, want 20", state.CurrentTime)
+	}
+
+	waitFor(t, "final state to be stored", func() bool {
+		stored, err := mr.Get("playback_state:" + sessionID)
+		return err == nil && isPausedAt(stored, 20)
+	})
+}
+
+// isPausedAt reports whether a stored playback_state message is paused at
+// position
+func isPausedAt(message string, position float64) bool {
+	var msg models.WebSocketMessage
+	var state models.PlaybackStatePayload
+	if json.Unmarshal([]byte(message), &msg) != nil || json.Unmarshal(msg.Payload, &state) != nil {
+		return false
+	}
+	return !state.Playing && state.CurrentTime == position
+}
@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"log/slog"
+	"sync/atomic"
+
+	"watchparty/internal/models"
+)
+
+// queueNearFull is the fill ratio at which a hub queue counts as near full
+const queueNearFull = 0.8
+
+// queueStats instruments one of the hub's inbound queues so its buffer can
+// be sized from real traffic
+type queueStats struct {
+	peak     atomic.Int64 // Deepest the queue has been
+	nearFull atomic.Int64 // Sends that found the queue near full
+	dropped  atomic.Int64 // Sends refused because the queue was full
+}
+
+// enqueue hands item to the hub without blocking the caller, which is often
+// a client's ReadPump. When the hub has fallen so far behind that the queue
+// is full, the item is dropped and counted rather than stalling the sender.
+func enqueue[T any](ch chan T, item T, stats *queueStats, name string) bool {
+	select {
+	case ch <- item:
+	default:
+		stats.dropped.Add(1)
+		slog.Warn("Hub queue full, dropping message", "queue", name, "capacity", cap(ch))
+		return false
+	}
+
+	depth := int64(len(ch))
+	for {
+		peak := stats.peak.Load()
+		if depth <= peak || stats.peak.CompareAndSwap(peak, depth) {
+			break
+		}
+	}
+	if float64(depth) >= queueNearFull*float64(cap(ch)) {
+		stats.nearFull.Add(1)
+		slog.Debug("Hub queue near full", "queue", name, "length", depth, "capacity", cap(ch))
+	}
+	return true
+}
+
+// snapshot reports the queue's current state and counters
+func (s *queueStats) snapshot(length, capacity int) models.QueueStats {
+	return models.QueueStats{
+		Length:   length,
+		Capacity: capacity,
+		Peak:     s.peak.Load(),
+		NearFull: s.nearFull.Load(),
+		Dropped:  s.dropped.Load(),
+	}
+}
@@ -0,0 +1,54 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+)
+
+func TestSaturatedHubQueuesDropInsteadOfBlocking(t *testing.T) {
+	// Not running, so nothing drains the queues
+	hub, _ := newIdleHub(t, func(cfg *config.Config) {
+		cfg.HubBroadcastBuffer = 5
+		cfg.HubDirectBuffer = 2
+	})
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 12; i++ {
+			hub.Broadcast("session-1", []byte(`{"type":"reaction"}`), "")
+			hub.SendToUser("session-1", "client-1", []byte(`{"type":"ice_candidate"}`))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("sending to a saturated hub blocked the caller")
+	}
+
+	metrics := hub.Metrics()
+	want := map[string]models.QueueStats{
+		// 4 of 5 is the first send at 80%, then the fifth
+		"broadcast": {Length: 5, Capacity: 5, Peak: 5, NearFull: 2, Dropped: 7},
+		"direct":    {Length: 2, Capacity: 2, Peak: 2, NearFull: 1, Dropped: 10},
+	}
+	got := map[string]models.QueueStats{"broadcast": metrics.BroadcastQueue, "direct": metrics.DirectQueue}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s queue = %+v, want %+v", name, got[name], w)
+		}
+	}
+}
+
+func TestHubQueueSizesAreConfigurable(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.HubBroadcastBuffer = 7
+		cfg.HubDirectBuffer = 3
+	})
+	if cap(hub.broadcast) != 7 || cap(hub.direct) != 3 {
+		t.Errorf("queue capacities = %d and %d, want 7 and 3", cap(hub.broadcast), cap(hub.direct))
+	}
+}
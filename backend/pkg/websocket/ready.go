@@ -0,0 +1,140 @@
+package websocket
+
+import (
+	"math"
+	"time"
+
+	"watchparty/internal/models"
+)
+
+// readyPositionTolerance is how far, in seconds, a viewer's reported
+// position may be from the requested one and still count as ready for it
+const readyPositionTolerance = 0.5
+
+// readyRound tracks which users have buffered for a pending synchronized start
+type readyRound struct {
+	position float64
+	ready    map[string]bool // User IDs
+	timer    *time.Timer
+}
+
+// StartReadyRound begins waiting for every connected user to buffer at
+// position, replacing any round already in progress. The user who asked
+// counts as ready. sync_play is broadcast once all users are ready or
+// ReadyTimeout passes, whichever comes first.
+func (h *Hub) StartReadyRound(sessionID, userID string, position float64) {
+	round := &readyRound{
+		position: position,
+		ready:    map[string]bool{userID: true},
+	}
+
+	h.readyMu.Lock()
+	if prev, ok := h.readyRounds[sessionID]; ok {
+		prev.timer.Stop()
+	}
+	h.readyRounds[sessionID] = round
+	round.timer = time.AfterFunc(h.config.ReadyTimeout, func() {
+		h.finishReadyRound(sessionID, round, true)
+	})
+	h.readyMu.Unlock()
+
+	// The requester may be alone in the room
+	h.checkReadyQuorum(sessionID)
+}
+
+// MarkReady records that a user has buffered at position. It reports false
+// when there is no round in progress or the position is for an older seek.
+func (h *Hub) MarkReady(sessionID, userID string, position float64) bool {
+	h.readyMu.Lock()
+	defer h.readyMu.Unlock()
+
+	round, ok := h.readyRounds[sessionID]
+	if !ok || math.Abs(round.position-position) > readyPositionTolerance {
+		return false
+	}
+	round.ready[userID] = true
+	return true
+}
+
+// checkReadyQuorum starts playback if every connected user is ready
+func (h *Hub) checkReadyQuorum(sessionID string) {
+	users := h.connectedUsers(sessionID)
+
+	h.readyMu.Lock()
+	round, ok := h.readyRounds[sessionID]
+	if !ok {
+		h.readyMu.Unlock()
+		return
+	}
+	for _, userID := range users {
+		if !round.ready[userID] {
+			h.readyMu.Unlock()
+			return
+		}
+	}
+	h.readyMu.Unlock()
+
+	h.finishReadyRound(sessionID, round, false)
+}
+
+// finishReadyRound ends a round and tells everyone when to start. It does
+// nothing if the round has already finished or been replaced.
+func (h *Hub) finishReadyRound(sessionID string, round *readyRound, timedOut bool) {
+	h.readyMu.Lock()
+	if h.readyRounds[sessionID] != round {
+		h.readyMu.Unlock()
+		return
+	}
+	delete(h.readyRounds, sessionID)
+	round.timer.Stop()
+	h.readyMu.Unlock()
+
+	// The round is no longer reachable, so its ready set can't change
+	var waiting []string
+	if timedOut {
+		for _, userID := range h.connectedUsers(sessionID) {
+			if !round.ready[userID] {
+				waiting = append(waiting, userID)
+			}
+		}
+	}
+
+	h.BroadcastEvent(sessionID, models.MessageTypeSyncPlay, models.SyncPlayPayload{
+		Position: round.position,
+		At:       time.Now().Add(h.config.SyncPlayLead).UnixMilli(),
+		TimedOut: timedOut,
+		Waiting:  waiting,
+	})
+}
+
+// cancelReadyRound drops a session's pending round without starting playback
+func (h *Hub) cancelReadyRound(sessionID string) {
+	h.readyMu.Lock()
+	defer h.readyMu.Unlock()
+
+	if round, ok := h.readyRounds[sessionID]; ok {
+		round.timer.Stop()
+		delete(h.readyRounds, sessionID)
+	}
+}
+
+// connectedUsers returns the distinct user IDs with a live connection to a
+// session
+func (h *Hub) connectedUsers(sessionID string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var users []string
+	for _, c := range h.sessions[sessionID] {
+		// Spectators follow along but never hold up the start
+		if c.IsSpectator {
+			continue
+		}
+		if !seen[c.UserID] {
+			seen[c.UserID] = true
+			users = append(users, c.UserID)
+		}
+	}
+	return users
+}
@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"watchparty/internal/config"
+	"watchparty/internal/models"
+)
+
+// startRound has host ask everyone to buffer at position and waits until
+// the viewers have been told
+func startRound(t *testing.T, host *testClient, position float64, viewers ...*testClient) {
+	t.Helper()
+	host.send(models.MessageTypeWaitForAll, models.WaitForAllPayload{Position: position})
+	for _, v := range viewers {
+		v.expect(models.MessageTypeWaitForAll)
+	}
+}
+
+func TestSyncPlayWaitsForEveryViewer(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	alice := connect(t, hub, sessionID, newID(), false)
+	bob := connect(t, hub, sessionID, newID(), false)
+
+	startRound(t, host, 42, alice, bob)
+
+	alice.send(models.MessageTypeReady, models.ReadyPayload{Position: 42})
+	var ready models.ReadyPayload
+	decode(t, host.expect(models.MessageTypeReady).Payload, &ready)
+	if ready.UserID != alice.UserID {
+		t.Errorf("host told %s is ready, want %s", ready.UserID, alice.UserID)
+	}
+	host.expectNone(models.MessageTypeSyncPlay, 100*time.Millisecond)
+
+	bob.send(models.MessageTypeReady, models.ReadyPayload{Position: 42.2})
+	for _, c := range []*testClient{host, alice, bob} {
+		var play models.SyncPlayPayload
+		decode(t, c.expect(models.MessageTypeSyncPlay).Payload, &play)
+		if play.Position != 42 || play.TimedOut || len(play.Waiting) != 0 {
+			t.Errorf("%s: got sync_play %+v", c.UserID, play)
+		}
+		if play.At < time.Now().UnixMilli() {
+			t.Errorf("%s: sync_play starts in the past", c.UserID)
+		}
+	}
+}
+
+func TestReadyForOlderSeekIsIgnored(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	startRound(t, host, 10, viewer)
+	startRound(t, host, 90, viewer)
+
+	viewer.send(models.MessageTypeReady, models.ReadyPayload{Position: 10})
+	host.expectNone(models.MessageTypeSyncPlay, 100*time.Millisecond)
+
+	viewer.send(models.MessageTypeReady, models.ReadyPayload{Position: 90})
+	var play models.SyncPlayPayload
+	decode(t, host.expect(models.MessageTypeSyncPlay).Payload, &play)
+	if play.Position != 90 {
+		t.Errorf("sync_play position = %v, want 90", play.Position)
+	}
+}
+
+func TestSyncPlayAfterTimeoutNamesSlowViewers(t *testing.T) {
+	hub, _ := newTestHub(t, func(cfg *config.Config) {
+		cfg.ReadyTimeout = 100 * time.Millisecond
+	})
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	fast := connect(t, hub, sessionID, newID(), false)
+	slow := connect(t, hub, sessionID, newID(), false)
+
+	startRound(t, host, 5, fast, slow)
+	fast.send(models.MessageTypeReady, models.ReadyPayload{Position: 5})
+
+	var play models.SyncPlayPayload
+	decode(t, host.expect(models.MessageTypeSyncPlay).Payload, &play)
+	if !play.TimedOut || !slices.Equal(play.Waiting, []string{slow.UserID}) {
+		t.Errorf("sync_play = %+v, want timed out waiting for %s", play, slow.UserID)
+	}
+}
+
+func TestDepartedViewerDoesNotHoldUpSyncPlay(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	ready := connect(t, hub, sessionID, newID(), false)
+	gone := connect(t, hub, sessionID, newID(), false)
+
+	startRound(t, host, 0, ready, gone)
+	ready.send(models.MessageTypeReady, models.ReadyPayload{Position: 0})
+	host.expect(models.MessageTypeReady)
+	host.expectNone(models.MessageTypeSyncPlay, 50*time.Millisecond)
+
+	gone.disconnect()
+	var play models.SyncPlayPayload
+	decode(t, host.expect(models.MessageTypeSyncPlay).Payload, &play)
+	if play.TimedOut {
+		t.Errorf("sync_play = %+v, want quorum without the departed viewer", play)
+	}
+}
+
+func TestViewerCannotStartReadyRound(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+
+	viewer.send(models.MessageTypeWaitForAll, models.WaitForAllPayload{Position: 3})
+	if got := viewer.expectError(); got.Code != models.ErrorCodeForbidden {
+		t.Errorf("error code = %q, want %q", got.Code, models.ErrorCodeForbidden)
+	}
+	host.expectNone(models.MessageTypeWaitForAll, 50*time.Millisecond)
+	if hub.MarkReady(sessionID, host.UserID, 3) {
+		t.Error("a round was started by a viewer")
+	}
+}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"strings"
+
+	"github.com/gofiber/websocket/v2"
+	"watchparty/internal/models"
+)
+
+// RotateSession moves a session's live state to newID once its data has
+// been migrated. Tokens name the session they were issued for, so each
+// connected client is sent its re-issued token from tokens, keyed by user
+// ID, in a session_rotated message and then disconnected, to reconnect as
+// the same user under the new ID. A client without a token, e.g. a
+// spectator who connected after tokens was built, must join again. Clients are detached from the old ID
+// first, so their disconnect is neither announced as a departure nor
+// treated as the session emptying out.
+func (h *Hub) RotateSession(oldID, newID, shareURL string, tokens map[string]string) {
+	h.mu.Lock()
+	clients := h.sessions[oldID]
+	delete(h.sessions, oldID)
+	if timer, ok := h.emptyTimers[oldID]; ok {
+		timer.Stop()
+		delete(h.emptyTimers, oldID)
+	}
+	prefix := leaveKey(oldID, "")
+	for key, timer := range h.leaveTimers {
+		if strings.HasPrefix(key, prefix) {
+			timer.Stop()
+			delete(h.leaveTimers, key)
+		}
+	}
+	h.endSubscriptionsLocked(oldID)
+	h.mu.Unlock()
+
+	h.seqMu.Lock()
+	delete(h.seq, oldID)
+	h.seqMu.Unlock()
+	h.rateMu.Lock()
+	delete(h.rates, oldID)
+	h.rateMu.Unlock()
+	h.cancelReadyRound(oldID)
+
+	// A playback state not yet written would otherwise land under the old ID
+	h.playbackMu.Lock()
+	pending, ok := h.playbackWrites[oldID]
+	if ok {
+		pending.timer.Stop()
+		delete(h.playbackWrites, oldID)
+	}
+	h.playbackMu.Unlock()
+	if ok {
+		h.SavePlaybackState(newID, pending.message)
+	}
+
+	for _, client := range clients {
+		client.sendEvent(models.MessageTypeSessionRotated, models.SessionRotatedPayload{
+			SessionID: newID,
+			ShareURL:  shareURL,
+			Token:     tokens[client.UserID],
+		})
+		// No longer registered, so close Send here; WritePump flushes the
+		// event, sends the close frame and ends the connection
+		client.setCloseMessage(websocket.CloseNormalClosure, string(models.MessageTypeSessionRotated))
+		client.closeSend()
+	}
+}
@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/gofiber/websocket/v2"
+	"watchparty/internal/models"
+)
+
+func TestRotateSessionHandsClientsNewTokens(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	oldID, newSessionID := newID(), newID()
+	host := connect(t, hub, oldID, newID(), true)
+	viewer := connect(t, hub, oldID, newID(), false)
+	host.expect(models.MessageTypeUserJoined)
+	tokens := map[string]string{host.UserID: "host-token", viewer.UserID: "viewer-token"}
+
+	hub.RotateSession(oldID, newSessionID, "https://watch.example.com/join/"+newSessionID, tokens)
+
+	for _, c := range []*testClient{host, viewer} {
+		var rotated models.SessionRotatedPayload
+		decode(t, c.expect(models.MessageTypeSessionRotated).Payload, &rotated)
+		if rotated.SessionID != newSessionID || rotated.Token != tokens[c.UserID] || rotated.ShareURL == "" {
+			t.Errorf("%s: got %+v, want its own token for %s", c.UserID, rotated, newSessionID)
+		}
+		if code, _ := c.expectClose(); code != websocket.CloseNormalClosure {
+			t.Errorf("%s: close code = %d, want %d", c.UserID, code, websocket.CloseNormalClosure)
+		}
+	}
+	waitFor(t, "old session to be detached", func() bool {
+		return hub.GetClientCount(oldID) == 0
+	})
+
+	connect(t, hub, newSessionID, host.UserID, true)
+	if n := hub.GetClientCount(newSessionID); n != 1 {
+		t.Errorf("new session has %d clients, want 1", n)
+	}
+}
+
+func TestRotateSessionHandsSpectatorsTokens(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	oldID, newSessionID := newID(), newID()
+	host := connect(t, hub, oldID, newID(), true)
+	spectator := connect(t, hub, oldID, newID(), false, asSpectator, func(c *Client) { c.Username = "Quiet Heron" })
+	late := connect(t, hub, oldID, newID(), false, asSpectator)
+
+	spectators := hub.Spectators(oldID)
+	if len(spectators) != 2 || spectators[spectator.UserID] != "Quiet Heron" {
+		t.Fatalf("Spectators = %v, want both spectators by username", spectators)
+	}
+	// The late spectator connected after the tokens were issued
+	tokens := map[string]string{host.UserID: "host-token", spectator.UserID: "spectator-token"}
+
+	hub.RotateSession(oldID, newSessionID, "https://watch.example.com/join/"+newSessionID, tokens)
+
+	for _, c := range []*testClient{spectator, late} {
+		var rotated models.SessionRotatedPayload
+		decode(t, c.expect(models.MessageTypeSessionRotated).Payload, &rotated)
+		if rotated.SessionID != newSessionID || rotated.Token != tokens[c.UserID] {
+			t.Errorf("%s: got %+v, want token %q for %s", c.UserID, rotated, tokens[c.UserID], newSessionID)
+		}
+		if code, _ := c.expectClose(); code != websocket.CloseNormalClosure {
+			t.Errorf("%s: close code = %d, want %d", c.UserID, code, websocket.CloseNormalClosure)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"watchparty/internal/models"
+)
+
+// asSpectator makes a client connect as a read-only spectator
+func asSpectator(c *Client) { c.IsSpectator = true }
+
+func TestSpectatorReceivesEventsButCannotSend(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	viewer := connect(t, hub, sessionID, newID(), false)
+	spectator := connect(t, hub, sessionID, newID(), false, asSpectator)
+
+	host.send(models.MessageTypeChat, models.ChatPayload{Message: "welcome"})
+	host.send(models.MessageTypePlaybackControl, models.PlaybackControlPayload{Action: "pause"})
+	var chat models.ChatPayload
+	decode(t, spectator.expect(models.MessageTypeChat).Payload, &chat)
+	if chat.Message != "welcome" {
+		t.Errorf("spectator got chat %q, want the host's message", chat.Message)
+	}
+	spectator.expect(models.MessageTypePlaybackControl)
+	host.expect(models.MessageTypeChat) // chat is echoed to its sender
+	viewer.expect(models.MessageTypeChat)
+	viewer.expect(models.MessageTypePlaybackControl)
+
+	for _, msgType := range []models.MessageType{models.MessageTypeChat, models.MessageTypePlaybackControl} {
+		spectator.send(msgType, models.ChatPayload{Message: "hello"})
+		if got := spectator.expectError(); got.Code != models.ErrorCodeForbidden {
+			t.Errorf("%s from spectator: error code = %q, want %q", msgType, got.Code, models.ErrorCodeForbidden)
+		}
+	}
+	for _, c := range []*testClient{host, viewer} {
+		c.expectNone(models.MessageTypeChat, 50*time.Millisecond)
+		c.expectNone(models.MessageTypePlaybackControl, 50*time.Millisecond)
+	}
+}
+
+func TestSpectatorsAreCountedSeparately(t *testing.T) {
+	hub, _ := newTestHub(t, nil)
+	sessionID := newID()
+	host := connect(t, hub, sessionID, newID(), true)
+	connect(t, hub, sessionID, newID(), false)
+	spectator := connect(t, hub, sessionID, newID(), false, asSpectator)
+	connect(t, hub, sessionID, newID(), false, asSpectator)
+
+	if got := hub.SpectatorCount(sessionID); got != 2 {
+		t.Errorf("SpectatorCount = %d, want 2", got)
+	}
+	// Spectators come and go unannounced
+	host.expect(models.MessageTypeUserJoined) // the viewer
+	host.expectNone(models.MessageTypeUserJoined, 50*time.Millisecond)
+
+	spectator.disconnect()
+	if got := hub.SpectatorCount(sessionID); got != 1 {
+		t.Errorf("SpectatorCount after a spectator left = %d, want 1", got)
+	}
+	host.expectNone(models.MessageTypeUserLeft, 50*time.Millisecond)
+}
@@ -0,0 +1,88 @@
+package websocket
+
+// subscriptionBuffer is how many broadcasts a subscriber may fall behind
+// before messages are dropped for it
+const subscriptionBuffer = 64
+
+// Subscription is a read-only feed of a session's text broadcasts for
+// consumers that are not WebSocket clients, such as SSE streams. C is closed
+// when the session ends, its tokens are revoked, the hub stops or the
+// subscription is cancelled.
+type Subscription struct {
+	SessionID string
+	C         <-chan []byte
+	ch        chan []byte
+}
+
+// Subscribe starts receiving every text message broadcast to a session.
+// Callers must Unsubscribe when done.
+func (h *Hub) Subscribe(sessionID string) *Subscription {
+	ch := make(chan []byte, subscriptionBuffer)
+	sub := &Subscription{SessionID: sessionID, C: ch, ch: ch}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers[sessionID] == nil {
+		h.subscribers[sessionID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[sessionID][sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe stops a subscription and closes its channel. It is safe to
+// call after the session has already ended it.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs, ok := h.subscribers[sub.SessionID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	close(sub.ch)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.SessionID)
+	}
+}
+
+// publishLocked fans a broadcast out to a session's subscribers without
+// blocking. The caller must hold h.mu for reading.
+func (h *Hub) publishLocked(sessionID string, message []byte) {
+	for sub := range h.subscribers[sessionID] {
+		select {
+		case sub.ch <- message:
+		default:
+			h.droppedMessages.Add(1)
+		}
+	}
+}
+
+// EndSubscriptions closes every subscription to a session, e.g. once the
+// tokens they were opened with are revoked. Subscribers still allowed in
+// must subscribe again.
+func (h *Hub) EndSubscriptions(sessionID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.endSubscriptionsLocked(sessionID)
+}
+
+// endSubscriptionsLocked closes every subscription to a session. The caller
+// must hold h.mu.
+func (h *Hub) endSubscriptionsLocked(sessionID string) {
+	for sub := range h.subscribers[sessionID] {
+		close(sub.ch)
+	}
+	delete(h.subscribers, sessionID)
+}
+
+// SubscriberCount returns how many subscriptions a session has on this server
+func (h *Hub) SubscriberCount(sessionID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers[sessionID])
+}